@@ -1,19 +1,34 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
+	"github.com/ajtroup1/platinum-trophy-tracker/config"
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
 	"github.com/ajtroup1/platinum-trophy-tracker/service/account"
 	"github.com/ajtroup1/platinum-trophy-tracker/service/achievement"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
 	"github.com/ajtroup1/platinum-trophy-tracker/service/game"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/importer"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/job"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/leaderboard"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/mail"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/ratelimit"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/rawg"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/screenshot"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/session"
 	"github.com/ajtroup1/platinum-trophy-tracker/service/user"
 	usergame "github.com/ajtroup1/platinum-trophy-tracker/service/user_game"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/verification"
 	"github.com/gorilla/mux"
 )
 
@@ -39,6 +54,11 @@ func clearConsole() {
 }
 
 func (s *APIServer) Run() error {
+	// Anyone who knows the secret can forge tokens for any user
+	if len(config.Envs.JWTSecret) < config.MinJWTSecretLength {
+		return fmt.Errorf("JWT_SECRET must be set to at least %d characters", config.MinJWTSecretLength)
+	}
+
 	clearConsole()
 	router := mux.NewRouter()
 	subrouter := router.PathPrefix("/api/v1").Subrouter()
@@ -48,19 +68,124 @@ func (s *APIServer) Run() error {
 	userGameStore := usergame.NewStore(s.db)
 	accountStore := account.NewStore(s.db)
 	achStore := achievement.NewStore(s.db)
-	
-	userHandler := user.NewHandler(userStore)
+	screenshotStore := screenshot.NewStore(s.db)
+	sessionStore := session.NewStore(s.db)
+	tokenStore := verification.NewStore(s.db)
+	twoFactorStore := auth.NewStore(s.db)
+	leaderboardStore := leaderboard.NewStore(s.db)
+
+	mailer, err := mail.NewMailer(config.Envs)
+	if err != nil {
+		return err
+	}
+
+	subrouter.Use(auth.JWTMiddleware(userStore, sessionStore,
+		"/api/v1/login",
+		"/api/v1/login/2fa",
+		"/api/v1/register",
+		"/api/v1/token/refresh",
+		"/api/v1/reactivate-account",
+		"/api/v1/verify-email",
+		"/api/v1/forgot-password",
+		"/api/v1/reset-password",
+	))
+
+	// Rate limits run after authentication so per-user buckets know who is asking
+	rateLimitStore := ratelimit.NewMemoryStore()
+	limiter := ratelimit.NewLimiter(rateLimitStore, ratelimit.Policy{Rate: 10, Burst: 30, PerIP: true, PerUser: true}).
+		Limit("/api/v1/login", ratelimit.Policy{Rate: ratelimit.PerMinute(10), Burst: 5, PerIP: true}).
+		Limit("/api/v1/login/2fa", ratelimit.Policy{Rate: ratelimit.PerMinute(10), Burst: 5, PerIP: true}).
+		Limit("/api/v1/reactivate-account", ratelimit.Policy{Rate: ratelimit.PerMinute(10), Burst: 5, PerIP: true}).
+		Limit("/api/v1/register", ratelimit.Policy{Rate: ratelimit.PerHour(10), Burst: 5, PerIP: true}).
+		Limit("/api/v1/forgot-password", ratelimit.Policy{Rate: ratelimit.PerHour(5), Burst: 3, PerIP: true}).
+		Limit("/api/v1/resend-verification", ratelimit.Policy{Rate: ratelimit.PerHour(5), Burst: 3, PerUser: true}).
+		Limit("/api/v1/import-progress", ratelimit.Policy{Rate: ratelimit.PerMinute(10), Burst: 5, PerUser: true}).
+		// These proxy to RAWG with our API key
+		Limit("/api/v1/game-search", ratelimit.Policy{Rate: 1, Burst: 5, PerIP: true, PerUser: true}).
+		Limit("/api/v1/games/search", ratelimit.Policy{Rate: 1, Burst: 5, PerIP: true, PerUser: true}).
+		Limit("/api/v1/add-game-db/{id:[0-9]+}", ratelimit.Policy{Rate: ratelimit.PerMinute(5), Burst: 3, PerIP: true, PerUser: true})
+	subrouter.Use(limiter.Middleware)
+
+	lockout := ratelimit.NewLockout(rateLimitStore,
+		int(config.Envs.LoginMaxFailures),
+		time.Second*time.Duration(config.Envs.LoginLockoutInSeconds),
+		time.Second*time.Duration(config.Envs.LoginMaxLockoutInSeconds),
+	)
+
+	userHandler := user.NewHandler(userStore, sessionStore, tokenStore, twoFactorStore, mailer, lockout)
 	userHandler.RegisterRoutes(subrouter)
 
-	accountHandler := account.NewHandler(accountStore)
+	twoFactorHandler := auth.NewHandler(twoFactorStore, userStore)
+	twoFactorHandler.RegisterRoutes(subrouter)
+
+	verificationHandler := verification.NewHandler(tokenStore, userStore, sessionStore, mailer)
+	verificationHandler.RegisterRoutes(subrouter)
+
+	sessionHandler := session.NewHandler(sessionStore, userStore)
+	sessionHandler.RegisterRoutes(subrouter)
+
+	accountHandler := account.NewHandler(accountStore, userStore)
 	accountHandler.RegisterRoutes(subrouter)
 
-	gameHandler := game.NewHandler(gameStore, userGameStore)
+	var rawgClient rawg.Client = rawg.NewClient(config.Envs)
+
+	var rawgCacheStore models.RAWGCacheStore
+	switch config.Envs.RAWGCache {
+	case "memory":
+		if config.Envs.RAWGCacheSize < 1 {
+			return fmt.Errorf("RAWG_CACHE_SIZE must be at least 1, set RAWG_CACHE=off to stop caching")
+		}
+		rawgCacheStore = rawg.NewMemoryStore(int(config.Envs.RAWGCacheSize))
+	case "mysql":
+		rawgCacheStore = rawg.NewStore(s.db)
+	case "off":
+	default:
+		return fmt.Errorf("unknown RAWG_CACHE '%s', expected memory, mysql or off", config.Envs.RAWGCache)
+	}
+	if rawgCacheStore != nil {
+		cachedClient := rawg.NewCachedClient(rawgClient, rawgCacheStore, rawg.CacheTTL{
+			Search:       time.Second * time.Duration(config.Envs.RAWGSearchTTLInSeconds),
+			Game:         time.Second * time.Duration(config.Envs.RAWGGameTTLInSeconds),
+			Achievements: time.Second * time.Duration(config.Envs.RAWGAchievementsTTLInSeconds),
+			Screenshots:  time.Second * time.Duration(config.Envs.RAWGScreenshotsTTLInSeconds),
+		})
+		rawgClient = cachedClient
+
+		rawgHandler := rawg.NewHandler(cachedClient)
+		rawgHandler.RegisterRoutes(subrouter)
+	}
+
+	jobStore := job.NewStore(s.db)
+	jobQueue := job.NewQueue(jobStore, gameStore, rawgClient,
+		int(config.Envs.JobWorkers),
+		int(config.Envs.JobMaxAttempts),
+		time.Second*time.Duration(config.Envs.JobRetryBackoffInSeconds),
+	)
+	if err := jobQueue.Start(context.Background()); err != nil {
+		return err
+	}
+	if config.Envs.ResyncIntervalInHours > 0 {
+		go jobQueue.ScheduleResync(context.Background(), time.Hour*time.Duration(config.Envs.ResyncIntervalInHours))
+	}
+
+	jobHandler := job.NewHandler(jobStore)
+	jobHandler.RegisterRoutes(subrouter)
+
+	gameHandler := game.NewHandler(gameStore, userGameStore, achStore, screenshotStore, rawgClient, jobQueue)
 	gameHandler.RegisterRoutes(subrouter)
 
-	userGameHandler := usergame.NewHandler(userGameStore, achStore, gameStore)
+	userGameHandler := usergame.NewHandler(userGameStore, achStore, gameStore, userStore)
 	userGameHandler.RegisterRoutes(subrouter)
 
+	achievementHandler := achievement.NewHandler(achStore)
+	achievementHandler.RegisterRoutes(subrouter)
+
+	importHandler := importer.NewHandler(gameStore, userGameStore, achStore)
+	importHandler.RegisterRoutes(subrouter)
+
+	leaderboardHandler := leaderboard.NewHandler(leaderboardStore, gameStore)
+	leaderboardHandler.RegisterRoutes(subrouter)
+
 	s.Router = router
 
 	listener, err := net.Listen("tcp", s.addr)
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN email_verified;
\ No newline at end of file
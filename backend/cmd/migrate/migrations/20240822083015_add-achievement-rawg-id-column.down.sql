@@ -0,0 +1,3 @@
+ALTER TABLE achievements
+    DROP INDEX achievements_game_id_rawg_id,
+    DROP COLUMN rawg_id;
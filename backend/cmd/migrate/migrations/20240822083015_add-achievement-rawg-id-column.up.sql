@@ -0,0 +1,3 @@
+ALTER TABLE achievements
+    ADD COLUMN rawg_id INTEGER NULL,
+    ADD UNIQUE INDEX achievements_game_id_rawg_id (game_id, rawg_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS rawg_cache;
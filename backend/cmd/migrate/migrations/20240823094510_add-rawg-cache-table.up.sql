@@ -0,0 +1,6 @@
+CREATE TABLE rawg_cache (
+    cache_key VARCHAR(255) NOT NULL PRIMARY KEY,
+    value MEDIUMBLOB NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    INDEX rawg_cache_expires_at (expires_at)
+);
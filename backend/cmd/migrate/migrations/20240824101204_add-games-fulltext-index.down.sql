@@ -0,0 +1 @@
+ALTER TABLE games DROP INDEX games_name_slug_fulltext;
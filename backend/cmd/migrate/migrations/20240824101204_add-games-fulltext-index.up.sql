@@ -0,0 +1 @@
+ALTER TABLE games ADD FULLTEXT INDEX games_name_slug_fulltext (name, slug);
@@ -0,0 +1,5 @@
+ALTER TABLE screenshots
+    DROP INDEX screenshots_game_id_rawg_id,
+    DROP COLUMN rawg_id,
+    DROP COLUMN width,
+    DROP COLUMN height;
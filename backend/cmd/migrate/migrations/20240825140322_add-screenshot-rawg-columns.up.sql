@@ -0,0 +1,5 @@
+ALTER TABLE screenshots
+    ADD COLUMN rawg_id INTEGER NULL,
+    ADD COLUMN width INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN height INTEGER NOT NULL DEFAULT 0,
+    ADD UNIQUE INDEX screenshots_game_id_rawg_id (game_id, rawg_id);
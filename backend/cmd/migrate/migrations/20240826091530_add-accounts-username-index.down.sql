@@ -0,0 +1 @@
+ALTER TABLE accounts DROP INDEX accounts_platform_username_unique;
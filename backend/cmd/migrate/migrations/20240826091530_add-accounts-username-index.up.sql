@@ -0,0 +1 @@
+ALTER TABLE accounts ADD UNIQUE INDEX accounts_platform_username_unique (platform_id, username);
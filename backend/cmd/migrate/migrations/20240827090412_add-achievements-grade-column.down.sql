@@ -0,0 +1 @@
+ALTER TABLE achievements DROP COLUMN grade;
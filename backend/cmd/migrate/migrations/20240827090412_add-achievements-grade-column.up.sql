@@ -0,0 +1 @@
+ALTER TABLE achievements ADD COLUMN grade ENUM('bronze', 'silver', 'gold', 'platinum') NOT NULL DEFAULT 'bronze';
@@ -0,0 +1 @@
+UPDATE achievements SET grade = 'bronze';
@@ -0,0 +1,9 @@
+-- Grades what's already imported the way new imports are, by how few players
+-- earned each achievement
+UPDATE achievements
+SET grade = CASE
+    WHEN percent NOT REGEXP '^[0-9]+(\\.[0-9]+)?$' THEN 'bronze'
+    WHEN CAST(percent AS DECIMAL(6, 2)) < 10 THEN 'gold'
+    WHEN CAST(percent AS DECIMAL(6, 2)) < 35 THEN 'silver'
+    ELSE 'bronze'
+END;
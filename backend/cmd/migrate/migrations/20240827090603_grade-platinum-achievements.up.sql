@@ -0,0 +1,13 @@
+-- The rarest achievement that reads like a platinum is the game's platinum
+UPDATE achievements a
+JOIN (
+    SELECT id, ROW_NUMBER() OVER (
+        PARTITION BY game_id
+        ORDER BY IF(percent REGEXP '^[0-9]+(\\.[0-9]+)?$', CAST(percent AS DECIMAL(6, 2)), 100), id
+    ) AS n
+    FROM achievements
+    WHERE CONCAT(name, ' ', COALESCE(description, '')) REGEXP 'platinum|(all|every)( of the)?( other)? (trophies|achievements)'
+      AND game_id IN (SELECT game_id FROM (SELECT game_id FROM achievements GROUP BY game_id HAVING COUNT(*) > 1) AS multi)
+) platinums ON platinums.id = a.id
+SET a.grade = 'platinum'
+WHERE platinums.n = 1;
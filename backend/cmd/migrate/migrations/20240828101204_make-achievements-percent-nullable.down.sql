@@ -0,0 +1 @@
+ALTER TABLE achievements MODIFY percent VARCHAR(40) NOT NULL;
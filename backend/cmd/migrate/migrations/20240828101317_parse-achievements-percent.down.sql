@@ -0,0 +1 @@
+UPDATE achievements SET percent = '' WHERE percent IS NULL;
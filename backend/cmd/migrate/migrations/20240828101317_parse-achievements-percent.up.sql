@@ -0,0 +1,8 @@
+-- Keeps the number of percents such as "84.12" or "84.12%" so the column can
+-- become a decimal, anything else becomes unknown
+UPDATE achievements
+SET percent = CASE
+    WHEN TRIM(TRAILING '%' FROM TRIM(percent)) NOT REGEXP '^[0-9]+(\\.[0-9]+)?$' THEN NULL
+    WHEN CAST(TRIM(TRAILING '%' FROM TRIM(percent)) AS DECIMAL(8, 2)) > 100 THEN NULL
+    ELSE CAST(TRIM(TRAILING '%' FROM TRIM(percent)) AS DECIMAL(5, 2))
+END;
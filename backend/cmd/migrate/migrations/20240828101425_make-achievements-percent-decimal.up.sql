@@ -0,0 +1 @@
+ALTER TABLE achievements MODIFY percent DECIMAL(5, 2) NULL;
@@ -0,0 +1 @@
+ALTER TABLE user_games DROP INDEX user_games_completed_at;
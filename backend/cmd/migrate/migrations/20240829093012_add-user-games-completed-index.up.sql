@@ -0,0 +1 @@
+ALTER TABLE user_games ADD INDEX user_games_completed_at (completed_at, user_id);
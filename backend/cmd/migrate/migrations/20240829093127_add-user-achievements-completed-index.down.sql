@@ -0,0 +1 @@
+ALTER TABLE user_achievements DROP INDEX user_achievements_completed_at;
@@ -0,0 +1 @@
+ALTER TABLE user_achievements ADD INDEX user_achievements_completed_at (completed, completed_at, user_id);
@@ -0,0 +1,2 @@
+-- The duplicates are gone for good, there is nothing to restore
+SELECT 1;
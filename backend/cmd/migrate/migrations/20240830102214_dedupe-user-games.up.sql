@@ -0,0 +1,3 @@
+-- Concurrent requests could track a game twice, keep the first row of each
+DELETE dup FROM user_games dup
+JOIN user_games kept ON kept.user_id = dup.user_id AND kept.game_id = dup.game_id AND kept.id < dup.id;
@@ -0,0 +1 @@
+ALTER TABLE user_games ADD UNIQUE INDEX user_games_user_game (user_id, game_id);
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/config"
+	"github.com/ajtroup1/platinum-trophy-tracker/db"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/user"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Assigns a role to a user, e.g. `go run cmd/role/main.go -user adamjtroup -role admin`
+func main() {
+	username := flag.String("user", "", "username or email of the user")
+	roleName := flag.String("role", string(auth.RoleAdmin), "role to assign: user, moderator or admin")
+	flag.Parse()
+
+	if *username == "" {
+		log.Fatal("missing -user")
+	}
+
+	role, err := auth.ParseRole(*roleName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := db.NewMySQLStorage(mysql.Config{
+		User:                 config.Envs.DBUser,
+		Passwd:               config.Envs.DBPassword,
+		Addr:                 config.Envs.DBAddress,
+		DBName:               config.Envs.DBName,
+		Net:                  "tcp",
+		AllowNativePasswords: true,
+		ParseTime:            true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	store := user.NewStore(db)
+
+	u, err := store.GetUserByUsernameOrEmail(*username)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := store.SetUserRole(u.ID, string(role)); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("User %s (id %d) is now %s", u.Username, u.ID, role)
+}
@@ -3,32 +3,87 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	PublicHost string
-	Port       string
-	DBUser     string
-	DBPassword string
-	DBAddress  string
-	DBName     string
-	RAWGKey    string
+	PublicHost                   string
+	Port                         string
+	DBUser                       string
+	DBPassword                   string
+	DBAddress                    string
+	DBName                       string
+	RAWGKey                      string
+	RAWGBaseURL                  string
+	RAWGTimeoutInSeconds         int64
+	RAWGCache                    string
+	RAWGCacheSize                int64
+	RAWGSearchTTLInSeconds       int64
+	RAWGGameTTLInSeconds         int64
+	RAWGAchievementsTTLInSeconds int64
+	RAWGScreenshotsTTLInSeconds  int64
+	JWTSecret                    string
+	JWTExpirationInSeconds       int64
+	RefreshExpirationInSeconds   int64
+	AppURL                       string
+	SMTPHost                     string
+	SMTPPort                     string
+	SMTPUser                     string
+	SMTPPassword                 string
+	MailFrom                     string
+	MailLogFile                  string
+	LoginMaxFailures             int64
+	LoginLockoutInSeconds        int64
+	LoginMaxLockoutInSeconds     int64
+	JobWorkers                   int64
+	JobMaxAttempts               int64
+	JobRetryBackoffInSeconds     int64
+	ResyncIntervalInHours        int64
 }
 
+// MinJWTSecretLength is the shortest JWT_SECRET the API server starts with.
+// The secret signs access tokens and one-time tokens, so it has no default.
+const MinJWTSecretLength = 32
+
 var Envs = initConfig()
 
 func initConfig() Config {
 	godotenv.Load()
 	return Config{
-		PublicHost: getEnv("PUBLIC_HOST", "http://localhost"),
-		Port:       getEnv("PORT", "8080"),
-		DBUser:     getEnv("DB_USER", "root"),
-		DBPassword: getEnv("DB_PASSWORD", "password"),
-		DBAddress:  fmt.Sprintf("%s:%s", getEnv("DB_HOST", "127.0.0.1"), getEnv("DB_PORT", "3306")),
-		DBName:     getEnv("DB_NAME", "mydatabase"),
-		RAWGKey:    getEnv("RAWG_KEY", "key"),
+		PublicHost:                   getEnv("PUBLIC_HOST", "http://localhost"),
+		Port:                         getEnv("PORT", "8080"),
+		DBUser:                       getEnv("DB_USER", "root"),
+		DBPassword:                   getEnv("DB_PASSWORD", "password"),
+		DBAddress:                    fmt.Sprintf("%s:%s", getEnv("DB_HOST", "127.0.0.1"), getEnv("DB_PORT", "3306")),
+		DBName:                       getEnv("DB_NAME", "mydatabase"),
+		RAWGKey:                      getEnv("RAWG_KEY", "key"),
+		RAWGBaseURL:                  getEnv("RAWG_BASE_URL", "https://api.rawg.io/api"),
+		RAWGTimeoutInSeconds:         getEnvAsInt("RAWG_TIMEOUT", 10),
+		RAWGCache:                    getEnv("RAWG_CACHE", "memory"),
+		RAWGCacheSize:                getEnvAsInt("RAWG_CACHE_SIZE", 1000),
+		RAWGSearchTTLInSeconds:       getEnvAsInt("RAWG_SEARCH_TTL", 3600),
+		RAWGGameTTLInSeconds:         getEnvAsInt("RAWG_GAME_TTL", 3600*24),
+		RAWGAchievementsTTLInSeconds: getEnvAsInt("RAWG_ACHIEVEMENTS_TTL", 3600*24),
+		RAWGScreenshotsTTLInSeconds:  getEnvAsInt("RAWG_SCREENSHOTS_TTL", 3600*24*7),
+		JWTSecret:                    getEnv("JWT_SECRET", ""),
+		JWTExpirationInSeconds:       getEnvAsInt("JWT_EXP", 60*15),
+		RefreshExpirationInSeconds:   getEnvAsInt("REFRESH_EXP", 3600*24*30),
+		AppURL:                       getEnv("APP_URL", "http://localhost:5173"),
+		SMTPHost:                     getEnv("SMTP_HOST", ""),
+		SMTPPort:                     getEnv("SMTP_PORT", "587"),
+		SMTPUser:                     getEnv("SMTP_USER", ""),
+		SMTPPassword:                 getEnv("SMTP_PASSWORD", ""),
+		MailFrom:                     getEnv("MAIL_FROM", "noreply@platinumtrophytracker.local"),
+		MailLogFile:                  getEnv("MAIL_LOG_FILE", ""),
+		LoginMaxFailures:             getEnvAsInt("LOGIN_MAX_FAILURES", 5),
+		LoginLockoutInSeconds:        getEnvAsInt("LOGIN_LOCKOUT", 60),
+		LoginMaxLockoutInSeconds:     getEnvAsInt("LOGIN_MAX_LOCKOUT", 3600),
+		JobWorkers:                   getEnvAsInt("JOB_WORKERS", 2),
+		JobMaxAttempts:               getEnvAsInt("JOB_MAX_ATTEMPTS", 5),
+		JobRetryBackoffInSeconds:     getEnvAsInt("JOB_RETRY_BACKOFF", 10),
+		ResyncIntervalInHours:        getEnvAsInt("RESYNC_INTERVAL", 24),
 	}
 }
 
@@ -38,3 +93,16 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvAsInt(key string, fallback int64) int64 {
+	if value, ok := os.LookupEnv(key); ok {
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fallback
+		}
+
+		return i
+	}
+
+	return fallback
+}
@@ -1,12 +1,15 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // USER
 type User struct {
 	ID             uint32                `json:"id"`
 	Username       string                `json:"username"`
-	Password       string                `json:"password"`
+	Password       string                `json:"-"` // Never serialized, respond with one of the user views below
 	Firstname      string                `json:"firstname"`
 	Lastname       string                `json:"lastname"`
 	Email          string                `json:"email"`
@@ -18,6 +21,47 @@ type User struct {
 	CompletedGames int                   `json:"completedGames"`
 	LastLogin      time.Time             `json:"lastLogin"`
 	Deactivated    bool                  `json:"deactivated"`
+	Role           string                `json:"role"`
+	EmailVerified  bool                  `json:"emailVerified"`
+	DeactivatedBy  string                `json:"deactivatedBy"` // One of the DeactivatedBy constants, empty while active
+	Trophies       *TrophyScore          `json:"trophies"`      // Only loaded for profiles
+}
+
+// Who deactivated an account. Only accounts users deactivated themselves can
+// be reactivated without an admin.
+const (
+	DeactivatedBySelf  = "self"
+	DeactivatedByAdmin = "admin"
+)
+
+// Profile visible to anyone
+type PublicUser struct {
+	ID             uint32       `json:"id"`
+	Username       string       `json:"username"`
+	Firstname      string       `json:"firstname"`
+	Lastname       string       `json:"lastname"`
+	ImgURL         string       `json:"imgurl"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	TrackedGames   int          `json:"trackedGames"`
+	CompletedGames int          `json:"completedGames"`
+	Trophies       *TrophyScore `json:"trophies,omitempty"`
+}
+
+// Profile visible to the user themselves
+type SelfUser struct {
+	PublicUser
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"emailVerified"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	LastLogin     time.Time `json:"lastLogin"`
+	Role          string    `json:"role"`
+}
+
+// Profile visible to admins
+type AdminUser struct {
+	SelfUser
+	Deactivated   bool   `json:"deactivated"`
+	DeactivatedBy string `json:"deactivatedBy,omitempty"`
 }
 
 type UserStore interface {
@@ -28,6 +72,27 @@ type UserStore interface {
 	CreateUser(User) error
 	EditUser(User) error
 	ChangePassword(id uint, currentPassword, newPassword, confirmNewPassword string) error
+	UpdateLastLogin(id uint32) error
+	SetUserRole(id uint32, role string) error
+	// DeactivateUser deactivates the user, recording whether they did it
+	// themselves or an admin did, as one of the DeactivatedBy constants
+	DeactivateUser(id uint32, by string) error
+	ReactivateUser(id uint32) error
+	ExportUser(id uint32) (*UserExport, error)
+	DeleteUser(id uint32) error
+	SetPassword(id uint32, hashedPassword string) error
+	SetEmailVerified(id uint32) error
+	// GetTrophyScore totals the points of every achievement the user earned
+	GetTrophyScore(id uint32) (*TrophyScore, error)
+}
+
+// Everything stored about a user, handed out before their account is deleted
+type UserExport struct {
+	ExportedAt   time.Time              `json:"exportedAt"`
+	User         SelfUser               `json:"user"`
+	Accounts     []*UserPlatformAccount `json:"accounts"`
+	Games        []*UserGame            `json:"games"`
+	Achievements []*UserAchievement     `json:"achievements"`
 }
 
 type UserPlatformAccount struct {
@@ -39,14 +104,39 @@ type UserPlatformAccount struct {
 
 type UserPlatformAccountStore interface {
 	GetAccountsByUserID(id uint) ([]*UserPlatformAccount, error)
+	// GetAccountByUsername finds the account with username on any platform
+	// sharing the platform's network
+	GetAccountByUsername(platformID uint32, username string) (*UserPlatformAccount, error)
+	GetPlatformByID(id uint32) (*Platform, error)
+	CreateAccount(userID uint, account UserPlatformAccount) (*UserPlatformAccount, error)
+	UpdateAccount(userID uint, account UserPlatformAccount) (*UserPlatformAccount, error)
+	DeleteAccount(userID, accountID uint) error
 	UpdateUserAccounts(userID uint, accounts []*UserPlatformAccount) error
 }
 
 type UpdateAccountsPayload struct {
-	UserID   uint                   `json:"userID" validate:"required"`
+	UserID   uint                   `json:"userID"` // Optional, must match the authenticated user
 	Accounts []*UserPlatformAccount `json:"accounts" validate:"required"`
 }
 
+type AddAccountPayload struct {
+	UserID     uint   `json:"userID"` // Optional, must match the authenticated user
+	PlatformID uint32 `json:"platformID" validate:"required"`
+	Username   string `json:"username" validate:"required,max=255"`
+}
+
+type EditAccountPayload struct {
+	UserID     uint   `json:"userID"` // Optional, must match the authenticated user
+	AccountID  uint   `json:"accountID" validate:"required"`
+	PlatformID uint32 `json:"platformID" validate:"required"`
+	Username   string `json:"username" validate:"required,max=255"`
+}
+
+type RemoveAccountPayload struct {
+	UserID    uint `json:"userID"` // Optional, must match the authenticated user
+	AccountID uint `json:"accountID" validate:"required"`
+}
+
 type RegisterUserPayload struct {
 	Username  string `json:"username" validate:"required,min=4,max=25"`
 	Password  string `json:"password" validate:"required,password"`
@@ -62,7 +152,7 @@ type LoginUserPayload struct {
 }
 
 type EditUserPayload struct {
-	ID        uint32 `json:"id"`
+	ID        uint32 `json:"id"` // Optional, must match the authenticated user
 	Username  string `json:"username"`
 	Firstname string `json:"firstname"`
 	Lastname  string `json:"lastname"`
@@ -70,49 +160,325 @@ type EditUserPayload struct {
 	ImgURL    string `json:"imgurl"`
 }
 
+// Sent by the user to confirm destructive changes to their own account
+type ConfirmPasswordPayload struct {
+	ConfirmPassword string `json:"confirmPassword" validate:"required"`
+}
+
 type ChangePasswordPayload struct {
-	UserID             uint   `json:"userID" validate:"required"`
+	UserID             uint   `json:"userID"` // Optional, must match the authenticated user
 	CurrentPassword    string `json:"currentPassword" validate:"required"`
 	NewPassword        string `json:"newPassword" validate:"required,password"`
 	ConfirmNewPassword string `json:"confirmPassword" validate:"required"`
 }
 
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type VerifyEmailPayload struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordPayload struct {
+	Token              string `json:"token" validate:"required"`
+	NewPassword        string `json:"newPassword" validate:"required"`
+	ConfirmNewPassword string `json:"confirmPassword" validate:"required"`
+}
+
+// Single-use tokens mailed to users, e.g. to verify their email or reset their password
+type UserTokenStore interface {
+	CreateToken(userID uint32, purpose, tokenHash string, expiresAt time.Time) error
+	ConsumeToken(purpose, tokenHash string) (uint32, error)
+	// FindToken returns the user a usable token was issued to without using it up
+	FindToken(purpose, tokenHash string) (uint32, error)
+}
+
+// TWO-FACTOR AUTHENTICATION
+// A TOTP secret enrolled by a user. It only guards logins once confirmed.
+type TwoFactor struct {
+	UserID      uint32     `json:"userID"`
+	Secret      string     `json:"-"`
+	ConfirmedAt *time.Time `json:"confirmedAt"`
+	// The last TOTP time step accepted, so a code can't be used twice
+	LastUsedStep int64     `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func (t *TwoFactor) Enabled() bool {
+	return t != nil && t.ConfirmedAt != nil
+}
+
+type TwoFactorStatus struct {
+	Enabled                bool       `json:"enabled"`
+	ConfirmedAt            *time.Time `json:"confirmedAt"`
+	RecoveryCodesRemaining int        `json:"recoveryCodesRemaining"`
+}
+
+type TwoFactorEnrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type TwoFactorCodePayload struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	Challenge         string `json:"challenge"`
+}
+
+type TwoFactorLoginPayload struct {
+	Challenge string `json:"challenge" validate:"required"`
+	// A code from the authenticator app or an unused recovery code
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorStore interface {
+	// GetTwoFactor returns nil without an error when the user hasn't enrolled
+	GetTwoFactor(userID uint32) (*TwoFactor, error)
+	// SaveTwoFactor starts a new, unconfirmed enrolment, replacing any previous one
+	SaveTwoFactor(userID uint32, secret string) error
+	ConfirmTwoFactor(userID uint32, step int64, recoveryCodeHashes []string) error
+	// SetLastUsedStep records an accepted TOTP step, returning false if it isn't newer than the last one
+	SetLastUsedStep(userID uint32, step int64) (bool, error)
+	ReplaceRecoveryCodes(userID uint32, recoveryCodeHashes []string) error
+	CountRecoveryCodes(userID uint32) (int, error)
+	// UseRecoveryCode marks an unused code as used, returning false if there is none
+	UseRecoveryCode(userID uint32, codeHash string) (bool, error)
+	DeleteTwoFactor(userID uint32) error
+}
+
+// RATE LIMITING
+// Shared state for rate limits and login lockouts
+type RateLimitStore interface {
+	// TakeToken takes a token from the bucket for key, refilled at rate tokens per second up to burst.
+	// When the bucket is empty it returns false and how long until the next token is available.
+	TakeToken(key string, rate float64, burst int, now time.Time) (bool, time.Duration)
+	// ReturnToken puts back a token taken for a request that was rejected by another bucket
+	ReturnToken(key string, burst int)
+	// AddFailure records a failed attempt for key and returns the number of failures since the last reset
+	AddFailure(key string, now time.Time) int
+	GetFailures(key string) (count int, last time.Time)
+	ResetFailures(key string)
+}
+
+// MAIL
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+// SESSION
+type Session struct {
+	ID         uint32     `json:"id"`
+	UserID     uint32     `json:"userID"`
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt time.Time  `json:"lastUsedAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	Current    bool       `json:"current"` // Session the request was made with
+}
+
+// IsActive reports whether the session can still be used to authenticate
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+type SessionStore interface {
+	CreateSession(session Session, tokenHash string) (uint32, error)
+	GetSessionByID(id uint32) (*Session, error)
+	GetSessionByTokenHash(tokenHash string) (*Session, error)
+	GetActiveSessionsByUserID(userID uint32) ([]*Session, error)
+	// RotateSession replaces the refresh token oldTokenHash of a session, and
+	// fails with ErrUnauthorized if it has already been replaced
+	RotateSession(id uint32, oldTokenHash, tokenHash string, expiresAt time.Time) error
+	RevokeSession(id uint32) error
+	RevokeAllSessions(userID uint32) error
+}
+
 // GAME
 type Game struct {
-	ID            uint32     `json:"id"`
-	RAWGID        uint       `json:"rawgID"`
-	Name          string     `json:"name"`
-	Slug          string     `json:"slug"`
-	Description   string     `json:"description"`
-	Platforms     []Platform `json:"platforms"`
-	ReleaseDate   string     `json:"releaseDate"`
-	BackgroundIMG string     `json:"backgroundImg"`
-	Rating        uint       `json:"rating"`
-	Website       string     `json:"website"`
-	Genres        []string   `json:"genres"`
-	CreatedAt     time.Time  `json:"createdAt"`
+	ID            uint32        `json:"id"`
+	RAWGID        uint          `json:"rawgID"`
+	Name          string        `json:"name"`
+	Slug          string        `json:"slug"`
+	Description   string        `json:"description"`
+	Platforms     []Platform    `json:"platforms"`
+	ReleaseDate   string        `json:"releaseDate"`
+	BackgroundIMG string        `json:"backgroundImg"`
+	Rating        uint          `json:"rating"`
+	Website       string        `json:"website"`
+	Genres        []string      `json:"genres"`
+	Screenshots   []Screenshot  `json:"screenshots"`
+	Achievements  []Achievement `json:"achievements"`
+	Stats         *GameStats    `json:"stats"`
+	CreatedAt     time.Time     `json:"createdAt"`
+}
+
+// GameStats counts the users tracking a game and those who have platinumed
+// it, by earning every achievement
+type GameStats struct {
+	Trackers  int `json:"trackers"`
+	Platinums int `json:"platinums"`
 }
 
 type GameStore interface {
 	GetAllGames() ([]*Game, error) // for dev purposes
 	GetGameByID(id uint) (*Game, error)
-	AddGamePlatform(name string, gameID uint32) error
-	AddGameGenre(name string, gameID uint32) error
-	AddGame(game Game) (Game, error)
-	AddAchievement(achievement Achievement) (int32, error)
+	GetGameByRAWGID(rawgID uint) (*Game, error)
+	// SearchGames returns up to limit catalog games whose name or slug matches
+	// query, best matches first, with their platforms and genres
+	SearchGames(query string, filter GameSearchFilter, limit int) ([]*Game, error)
+	GetGamesByRAWGIDs(rawgIDs []uint) ([]*Game, error)
+	GetGamePlatforms(gameID uint32) ([]Platform, error)
+	GetGameGenres(gameID uint32) ([]string, error)
+	GetGameStats(gameID uint32) (*GameStats, error)
+	// ImportGame adds the game to the catalog and tracks it for the user in one
+	// transaction. A game that is already in the catalog is only tracked, and
+	// created reports which happened.
+	ImportGame(game GameImport, userID uint32) (imported *Game, created bool, err error)
+	// ResyncGame updates the game and its platforms, genres and achievements
+	// to match game, freshly fetched from RAWG, in one transaction
+	ResyncGame(gameID uint32, game GameImport) (*ResyncReport, error)
 	AddUserAchievement(userID, gameID, achID uint32) error
+	EditGame(game Game) error
+	DeleteGame(id uint32) error
+}
+
+// GameImport is a game with everything fetched from RAWG to import it
+type GameImport struct {
+	Game         Game
+	Platforms    []string
+	Genres       []string
+	Achievements []Achievement
+	Screenshots  []Screenshot
+}
+
+// What a resync changed about a game
+type ResyncReport struct {
+	GameID                uint32   `json:"gameID"`
+	UpdatedFields         []string `json:"updatedFields"`
+	PlatformsAdded        []string `json:"platformsAdded"`
+	PlatformsRemoved      []string `json:"platformsRemoved"`
+	GenresAdded           []string `json:"genresAdded"`
+	GenresRemoved         []string `json:"genresRemoved"`
+	AchievementsAdded     []string `json:"achievementsAdded"`
+	AchievementsUpdated   []string `json:"achievementsUpdated"`
+	UserAchievementsAdded int      `json:"userAchievementsAdded"` // For the users tracking the game
+	CompletionsReopened   int      `json:"completionsReopened"`   // Users whose completed game gained achievements they haven't earned
+	ScreenshotsAdded      int      `json:"screenshotsAdded"`
+	ScreenshotsRemoved    int      `json:"screenshotsRemoved"`
+}
+
+// Changed reports whether the resync changed anything
+func (r *ResyncReport) Changed() bool {
+	return len(r.UpdatedFields)+len(r.PlatformsAdded)+len(r.PlatformsRemoved)+len(r.GenresAdded)+len(r.GenresRemoved)+
+		len(r.AchievementsAdded)+len(r.AchievementsUpdated) > 0 || r.CompletionsReopened+r.ScreenshotsAdded+r.ScreenshotsRemoved > 0
+}
+
+// GameSearchFilter narrows a search to games on a platform and in a genre,
+// both by name. Empty fields match every game.
+type GameSearchFilter struct {
+	Platform string
+	Genre    string
+}
+
+// GameSearchResult is a game found in the catalog or on RAWG
+type GameSearchResult struct {
+	RAWGID      uint     `json:"rawgID"`
+	GameID      uint32   `json:"gameID,omitempty"` // Only once the game is imported
+	Name        string   `json:"name"`
+	Slug        string   `json:"slug"`
+	CoverURL    string   `json:"coverURL"`
+	ReleaseDate string   `json:"releaseDate"`
+	Platforms   []string `json:"platforms"`
+	Genres      []string `json:"genres"`
+	Imported    bool     `json:"imported"` // In the catalog
+	Tracked     bool     `json:"tracked"`  // Tracked by the user searching
+}
+
+type EditGamePayload struct {
+	Name          string `json:"name" validate:"required,max=255"`
+	Description   string `json:"description"`
+	ReleaseDate   string `json:"releaseDate" validate:"max=50"`
+	BackgroundIMG string `json:"backgroundImg" validate:"omitempty,url,max=255"`
+	Rating        uint   `json:"rating" validate:"max=100"`
+	Website       string `json:"website" validate:"omitempty,url,max=255"`
 }
 
 type RAWGGame struct {
-	ID            uint   `json:"id"`
-	Name          string `json:"name"`
-	BackgroundIMG string `json:"background_image"`
+	ID            uint              `json:"id"`
+	Name          string            `json:"name"`
+	Slug          string            `json:"slug"`
+	Released      string            `json:"released"`
+	BackgroundIMG string            `json:"background_image"`
+	Platforms     []PlatformRequest `json:"platforms"`
+	Genres        []Genre           `json:"genres"`
 }
 
 type RAWGGameResponse struct {
 	Results []RAWGGame `json:"results"`
 }
 
+type RAWGAchievement struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Image       string `json:"image"`
+	Percent     string `json:"percent"` // Such as "84.12"
+}
+
+type RAWGScreenshot struct {
+	ID        uint   `json:"id"`
+	Image     string `json:"image"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	IsDeleted bool   `json:"is_deleted"`
+}
+
+// RAWG CACHE
+// Responses from RAWG, kept so popular searches and games don't cost a request each time
+type RAWGCacheStore interface {
+	// Get returns the value stored for key unless it expired by now
+	Get(key string, now time.Time) (value []byte, ok bool, err error)
+	Set(key string, value []byte, expiresAt time.Time) error
+	Delete(keys ...string) error
+	Clear() error
+}
+
+// RAWGCacheStats counts how requests for one kind of RAWG response were served
+// since the server started
+type RAWGCacheStats struct {
+	Endpoint   string  `json:"endpoint"` // "search", "game", "achievements" or "screenshots"
+	TTLSeconds int64   `json:"ttlSeconds"`
+	Hits       uint64  `json:"hits"`
+	Misses     uint64  `json:"misses"`
+	Bypassed   uint64  `json:"bypassed"`
+	HitRate    float64 `json:"hitRate"` // Hits out of hits and misses, 0 before any
+}
+
 type ReturnSearchGamePayload struct {
 	ID       uint   `json:"id"`
 	Name     string `json:"name"`
@@ -203,6 +569,22 @@ type Platform struct {
 	ReleaseYear string `json:"releaseYear"`
 }
 
+// SCREENSHOT
+type Screenshot struct {
+	ID     uint32 `json:"id"`
+	GameID uint32 `json:"gameID"`
+	RAWGID uint32 `json:"rawgID"`
+	ImgURL string `json:"image"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// ScreenshotStore reads a game's screenshots. They are written along with the
+// game by GameStore.ImportGame and GameStore.ResyncGame.
+type ScreenshotStore interface {
+	GetScreenshotsByGame(gameID uint32) ([]*Screenshot, error)
+}
+
 // USER GAME
 type UserGame struct {
 	ID          uint32    `json:"id"`
@@ -216,35 +598,134 @@ type UserGame struct {
 type UserGameStore interface {
 	GetAllUserGames(userID uint32) ([]*UserGame, error)
 	GetUserGameByID(userID, gameID uint32) (*UserGame, error)
+	// GetAllGameProgress lists the games the user tracks, most recently played first
+	GetAllGameProgress(userID uint32) ([]*GameProgress, error)
+	GetGameProgress(userID, gameID uint32) (*GameProgress, error)
+	GetAchievementProgress(userID, gameID uint32) ([]*AchievementProgress, error)
+	// GetRarestUnlocks lists up to limit achievements the user earned of known
+	// rarity, rarest first
+	GetRarestUnlocks(userID uint32, limit int) ([]*RareUnlock, error)
 	TrackGame(userID, gameID uint32) error
 	UntrackGame(userID, gameID uint32) error
 }
 
+// GameProgress is how far a user is through a game they track
+type GameProgress struct {
+	GameID         uint32     `json:"gameID"`
+	Name           string     `json:"name"`
+	Slug           string     `json:"slug"`
+	BackgroundIMG  string     `json:"backgroundImg"`
+	Earned         int        `json:"earned"`
+	Total          int        `json:"total"`
+	Percent        float64    `json:"percent"` // Earned out of total, from 0 to 100
+	LastUnlockedAt *time.Time `json:"lastUnlockedAt"`
+	Platinum       bool       `json:"platinum"` // Every achievement earned
+	CompletedAt    *time.Time `json:"completedAt"`
+	TrackedAt      time.Time  `json:"trackedAt"`
+}
+
+// AchievementProgress is one of a game's achievements and whether the user
+// has earned it
+type AchievementProgress struct {
+	Achievement
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completedAt"`
+}
+
+// GameProgressDetail is a user's progress through a game with every achievement
+type GameProgressDetail struct {
+	GameProgress
+	Grades       []GradeProgress       `json:"grades"` // Bronze to platinum
+	Achievements []AchievementProgress `json:"achievements"`
+}
+
 type TrackGamePayload struct {
-	UserID uint32 `json:"userID"`
+	UserID uint32 `json:"userID"` // Optional, must match the authenticated user
 	GameID uint32 `json:"gameID"`
 }
 
 // Achievement
 type Achievement struct {
-	ID          uint32 `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	ImgURL      string `json:"image"`
-	Percent     string `json:"percent"`
-	GameID      uint   `json:"gameID"`
+	ID          uint32   `json:"id"`
+	RAWGID      uint32   `json:"rawgID"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	ImgURL      string   `json:"image"`
+	Percent     *float64 `json:"percent"` // Of players who earned it, null when unknown
+	Rarity      string   `json:"rarity"`  // One of the Rarity constants, empty when the percent is unknown
+	Grade       string   `json:"grade"`   // One of the Grade constants
+	GameID      uint     `json:"gameID"`
+}
+
+// Rarity tiers, by the percent of players who earned an achievement
+const (
+	RarityCommon    = "common"     // 50% and up
+	RarityUncommon  = "uncommon"   // Under 50%
+	RarityRare      = "rare"       // Under 20%
+	RarityVeryRare  = "very_rare"  // Under 10%
+	RarityUltraRare = "ultra_rare" // Under 5%
+)
+
+// RareUnlock is an achievement a user earned, with the game it's from
+type RareUnlock struct {
+	Achievement
+	GameName    string    `json:"gameName"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+// Trophy grades, from the most common to the platinum for earning every other
+const (
+	GradeBronze   = "bronze"
+	GradeSilver   = "silver"
+	GradeGold     = "gold"
+	GradePlatinum = "platinum"
+)
+
+var Grades = []string{GradeBronze, GradeSilver, GradeGold, GradePlatinum}
+
+// TrophyScore is what a user's earned achievements are worth
+type TrophyScore struct {
+	Points   int `json:"points"`
+	Level    int `json:"level"`    // From 1 to 999, as on PSN
+	Progress int `json:"progress"` // Percent of the way to the next level
+	Bronze   int `json:"bronze"`
+	Silver   int `json:"silver"`
+	Gold     int `json:"gold"`
+	Platinum int `json:"platinum"`
+}
+
+// GradeProgress is how many of a game's achievements of a grade a user earned
+type GradeProgress struct {
+	Grade  string `json:"grade"`
+	Earned int    `json:"earned"`
+	Total  int    `json:"total"`
+	Points int    `json:"points"` // Earned
+}
+
+type SetGradePayload struct {
+	Grade string `json:"grade" validate:"required,oneof=bronze silver gold platinum"`
 }
 
 type AchievementStore interface {
-	CompleteAchievement(userID, achievementID uint32) error
+	// CompleteAchievements marks the user's achievements earned at completedAt,
+	// or now when it is nil, and platinums their games once every achievement is
+	CompleteAchievements(userID uint32, achievementIDs []uint32, completedAt *time.Time) error
+	UncompleteAchievement(userID, achievementID uint32) error
+	SetAchievementGrade(id uint32, grade string) error
+	// ImportAchievements marks the user's achievements earned at each unlock's
+	// own time in one transaction
+	ImportAchievements(userID uint32, unlocks []AchievementUnlock) error
 	GetAllAchievementsByGame(gameID uint32) ([]*Achievement, error)
+	// GetAchievementsByRarity lists the game's achievements rarest first, or
+	// most common first, with those of unknown rarity last
+	GetAchievementsByRarity(gameID uint32, rarestFirst bool) ([]*Achievement, error)
 }
 
 type AddAchievementPayload struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	ImgURL      string  `json:"imgurl"`
-	Percent     float32 `json:"percent"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	ImgURL      string   `json:"imgurl"`
+	Percent     *float64 `json:"percent" validate:"omitempty,min=0,max=100"`
 }
 
 // USER ACHIEVEMENT
@@ -271,6 +752,168 @@ type CreateUserAchievementPayload struct {
 }
 
 type CompletedUserAchievementPayload struct {
-	UserID            uint32 `json:"userID"`
+	UserID        uint32     `json:"userID"` // Optional, must match the authenticated user
+	AchievementID uint32     `json:"achievementID"`
+	CompletedAt   *time.Time `json:"completedAt"` // Optional, when the achievement was really earned
+}
+
+// AchievementUnlock is an achievement earned at CompletedAt, or at an unknown
+// time when it is nil
+type AchievementUnlock struct {
+	AchievementID uint32
+	CompletedAt   *time.Time
+}
+
+type CompleteAchievementsPayload struct {
+	UserID         uint32     `json:"userID"` // Optional, must match the authenticated user
+	AchievementIDs []uint32   `json:"achievementIDs" validate:"required,min=1,max=500,dive,required"`
+	CompletedAt    *time.Time `json:"completedAt"` // Optional, when the achievements were really earned
+}
+
+type UncompleteAchievementPayload struct {
+	UserID        uint32 `json:"userID"` // Optional, must match the authenticated user
+	AchievementID uint32 `json:"achievementID" validate:"required"`
+}
+
+// JOBS
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+const (
+	// JobImportGame imports the game RAWGID from RAWG and tracks it for UserID
+	JobImportGame = "import_game"
+	// JobResyncGame refreshes the imported game RAWGID from RAWG
+	JobResyncGame = "resync_game"
+)
+
+// A unit of background work, such as importing a game from RAWG
+type Job struct {
+	ID                   uint32          `json:"id"`
+	Kind                 string          `json:"kind"`
+	UserID               uint32          `json:"userID,omitempty"`
+	RAWGID               uint            `json:"rawgID"`
+	Status               JobStatus       `json:"status"`
+	Attempts             int             `json:"attempts"`
+	PagesFetched         int             `json:"pagesFetched"`
+	AchievementsInserted int             `json:"achievementsInserted"`
+	GameID               uint32          `json:"gameID,omitempty"` // Set once the job has succeeded
+	Error                string          `json:"error,omitempty"`  // Why the last attempt failed
+	Result               json.RawMessage `json:"result,omitempty"` // What the job did, e.g. a ResyncReport
+	RunAt                time.Time       `json:"runAt"`
+	CreatedAt            time.Time       `json:"createdAt"`
+	UpdatedAt            time.Time       `json:"updatedAt"`
+}
+
+type JobStore interface {
+	CreateJob(job Job) (*Job, error)
+	GetJobByID(id uint32) (*Job, error)
+	// GetPendingRAWGIDs returns the RAWG IDs of queued or running jobs of a kind
+	GetPendingRAWGIDs(kind string) (map[uint]bool, error)
+	// ClaimJob marks the next queued job due by now as running and returns it,
+	// or nil if there is none. Each claim counts as an attempt.
+	ClaimJob(now time.Time) (*Job, error)
+	UpdateJobProgress(id uint32, pagesFetched, achievementsInserted int) error
+	// TouchJob marks a running job as still alive
+	TouchJob(id uint32) error
+	CompleteJob(id, gameID uint32, achievementsInserted int, result json.RawMessage) error
+	// RetryJob queues the job again to run at runAt
+	RetryJob(id uint32, runAt time.Time, reason string) error
+	FailJob(id uint32, reason string) error
+	// RequeueStaleJobs queues running jobs not updated for staleAfter, which a
+	// server that stopped left behind. Jobs other servers are running are left
+	// alone, and those already attempted maxAttempts times fail instead.
+	RequeueStaleJobs(staleAfter time.Duration, maxAttempts int) error
+}
+
+type JobQueue interface {
+	Enqueue(job Job) (*Job, error)
+}
+
+// PROGRESS IMPORT
+// ImportRow is an unlock read from an offline export file
+type ImportRow struct {
+	Line        int        `json:"line"`
+	Game        string     `json:"game"`
+	Achievement string     `json:"achievement"`
+	UnlockedAt  *time.Time `json:"unlockedAt"` // Null when the file doesn't say
+}
+
+type ImportMatch struct {
+	ImportRow
+	GameID        uint32 `json:"gameID"`
 	AchievementID uint32 `json:"achievementID"`
 }
+
+type ImportMiss struct {
+	ImportRow
+	Reason string `json:"reason"`
+}
+
+// ImportReport lists which rows of an import matched a tracked game's
+// achievement, and why the others didn't
+type ImportReport struct {
+	Format    string        `json:"format"`
+	DryRun    bool          `json:"dryRun"`
+	Rows      int           `json:"rows"`
+	Matched   []ImportMatch `json:"matched"`
+	Unmatched []ImportMiss  `json:"unmatched"`
+}
+
+// LEADERBOARD
+const (
+	// LeaderboardPlatinums ranks users by games platinumed
+	LeaderboardPlatinums = "platinums"
+	// LeaderboardTrophies ranks users by achievements earned
+	LeaderboardTrophies = "trophies"
+	// LeaderboardFastest ranks users by how soon after tracking a game they
+	// platinumed it
+	LeaderboardFastest = "fastest"
+)
+
+// Time windows a leaderboard counts, by when things were earned
+const (
+	WindowMonth = "month"
+	WindowYear  = "year"
+	WindowAll   = "all"
+)
+
+// LeaderboardQuery picks a leaderboard. GameID is only for LeaderboardFastest,
+// and Since leaves out what was earned before it unless nil.
+type LeaderboardQuery struct {
+	Board  string
+	GameID uint32
+	Since  *time.Time
+}
+
+// LeaderboardEntry is a user's place on a leaderboard. Users who tie share a
+// rank, listed by who got there first.
+type LeaderboardEntry struct {
+	Rank     int    `json:"rank"`
+	UserID   uint32 `json:"userID"`
+	Username string `json:"username"`
+	ImgURL   string `json:"imgurl"`
+	Value    int64  `json:"value"` // Platinums, trophies or seconds to platinum
+}
+
+type Leaderboard struct {
+	Board   string             `json:"board"`
+	Window  string             `json:"window"`
+	GameID  uint32             `json:"gameID,omitempty"`
+	Total   int                `json:"total"` // Users ranked, across every page
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+// LeaderboardStore ranks active users, deactivated ones are left out
+type LeaderboardStore interface {
+	// GetLeaderboard returns a page of the leaderboard and how many users it ranks
+	GetLeaderboard(q LeaderboardQuery, limit, offset int) ([]*LeaderboardEntry, int, error)
+	GetLeaderboardEntry(q LeaderboardQuery, userID uint32) (*LeaderboardEntry, error)
+}
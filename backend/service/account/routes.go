@@ -1,72 +1,211 @@
 package account
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
 	"github.com/ajtroup1/platinum-trophy-tracker/utils"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
 type Handler struct {
-	store models.UserPlatformAccountStore
+	store     models.UserPlatformAccountStore
+	userStore models.UserStore
 }
 
-func NewHandler(store models.UserPlatformAccountStore) *Handler {
-	return &Handler{store: store}
+func NewHandler(store models.UserPlatformAccountStore, userStore models.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/accounts/{id:[0-9]+}", h.handleGetUserByID).Methods("GET")
-	router.HandleFunc("/update-user-accounts", h.handleUpdateAccounts).Methods("PUT")
+	router.HandleFunc("/accounts/{id:[0-9]+}", utils.MakeHandler(h.handleGetUserByID)).Methods("GET")
+	router.HandleFunc("/accounts/lookup", utils.MakeHandler(h.handleLookupAccount)).Methods("GET")
+	router.HandleFunc("/update-user-accounts", utils.MakeHandler(h.handleUpdateAccounts)).Methods("PUT")
+	router.HandleFunc("/add-account", utils.MakeHandler(h.handleAddAccount)).Methods("POST")
+	router.HandleFunc("/edit-account", utils.MakeHandler(h.handleEditAccount)).Methods("PUT")
+	router.HandleFunc("/remove-account", utils.MakeHandler(h.handleRemoveAccount)).Methods("DELETE")
 }
 
-func (h *Handler) handleGetUserByID(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleGetUserByID(w http.ResponseWriter, r *http.Request) error {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid user id: %v", err))
-		return
+		return utils.Invalid("invalid user id: %v", err)
 	}
 
 	u, err := h.store.GetAccountsByUserID(uint(id))
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		return err
 	}
 
-	utils.WriteJSON(w, http.StatusOK, u)
+	return utils.WriteJSON(w, http.StatusOK, u)
 }
 
-func (h *Handler) handleUpdateAccounts(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleUpdateAccounts(w http.ResponseWriter, r *http.Request) error {
 	var payload models.UpdateAccountsPayload
-
-	// Decode the request body into the payload struct
-	err := json.NewDecoder(r.Body).Decode(&payload)
-	if err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
-		return
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
 	}
 
 	// Validate the payload
 	if err := utils.Validate.Struct(payload); err != nil {
-		errors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
-		return
+		return utils.ValidationError(err)
+	}
+
+	userID, err := auth.ActingUserID(r, uint32(payload.UserID))
+	if err != nil {
+		return err
+	}
+
+	for _, account := range payload.Accounts {
+		if account == nil {
+			return utils.Invalid("accounts can't contain null")
+		}
+		account.Username, err = h.checkUsername(account.PlatformID, account.Username)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Update user accounts
-	err = h.store.UpdateUserAccounts(payload.UserID, payload.Accounts)
+	err = h.store.UpdateUserAccounts(uint(userID), payload.Accounts)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to update user accounts: %v", err))
-		return
+		return fmt.Errorf("failed to update user accounts: %w", err)
 	}
 
 	// Send success response
-	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Successfully updated user accounts"})
+	return utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Successfully updated user accounts"})
+}
+
+func (h *Handler) handleAddAccount(w http.ResponseWriter, r *http.Request) error {
+	var payload models.AddAccountPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
+	}
+
+	userID, err := auth.ActingUserID(r, uint32(payload.UserID))
+	if err != nil {
+		return err
+	}
+
+	username, err := h.checkUsername(payload.PlatformID, payload.Username)
+	if err != nil {
+		return err
+	}
+
+	account, err := h.store.CreateAccount(uint(userID), models.UserPlatformAccount{Username: username, PlatformID: payload.PlatformID})
+	if err != nil {
+		return fmt.Errorf("failed to add account: %w", err)
+	}
+
+	return utils.WriteJSON(w, http.StatusCreated, account)
+}
+
+func (h *Handler) handleEditAccount(w http.ResponseWriter, r *http.Request) error {
+	var payload models.EditAccountPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
+	}
+
+	userID, err := auth.ActingUserID(r, uint32(payload.UserID))
+	if err != nil {
+		return err
+	}
+
+	username, err := h.checkUsername(payload.PlatformID, payload.Username)
+	if err != nil {
+		return err
+	}
+
+	account, err := h.store.UpdateAccount(uint(userID), models.UserPlatformAccount{ID: payload.AccountID, Username: username, PlatformID: payload.PlatformID})
+	if err != nil {
+		return fmt.Errorf("failed to edit account: %w", err)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, account)
+}
+
+func (h *Handler) handleRemoveAccount(w http.ResponseWriter, r *http.Request) error {
+	var payload models.RemoveAccountPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
+	}
+
+	userID, err := auth.ActingUserID(r, uint32(payload.UserID))
+	if err != nil {
+		return err
+	}
+
+	if err := h.store.DeleteAccount(uint(userID), payload.AccountID); err != nil {
+		return fmt.Errorf("failed to remove account: %w", err)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+// handleLookupAccount finds who holds a username on a platform's network. Like
+// profiles, accounts of deactivated users are only found by admins.
+func (h *Handler) handleLookupAccount(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	platformID, err := strconv.ParseUint(query.Get("platformID"), 10, 32)
+	if err != nil {
+		return utils.Invalid("invalid platform id: %v", err)
+	}
+	username := strings.TrimSpace(query.Get("username"))
+	if username == "" {
+		return utils.Invalid("username is required")
+	}
+
+	account, err := h.store.GetAccountByUsername(uint32(platformID), username)
+	if err != nil {
+		return err
+	}
+
+	u, err := h.userStore.GetUserByID(int(account.UserID))
+	if err != nil {
+		return err
+	}
+	if u.Deactivated && !auth.GetRoleFromContext(r.Context()).Includes(auth.RoleAdmin) {
+		return utils.NotFound("no account '%s' found for platform '%d'", username, platformID)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, account)
+}
+
+// checkUsername trims the username and checks that it fits the platform
+func (h *Handler) checkUsername(platformID uint32, username string) (string, error) {
+	platform, err := h.store.GetPlatformByID(platformID)
+	if errors.Is(err, utils.ErrNotFound) {
+		return "", utils.Invalid("platform '%d' doesn't exist", platformID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	username = strings.TrimSpace(username)
+	if err := validateUsername(platform.Name, username); err != nil {
+		return "", err
+	}
+
+	return username, nil
 }
@@ -2,18 +2,22 @@ package account
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
 	"github.com/gorilla/mux"
 )
 
 func TestAccounts(t *testing.T) {
 	accountStore := &mockAccountStore{}
-	handler := NewHandler(accountStore)
+	handler := NewHandler(accountStore, nil)
 
 	t.Run("should fail if account payload is invalid", func(t *testing.T) {
 		payload := models.UpdateAccountsPayload{
@@ -30,7 +34,7 @@ func TestAccounts(t *testing.T) {
 		rr := httptest.NewRecorder()
 		router := mux.NewRouter()
 
-		router.HandleFunc("/update-user-accounts", handler.handleUpdateAccounts)
+		router.HandleFunc("/update-user-accounts", utils.MakeHandler(handler.handleUpdateAccounts))
 
 		router.ServeHTTP(rr, req)
 
@@ -62,11 +66,12 @@ func TestAccounts(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, uint32(1)))
 
 		rr := httptest.NewRecorder()
 		router := mux.NewRouter()
 
-		router.HandleFunc("/update-user-accounts", handler.handleUpdateAccounts)
+		router.HandleFunc("/update-user-accounts", utils.MakeHandler(handler.handleUpdateAccounts))
 
 		router.ServeHTTP(rr, req)
 
@@ -74,13 +79,236 @@ func TestAccounts(t *testing.T) {
 			t.Errorf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
 		}
 	})
+
+	t.Run("should fail when updating another user's accounts", func(t *testing.T) {
+		payload := models.UpdateAccountsPayload{
+			UserID: 2,
+			Accounts: []*models.UserPlatformAccount{
+				{
+					Username:   "testuser1",
+					PlatformID: 1,
+				},
+			},
+		}
+		marshal, _ := json.Marshal(payload)
+
+		req, err := http.NewRequest(http.MethodPut, "/update-user-accounts", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, uint32(1)))
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/update-user-accounts", utils.MakeHandler(handler.handleUpdateAccounts))
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d. Response body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+		}
+	})
 }
 
-type mockAccountStore struct{}
+func TestAccountRules(t *testing.T) {
+	accountStore := &mockAccountStore{
+		accounts: []*models.UserPlatformAccount{
+			{ID: 1, UserID: 2, Username: "KratosGoW", PlatformID: 4},
+			{ID: 2, UserID: 3, Username: "Gone_User", PlatformID: 5},
+		},
+	}
+	users := &mockUserStore{users: map[int]*models.User{
+		1: {ID: 1},
+		2: {ID: 2},
+		3: {ID: 3, Deactivated: true},
+	}}
+	handler := NewHandler(accountStore, users)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	send := func(method, path string, payload interface{}) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatal(err)
+			}
+		}
+		req, err := http.NewRequest(method, path, &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, uint32(1)))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should add an account that fits the platform", func(t *testing.T) {
+		rr := send(http.MethodPost, "/add-account", models.AddAccountPayload{PlatformID: 14, Username: " 76561197960287930 "})
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+
+		var account models.UserPlatformAccount
+		if err := json.NewDecoder(rr.Body).Decode(&account); err != nil {
+			t.Fatal(err)
+		}
+		if account.UserID != 1 || account.Username != "76561197960287930" {
+			t.Errorf("unexpected account %+v", account)
+		}
+	})
+
+	t.Run("should fail for usernames that don't fit the platform", func(t *testing.T) {
+		for _, payload := range []models.AddAccountPayload{
+			{PlatformID: 14, Username: "gaben"},
+			{PlatformID: 4, Username: "1stPlayer"},
+			{PlatformID: 8, Username: "Master  Chief"},
+		} {
+			if rr := send(http.MethodPost, "/add-account", payload); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %+v, got %d", http.StatusBadRequest, payload, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should fail for unknown platforms", func(t *testing.T) {
+		rr := send(http.MethodPost, "/add-account", models.AddAccountPayload{PlatformID: 99, Username: "anyone"})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should fail for a PSN ID claimed by another user", func(t *testing.T) {
+		rr := send(http.MethodPost, "/add-account", models.AddAccountPayload{PlatformID: 5, Username: "kratosgow"})
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("should only remove the user's own accounts", func(t *testing.T) {
+		rr := send(http.MethodDelete, "/remove-account", models.RemoveAccountPayload{AccountID: 1})
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should look up who holds an account on the platform's network", func(t *testing.T) {
+		rr := send(http.MethodGet, "/accounts/lookup?platformID=5&username=KratosGoW", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var account models.UserPlatformAccount
+		if err := json.NewDecoder(rr.Body).Decode(&account); err != nil {
+			t.Fatal(err)
+		}
+		if account.UserID != 2 {
+			t.Errorf("expected user 2, got %+v", account)
+		}
+	})
+
+	t.Run("should not find deactivated users' accounts", func(t *testing.T) {
+		if rr := send(http.MethodGet, "/accounts/lookup?platformID=5&username=Gone_User", nil); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		platform string
+		username string
+		valid    bool
+	}{
+		{"PlayStation 5", "Kratos_GoW-2018", true},
+		{"PlayStation 4", "ab", false},
+		{"PlayStation 3", "1stPlayer", false},
+		{"PlayStation 5", "has space", false},
+		{"Xbox One", "Master Chief", true},
+		{"Xbox Series X", "MasterChief#117", true},
+		{"Xbox 360", "Master  Chief", false},
+		{"Xbox", "ThisGamertagIsTooLong", false},
+		{"PC", "76561197960287930", true},
+		{"PC", "7656119796028793", false},
+		{"PC", "gaben", false},
+		{"Switch", "Any name at all", true},
+	}
+
+	for _, tt := range tests {
+		err := validateUsername(tt.platform, tt.username)
+		if (err == nil) != tt.valid {
+			t.Errorf("validateUsername(%q, %q) = %v, expected valid to be %v", tt.platform, tt.username, err, tt.valid)
+		}
+	}
+}
+
+var platforms = map[uint32]string{
+	1: "PlayStation", 2: "PlayStation 2", 4: "PlayStation 4", 5: "PlayStation 5", 8: "Xbox One", 14: "PC",
+}
+
+type mockAccountStore struct {
+	accounts []*models.UserPlatformAccount
+}
 
 func (s *mockAccountStore) GetAccountsByUserID(id uint) ([]*models.UserPlatformAccount, error) {
 	return nil, nil
 }
+
+func (s *mockAccountStore) GetAccountByUsername(platformID uint32, username string) (*models.UserPlatformAccount, error) {
+	for _, a := range s.accounts {
+		if networkOf(platforms[a.PlatformID]) == networkOf(platforms[platformID]) && strings.EqualFold(a.Username, username) {
+			return a, nil
+		}
+	}
+	return nil, utils.NotFound("no account '%s' found for platform '%d'", username, platformID)
+}
+
+func (s *mockAccountStore) GetPlatformByID(id uint32) (*models.Platform, error) {
+	name, ok := platforms[id]
+	if !ok {
+		return nil, utils.NotFound("platform not found with id '%d'", id)
+	}
+	return &models.Platform{ID: uint(id), Name: name}, nil
+}
+
+func (s *mockAccountStore) CreateAccount(userID uint, account models.UserPlatformAccount) (*models.UserPlatformAccount, error) {
+	if owner, err := s.GetAccountByUsername(account.PlatformID, account.Username); err == nil && owner.UserID != userID {
+		return nil, utils.Conflict("account '%s' is already claimed by another user", account.Username)
+	}
+	account.ID = uint(len(s.accounts) + 1)
+	account.UserID = userID
+	s.accounts = append(s.accounts, &account)
+	return &account, nil
+}
+
+func (s *mockAccountStore) UpdateAccount(userID uint, account models.UserPlatformAccount) (*models.UserPlatformAccount, error) {
+	return &account, nil
+}
+
+func (s *mockAccountStore) DeleteAccount(userID, accountID uint) error {
+	for i, a := range s.accounts {
+		if a.ID == accountID && a.UserID == userID {
+			s.accounts = append(s.accounts[:i], s.accounts[i+1:]...)
+			return nil
+		}
+	}
+	return utils.NotFound("account not found with id '%d'", accountID)
+}
+
 func (s *mockAccountStore) UpdateUserAccounts(userID uint, accounts []*models.UserPlatformAccount) error {
 	return nil
 }
+
+type mockUserStore struct {
+	models.UserStore
+	users map[int]*models.User
+}
+
+func (s *mockUserStore) GetUserByID(id int) (*models.User, error) {
+	u, ok := s.users[id]
+	if !ok {
+		return nil, utils.NotFound("user not found with id '%d'", id)
+	}
+	return u, nil
+}
@@ -0,0 +1,65 @@
+package account
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+)
+
+// Platforms sign in with the account of their network, so one PSN online ID is
+// the same account on every PlayStation. Usernames are unique per network.
+const (
+	networkPSN   = "PSN"
+	networkXbox  = "Xbox"
+	networkSteam = "Steam"
+)
+
+var (
+	// 3 to 16 letters, digits, hyphens and underscores, starting with a letter
+	psnOnlineID = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{2,15}$`)
+	// Up to 15 letters, digits and single spaces, starting with a letter, with
+	// the optional number suffix of newer gamertags
+	xboxGamertag = regexp.MustCompile(`^[A-Za-z](?:[A-Za-z0-9]| [A-Za-z0-9]){0,14}(?:#[0-9]{1,4})?$`)
+	// 17 digits in the individual account range
+	steamID64 = regexp.MustCompile(`^7656119[0-9]{10}$`)
+)
+
+// networkOf returns the account network a platform signs in with. Platforms
+// without one of their own are their own network.
+func networkOf(platform string) string {
+	switch {
+	case strings.HasPrefix(platform, "PlayStation"):
+		return networkPSN
+	case strings.HasPrefix(platform, "Xbox"):
+		return networkXbox
+	case platform == "PC":
+		return networkSteam
+	default:
+		return platform
+	}
+}
+
+// validateUsername checks that username could be an account on the platform
+func validateUsername(platform, username string) error {
+	if username == "" || len(username) > 255 {
+		return utils.Invalid("username must be between 1 and 255 characters")
+	}
+
+	switch networkOf(platform) {
+	case networkPSN:
+		if !psnOnlineID.MatchString(username) {
+			return utils.Invalid("'%s' isn't a PSN online ID: 3 to 16 letters, digits, '-' or '_', starting with a letter", username)
+		}
+	case networkXbox:
+		if !xboxGamertag.MatchString(username) {
+			return utils.Invalid("'%s' isn't an Xbox gamertag: up to 15 letters, digits or single spaces, starting with a letter", username)
+		}
+	case networkSteam:
+		if !steamID64.MatchString(username) {
+			return utils.Invalid("'%s' isn't a Steam ID64: 17 digits starting with 7656119", username)
+		}
+	}
+
+	return nil
+}
@@ -2,8 +2,10 @@ package account
 
 import (
 	"database/sql"
+	"strings"
 
 	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
 )
 
 type Store struct {
@@ -33,16 +35,130 @@ func (s *Store) GetAccountsByUserID(id uint) ([]*models.UserPlatformAccount, err
 	return accounts, nil
 }
 
-func (s *Store) CreateAccount(userID uint, account models.UserPlatformAccount) error {
-	_, err := s.db.Exec("INSERT INTO accounts (user_id, username, platform_id) VALUES (?, ?, ?)",
+// GetAccountByUsername finds the account with username on any platform sharing
+// the platform's network
+func (s *Store) GetAccountByUsername(platformID uint32, username string) (*models.UserPlatformAccount, error) {
+	_, ids, err := networkPlatformIDs(s.db, platformID)
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]interface{}{username}, ids...)
+	row := s.db.QueryRow("SELECT id, user_id, username, platform_id FROM accounts WHERE username = ? AND platform_id IN ("+placeholders(len(ids))+") ORDER BY id LIMIT 1", args...)
+
+	var account models.UserPlatformAccount
+	err = row.Scan(&account.ID, &account.UserID, &account.Username, &account.PlatformID)
+	if err == sql.ErrNoRows {
+		return nil, utils.NotFound("no account '%s' found for platform '%d'", username, platformID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+func (s *Store) GetPlatformByID(id uint32) (*models.Platform, error) {
+	var platform models.Platform
+	var imgURL, releaseYear sql.NullString
+	err := s.db.QueryRow("SELECT id, name, imgurl, release_year FROM platforms WHERE id = ?", id).
+		Scan(&platform.ID, &platform.Name, &imgURL, &releaseYear)
+	if err == sql.ErrNoRows {
+		return nil, utils.NotFound("platform not found with id '%d'", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	platform.ImgURL = imgURL.String
+	platform.ReleaseYear = releaseYear.String
+
+	return &platform, nil
+}
+
+func (s *Store) CreateAccount(userID uint, account models.UserPlatformAccount) (*models.UserPlatformAccount, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := claim(tx, userID, account); err != nil {
+		return nil, err
+	}
+
+	res, err := tx.Exec("INSERT INTO accounts (user_id, username, platform_id) VALUES (?, ?, ?)",
 		userID, account.Username, account.PlatformID)
+	if err != nil {
+		return nil, duplicateAccount(err, account)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	account.ID = uint(id)
+	account.UserID = userID
+	return &account, nil
+}
+
+// UpdateAccount changes the username or platform of one of the user's accounts
+func (s *Store) UpdateAccount(userID uint, account models.UserPlatformAccount) (*models.UserPlatformAccount, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id uint
+	err = tx.QueryRow("SELECT id FROM accounts WHERE id = ? AND user_id = ? FOR UPDATE", account.ID, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, utils.NotFound("account not found with id '%d'", account.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := claim(tx, userID, account); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE accounts SET username = ?, platform_id = ? WHERE id = ?",
+		account.Username, account.PlatformID, account.ID)
+	if err != nil {
+		return nil, duplicateAccount(err, account)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	account.UserID = userID
+	return &account, nil
+}
+
+func (s *Store) DeleteAccount(userID, accountID uint) error {
+	res, err := s.db.Exec("DELETE FROM accounts WHERE id = ? AND user_id = ?", accountID, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return utils.NotFound("account not found with id '%d'", accountID)
+	}
 
 	return nil
 }
 
+// UpdateUserAccounts replaces every account of the user
 func (s *Store) UpdateUserAccounts(userID uint, accounts []*models.UserPlatformAccount) error {
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -56,16 +172,90 @@ func (s *Store) UpdateUserAccounts(userID uint, accounts []*models.UserPlatformA
 	}
 
 	for _, account := range accounts {
+		if err := claim(tx, userID, *account); err != nil {
+			return err
+		}
+
 		_, err = tx.Exec("INSERT INTO accounts (user_id, username, platform_id) VALUES (?, ?, ?)",
 			userID, account.Username, account.PlatformID)
 		if err != nil {
-			return err
+			return duplicateAccount(err, *account)
 		}
 	}
 
 	return tx.Commit()
 }
 
+// claim fails if another user already holds the username on the account's
+// network, such as the same PSN online ID on a different PlayStation
+func claim(tx *sql.Tx, userID uint, account models.UserPlatformAccount) error {
+	network, ids, err := networkPlatformIDs(tx, account.PlatformID)
+	if err != nil {
+		return err
+	}
+
+	args := append([]interface{}{account.Username, userID}, ids...)
+	var owner uint
+	err = tx.QueryRow("SELECT user_id FROM accounts WHERE username = ? AND user_id != ? AND platform_id IN ("+placeholders(len(ids))+") LIMIT 1 FOR UPDATE", args...).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return utils.Conflict("%s account '%s' is already claimed by another user", network, account.Username)
+}
+
+// networkPlatformIDs returns the network of the platform and every platform on it
+func networkPlatformIDs(q interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, platformID uint32) (string, []interface{}, error) {
+	rows, err := q.Query("SELECT id, name FROM platforms")
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	networks := make(map[uint32]string)
+	for rows.Next() {
+		var id uint32
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return "", nil, err
+		}
+		networks[id] = networkOf(name)
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, err
+	}
+
+	network, ok := networks[platformID]
+	if !ok {
+		return "", nil, utils.NotFound("platform not found with id '%d'", platformID)
+	}
+
+	var ids []interface{}
+	for id, n := range networks {
+		if n == network {
+			ids = append(ids, id)
+		}
+	}
+
+	return network, ids, nil
+}
+
+func duplicateAccount(err error, account models.UserPlatformAccount) error {
+	if utils.IsDuplicateEntry(err) {
+		return utils.Conflict("account '%s' is already added for platform '%d'", account.Username, account.PlatformID)
+	}
+	return err
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
 func scanRowsIntoAccounts(rows *sql.Rows, accounts *[]*models.UserPlatformAccount) error {
 	for rows.Next() {
 		var account models.UserPlatformAccount
@@ -0,0 +1,122 @@
+package achievement
+
+import (
+	"regexp"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+)
+
+// Points each grade is worth, as on PSN
+var gradePoints = map[string]int{
+	models.GradeBronze:   15,
+	models.GradeSilver:   30,
+	models.GradeGold:     90,
+	models.GradePlatinum: 300,
+}
+
+// Points per level, by hundreds of levels, as on PSN since 2020. Levels go
+// from 1 to 999.
+var levelTiers = []int{60, 90, 450, 900, 1350, 1800, 2250, 2700, 3150, 3600}
+
+const maxLevel = 999
+
+// Achievements for earning every other one, which RAWG doesn't tell apart
+var platinumPattern = regexp.MustCompile(`(?i)platinum|\b(all|every)( of the)?( other)? (trophies|achievements)\b`)
+
+// InferGrades grades achievements fetched from RAWG, which has no grades. The
+// rarest achievement that reads like a platinum is the platinum, the others
+// are graded by how few players earned them.
+func InferGrades(achievements []models.Achievement) {
+	platinum := -1
+	for i, a := range achievements {
+		achievements[i].Grade = gradeByPercent(a.Percent)
+
+		if len(achievements) > 1 && (platinumPattern.MatchString(a.Name) || platinumPattern.MatchString(a.Description)) {
+			if platinum == -1 || percent(a.Percent) < percent(achievements[platinum].Percent) {
+				platinum = i
+			}
+		}
+	}
+
+	if platinum != -1 {
+		achievements[platinum].Grade = models.GradePlatinum
+	}
+}
+
+// GradeAdded grades an achievement a resync adds to a game that may already
+// have a platinum. InferGrades only sees what RAWG lists, so it can make a new
+// achievement the platinum, which is graded by rarity instead when the game has one.
+func GradeAdded(a models.Achievement, hasPlatinum bool) string {
+	if a.Grade == models.GradePlatinum && hasPlatinum {
+		return gradeByPercent(a.Percent)
+	}
+	return a.Grade
+}
+
+func gradeByPercent(p *float64) string {
+	switch {
+	case p == nil:
+		return models.GradeBronze
+	case *p < 10:
+		return models.GradeGold
+	case *p < 35:
+		return models.GradeSilver
+	default:
+		return models.GradeBronze
+	}
+}
+
+// percent treats achievements of unknown rarity as earned by everyone
+func percent(p *float64) float64 {
+	if p == nil {
+		return 100
+	}
+	return *p
+}
+
+// Points returns what an achievement of the grade is worth
+func Points(grade string) int {
+	return gradePoints[grade]
+}
+
+// Score totals the points of earned achievements, counted by grade, into a
+// PSN style level
+func Score(earned map[string]int) models.TrophyScore {
+	score := models.TrophyScore{
+		Bronze:   earned[models.GradeBronze],
+		Silver:   earned[models.GradeSilver],
+		Gold:     earned[models.GradeGold],
+		Platinum: earned[models.GradePlatinum],
+	}
+	for grade, n := range earned {
+		score.Points += Points(grade) * n
+	}
+
+	score.Level, score.Progress = level(score.Points)
+	return score
+}
+
+// level returns the level reached with points, and how many percent of the
+// way to the next one they are
+func level(points int) (int, int) {
+	lvl := 1
+	for _, perLevel := range levelTiers {
+		// A tier is a hundred levels, except the first which starts at 1
+		levels := 100
+		if lvl == 1 {
+			levels = 99
+		}
+
+		if points < perLevel*levels {
+			lvl += points / perLevel
+			if lvl >= maxLevel {
+				break
+			}
+			return lvl, points % perLevel * 100 / perLevel
+		}
+		points -= perLevel * levels
+		lvl += levels
+	}
+
+	return maxLevel, 100
+}
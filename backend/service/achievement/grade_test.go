@@ -0,0 +1,84 @@
+package achievement
+
+import (
+	"testing"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+)
+
+func TestInferGrades(t *testing.T) {
+	achievements := []models.Achievement{
+		{Name: "Lilac and Gooseberries", Percent: ptr(84.12)},
+		{Name: "Kaer Morhen Forever", Percent: ptr(34.99)},
+		{Name: "Walk on the Wild Side", Percent: ptr(5.5)},
+		{Name: "Platinum Pusher", Description: "Sell a platinum ring", Percent: ptr(8)},
+		{Name: "Master of the Path", Description: "Unlock all other achievements", Percent: ptr(2.1)},
+		{Name: "Gwent Master"},
+	}
+
+	InferGrades(achievements)
+
+	expected := []string{
+		models.GradeBronze,
+		models.GradeSilver,
+		models.GradeGold,
+		models.GradeGold,
+		models.GradePlatinum,
+		models.GradeBronze,
+	}
+	for i, grade := range expected {
+		if achievements[i].Grade != grade {
+			t.Errorf("expected %q to be %s, got %s", achievements[i].Name, grade, achievements[i].Grade)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	score := Score(map[string]int{models.GradeBronze: 2, models.GradePlatinum: 1})
+	if score.Points != 330 || score.Level != 6 || score.Progress != 50 || score.Bronze != 2 || score.Platinum != 1 {
+		t.Errorf("unexpected score %+v", score)
+	}
+
+	tests := []struct {
+		points   int
+		level    int
+		progress int
+	}{
+		{0, 1, 0},
+		{5939, 99, 98},
+		{5940, 100, 0},
+		{14940, 200, 0},
+		{1631339, 998, 99},
+		{1631340, 999, 100},
+		{5000000, 999, 100},
+	}
+	for _, tt := range tests {
+		if level, progress := level(tt.points); level != tt.level || progress != tt.progress {
+			t.Errorf("level(%d) = %d, %d%%, expected %d, %d%%", tt.points, level, progress, tt.level, tt.progress)
+		}
+	}
+}
+
+func TestGradeAdded(t *testing.T) {
+	// A resync fetches the base game's platinum along with a rarer DLC
+	// achievement for earning all the others
+	fetched := []models.Achievement{
+		{Name: "Master of the Path", Description: "Unlock all other achievements", Percent: ptr(2.1)},
+		{Name: "Master of the Wild", Description: "Earn all trophies in Blood and Wine", Percent: ptr(0.9)},
+		{Name: "Lilac and Gooseberries", Percent: ptr(84.12)},
+	}
+	InferGrades(fetched)
+	if fetched[1].Grade != models.GradePlatinum {
+		t.Fatalf("expected the DLC achievement to be inferred the platinum, got %s", fetched[1].Grade)
+	}
+
+	if grade := GradeAdded(fetched[1], true); grade != models.GradeGold {
+		t.Errorf("expected a new platinum to be graded gold when the game has one, got %s", grade)
+	}
+	if grade := GradeAdded(fetched[1], false); grade != models.GradePlatinum {
+		t.Errorf("expected a new platinum to stay one when the game has none, got %s", grade)
+	}
+	if grade := GradeAdded(fetched[2], true); grade != models.GradeBronze {
+		t.Errorf("expected other grades to be kept, got %s", grade)
+	}
+}
@@ -0,0 +1,40 @@
+package achievement
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+)
+
+// Rarity returns the tier of an achievement earned by percent of players, or
+// an empty string when that's unknown
+func Rarity(percent *float64) string {
+	switch {
+	case percent == nil:
+		return ""
+	case *percent < 5:
+		return models.RarityUltraRare
+	case *percent < 10:
+		return models.RarityVeryRare
+	case *percent < 20:
+		return models.RarityRare
+	case *percent < 50:
+		return models.RarityUncommon
+	default:
+		return models.RarityCommon
+	}
+}
+
+// ParsePercent reads a percent such as RAWG's "84.12", rounded to two decimals
+// like the achievements.percent column. Anything that isn't a percent is unknown.
+func ParsePercent(s string) *float64 {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || math.IsNaN(f) || f < 0 || f > 100 {
+		return nil
+	}
+
+	f = math.Round(f*100) / 100
+	return &f
+}
@@ -0,0 +1,63 @@
+package achievement
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+)
+
+func TestRarity(t *testing.T) {
+	tests := []struct {
+		percent *float64
+		rarity  string
+	}{
+		{nil, ""},
+		{ptr(0), models.RarityUltraRare},
+		{ptr(4.99), models.RarityUltraRare},
+		{ptr(5), models.RarityVeryRare},
+		{ptr(10), models.RarityRare},
+		{ptr(20), models.RarityUncommon},
+		{ptr(50), models.RarityCommon},
+		{ptr(100), models.RarityCommon},
+	}
+	for _, tt := range tests {
+		if rarity := Rarity(tt.percent); rarity != tt.rarity {
+			t.Errorf("Rarity(%s) = %q, expected %q", format(tt.percent), rarity, tt.rarity)
+		}
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	tests := []struct {
+		s       string
+		percent *float64
+	}{
+		{"84.12", ptr(84.12)},
+		{" 39.77% ", ptr(39.77)},
+		{"0", ptr(0)},
+		{"100", ptr(100)},
+		{"2.105", ptr(2.11)},
+		{"", nil},
+		{"rare", nil},
+		{"-1", nil},
+		{"100.01", nil},
+		{"NaN", nil},
+	}
+	for _, tt := range tests {
+		if percent := ParsePercent(tt.s); format(percent) != format(tt.percent) {
+			t.Errorf("ParsePercent(%q) = %s, expected %s", tt.s, format(percent), format(tt.percent))
+		}
+	}
+}
+
+func ptr(f float64) *float64 {
+	return &f
+}
+
+func format(p *float64) string {
+	if p == nil {
+		return "nil"
+	}
+	return strconv.FormatFloat(*p, 'f', -1, 64)
+}
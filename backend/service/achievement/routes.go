@@ -0,0 +1,47 @@
+package achievement
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+// Handler lets admins correct the grades inferred for achievements
+type Handler struct {
+	store models.AchievementStore
+}
+
+func NewHandler(store models.AchievementStore) *Handler {
+	return &Handler{store: store}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/achievements/{id:[0-9]+}/grade", utils.MakeHandler(auth.RequireRole(auth.RoleAdmin, h.handleSetGrade))).Methods("PUT")
+}
+
+func (h *Handler) handleSetGrade(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		return utils.Invalid("invalid achievement id: %v", err)
+	}
+
+	var payload models.SetGradePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
+	}
+
+	if err := h.store.SetAchievementGrade(uint32(id), payload.Grade); err != nil {
+		return fmt.Errorf("error setting achievement grade: %w", err)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
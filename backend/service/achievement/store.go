@@ -3,9 +3,11 @@ package achievement
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
 )
 
 type Store struct {
@@ -16,63 +18,116 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
-func (s *Store) CompleteAchievement(userID, achievementID uint32) error {
-	// Start a transaction to ensure atomicity
+// CompleteAchievements marks the user's achievements earned in one
+// transaction. A nil completedAt earns them now and leaves those already
+// earned alone, otherwise every one is set to completedAt.
+func (s *Store) CompleteAchievements(userID uint32, achievementIDs []uint32, completedAt *time.Time) error {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %v", err)
 	}
 	defer tx.Rollback()
 
-	// Step 1: Complete the Achievement
-	_, err = tx.Exec("UPDATE user_achievements SET completed = ?, completed_at = CURRENT_TIMESTAMP WHERE user_id = ? AND achievement_id = ?",
-		true, userID, achievementID)
+	ids := uniqueIDs(achievementIDs)
+	tracked, err := lockUserAchievements(tx, userID, ids)
 	if err != nil {
-		return fmt.Errorf("error updating achievement: %v", err)
+		return err
 	}
 
-	// Step 2: Check if all achievements for the game are completed
-	var gameID uint32
-	err = tx.QueryRow(`
-		SELECT game_id
-		FROM user_achievements
-		WHERE user_id = ? AND achievement_id = ?`,
-		userID, achievementID).Scan(&gameID)
+	if completedAt != nil {
+		for _, a := range tracked {
+			if completedAt.Before(a.trackedAt) {
+				return utils.Invalid("completedAt can't be before the game was tracked at %s", a.trackedAt.UTC().Format(time.RFC3339))
+			}
+		}
+	}
+
+	args := []interface{}{userID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	if completedAt != nil {
+		_, err = tx.Exec("UPDATE user_achievements SET completed = TRUE, completed_at = ? WHERE user_id = ? AND achievement_id IN ("+in+")",
+			append([]interface{}{*completedAt}, args...)...)
+	} else {
+		_, err = tx.Exec("UPDATE user_achievements SET completed = TRUE, completed_at = ? WHERE completed = FALSE AND user_id = ? AND achievement_id IN ("+in+")",
+			append([]interface{}{time.Now()}, args...)...)
+	}
 	if err != nil {
-		return fmt.Errorf("error retrieving game ID: %v", err)
+		return fmt.Errorf("error updating achievements: %v", err)
 	}
 
-	// Count completed achievements for the game
-	var totalAchievements, completedAchievements int
-	err = tx.QueryRow(`
-		SELECT COUNT(*)
-		FROM achievements
-		WHERE game_id = ?`, gameID).Scan(&totalAchievements)
+	for _, gameID := range gameIDs(tracked) {
+		if err := updatePlatinum(tx, userID, gameID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+// ImportAchievements marks the user's achievements earned at their own unlock
+// times in one transaction. Unlocks from before their game was tracked move
+// tracked_at back to them, the user was playing it by then.
+func (s *Store) ImportAchievements(userID uint32, unlocks []models.AchievementUnlock) error {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return fmt.Errorf("error counting total achievements: %v", err)
+		return fmt.Errorf("error starting transaction: %v", err)
 	}
+	defer tx.Rollback()
 
-	err = tx.QueryRow(`
-		SELECT COUNT(*)
-		FROM user_achievements
-		WHERE user_id = ? AND game_id = ? AND completed = true`, userID, gameID).Scan(&completedAchievements)
+	ids := make([]uint32, 0, len(unlocks))
+	for _, u := range unlocks {
+		ids = append(ids, u.AchievementID)
+	}
+	tracked, err := lockUserAchievements(tx, userID, uniqueIDs(ids))
 	if err != nil {
-		return fmt.Errorf("error counting completed achievements: %v", err)
+		return err
+	}
+
+	games := make(map[uint32]trackedAchievement, len(tracked))
+	for _, a := range tracked {
+		games[a.achievementID] = a
 	}
 
-	// Step 3: Update user_game completed_at if all achievements are completed
-	if totalAchievements > 0 && completedAchievements == totalAchievements {
-		_, err = tx.Exec(`
-			UPDATE user_games
-			SET completed_at = ?
-			WHERE user_id = ? AND game_id = ?`,
-			time.Now(), userID, gameID)
+	earliest := make(map[uint32]time.Time)
+	for _, u := range unlocks {
+		if u.CompletedAt == nil {
+			_, err = tx.Exec("UPDATE user_achievements SET completed = TRUE, completed_at = ? WHERE completed = FALSE AND user_id = ? AND achievement_id = ?",
+				time.Now(), userID, u.AchievementID)
+		} else {
+			_, err = tx.Exec("UPDATE user_achievements SET completed = TRUE, completed_at = ? WHERE user_id = ? AND achievement_id = ?",
+				*u.CompletedAt, userID, u.AchievementID)
+
+			a := games[u.AchievementID]
+			if t, ok := earliest[a.gameID]; (!ok || u.CompletedAt.Before(t)) && u.CompletedAt.Before(a.trackedAt) {
+				earliest[a.gameID] = *u.CompletedAt
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("error updating achievement: %v", err)
+		}
+	}
+
+	for gameID, trackedAt := range earliest {
+		_, err = tx.Exec("UPDATE user_games SET tracked_at = ? WHERE user_id = ? AND game_id = ?", trackedAt, userID, gameID)
 		if err != nil {
 			return fmt.Errorf("error updating user_game: %v", err)
 		}
 	}
 
-	// Commit the transaction
+	for _, gameID := range gameIDs(tracked) {
+		if err := updatePlatinum(tx, userID, gameID); err != nil {
+			return err
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("error committing transaction: %v", err)
 	}
@@ -80,9 +135,169 @@ func (s *Store) CompleteAchievement(userID, achievementID uint32) error {
 	return nil
 }
 
+// UncompleteAchievement marks the user's achievement as not earned, and their
+// game as no longer platinumed
+func (s *Store) UncompleteAchievement(userID, achievementID uint32) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	tracked, err := lockUserAchievements(tx, userID, []uint32{achievementID})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE user_achievements SET completed = FALSE, completed_at = NULL WHERE user_id = ? AND achievement_id = ?",
+		userID, achievementID)
+	if err != nil {
+		return fmt.Errorf("error updating achievement: %v", err)
+	}
+
+	if err := updatePlatinum(tx, userID, tracked[0].gameID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+type trackedAchievement struct {
+	achievementID uint32
+	gameID        uint32
+	trackedAt     time.Time
+}
+
+// lockUserAchievements locks the user's rows for the achievements, failing
+// if the user doesn't track any one of them
+func lockUserAchievements(tx *sql.Tx, userID uint32, achievementIDs []uint32) ([]trackedAchievement, error) {
+	args := []interface{}{userID}
+	for _, id := range achievementIDs {
+		args = append(args, id)
+	}
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(achievementIDs)), ", ")
+
+	rows, err := tx.Query(`
+		SELECT ua.achievement_id, ua.game_id, ug.tracked_at
+		FROM user_achievements ua
+		JOIN user_games ug ON ug.user_id = ua.user_id AND ug.game_id = ua.game_id
+		WHERE ua.user_id = ? AND ua.achievement_id IN (`+in+`)
+		FOR UPDATE`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving achievements: %v", err)
+	}
+	defer rows.Close()
+
+	found := make(map[uint32]trackedAchievement)
+	for rows.Next() {
+		var a trackedAchievement
+		if err := rows.Scan(&a.achievementID, &a.gameID, &a.trackedAt); err != nil {
+			return nil, err
+		}
+		found[a.achievementID] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tracked := make([]trackedAchievement, 0, len(achievementIDs))
+	for _, id := range achievementIDs {
+		a, ok := found[id]
+		if !ok {
+			return nil, utils.NotFound("user '%d' doesn't track achievement '%d'", userID, id)
+		}
+		tracked = append(tracked, a)
+	}
+
+	return tracked, nil
+}
+
+// updatePlatinum sets when the user platinumed the game, the time of their
+// last unlock, or clears it while they are missing any achievement
+func updatePlatinum(tx *sql.Tx, userID, gameID uint32) error {
+	var missing int
+	var lastUnlock sql.NullTime
+	err := tx.QueryRow("SELECT COUNT(*) - COALESCE(SUM(completed), 0), MAX(completed_at) FROM user_achievements WHERE user_id = ? AND game_id = ?",
+		userID, gameID).Scan(&missing, &lastUnlock)
+	if err != nil {
+		return fmt.Errorf("error counting completed achievements: %v", err)
+	}
+
+	completedAt := sql.NullTime{Time: lastUnlock.Time, Valid: missing == 0 && lastUnlock.Valid}
+	_, err = tx.Exec("UPDATE user_games SET completed_at = ? WHERE user_id = ? AND game_id = ?", completedAt, userID, gameID)
+	if err != nil {
+		return fmt.Errorf("error updating user_game: %v", err)
+	}
+
+	return nil
+}
+
+func uniqueIDs(ids []uint32) []uint32 {
+	seen := make(map[uint32]bool, len(ids))
+	unique := make([]uint32, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// SetAchievementGrade regrades an achievement. A game has one platinum at most,
+// so the current one has to be regraded first.
+func (s *Store) SetAchievementGrade(id uint32, grade string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var gameID uint32
+	err = tx.QueryRow("SELECT game_id FROM achievements WHERE id = ? FOR UPDATE", id).Scan(&gameID)
+	if err == sql.ErrNoRows {
+		return utils.NotFound("achievement not found with id '%d'", id)
+	}
+	if err != nil {
+		return err
+	}
+
+	if grade == models.GradePlatinum {
+		var platinumID uint32
+		err = tx.QueryRow("SELECT id FROM achievements WHERE game_id = ? AND grade = ? AND id != ? FOR UPDATE", gameID, models.GradePlatinum, id).Scan(&platinumID)
+		if err == nil {
+			return utils.Conflict("achievement '%d' is already the platinum of game '%d'", platinumID, gameID)
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE achievements SET grade = ? WHERE id = ?", grade, id); err != nil {
+		return fmt.Errorf("error updating achievement: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+func gameIDs(tracked []trackedAchievement) []uint32 {
+	ids := make([]uint32, 0, len(tracked))
+	for _, a := range tracked {
+		ids = append(ids, a.gameID)
+	}
+	return uniqueIDs(ids)
+}
 
 func (s *Store) GetAllAchievementsByGame(gameID uint32) ([]*models.Achievement, error) {
-	rows, err := s.db.Query("SELECT * FROM achievements WHERE game_id = ?", gameID)
+	rows, err := s.db.Query("SELECT id, rawg_id, name, description, imgurl, percent, grade, game_id FROM achievements WHERE game_id = ?", gameID)
 	if err != nil {
 		return nil, err
 	}
@@ -104,12 +319,45 @@ func (s *Store) GetAllAchievementsByGame(gameID uint32) ([]*models.Achievement,
 	return as, nil
 }
 
+func (s *Store) GetAchievementsByRarity(gameID uint32, rarestFirst bool) ([]*models.Achievement, error) {
+	order := "ASC"
+	if !rarestFirst {
+		order = "DESC"
+	}
+
+	rows, err := s.db.Query("SELECT id, rawg_id, name, description, imgurl, percent, grade, game_id FROM achievements WHERE game_id = ? ORDER BY percent IS NULL, percent "+order+", id", gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	as := []*models.Achievement{}
+	for rows.Next() {
+		var a models.Achievement
+		if err := scanAchievement(rows, &a); err != nil {
+			return nil, err
+		}
+		as = append(as, &a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return as, nil
+}
+
 func scanAchievement(scanner interface {
 	Scan(dest ...interface{}) error
 }, ach *models.Achievement) error {
-	err := scanner.Scan(&ach.ID, &ach.Name, &ach.Description, &ach.ImgURL, &ach.Percent, &ach.GameID)
+	var rawgID sql.NullInt64
+	var description, imgURL sql.NullString
+	err := scanner.Scan(&ach.ID, &rawgID, &ach.Name, &description, &imgURL, &ach.Percent, &ach.Grade, &ach.GameID)
 	if err != nil {
 		return err
 	}
+	ach.RAWGID = uint32(rawgID.Int64)
+	ach.Description = description.String
+	ach.ImgURL = imgURL.String
+	ach.Rarity = Rarity(ach.Percent)
 	return nil
 }
\ No newline at end of file
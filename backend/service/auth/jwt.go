@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/config"
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+type contextKey string
+
+const (
+	UserKey    contextKey = "userID"
+	SessionKey contextKey = "sessionID"
+	RoleKey    contextKey = "role"
+)
+
+// Claims are the contents of an access token issued by /login and /token/refresh
+type Claims struct {
+	UserID    uint32 `json:"userID"`
+	SessionID uint32 `json:"sessionID"`
+	jwt.RegisteredClaims
+}
+
+func CreateJWT(secret []byte, userID, sessionID uint32) (string, error) {
+	expiration := time.Second * time.Duration(config.Envs.JWTExpirationInSeconds)
+	now := time.Now()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(int(userID)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiration)),
+		},
+	})
+
+	tokenString, err := token.SignedString(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+func ValidateJWT(secret []byte, tokenString string) (*Claims, error) {
+	claims := new(Claims)
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return secret, nil
+	}, jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// JWTMiddleware authenticates requests on the router it is attached to.
+// Mutating requests (anything but GET, HEAD and OPTIONS) must carry a valid
+// token unless their path is listed in publicPaths. Read-only requests are
+// let through anonymously, but a valid token still puts the user ID on the context.
+// Tokens belonging to a revoked or expired session are treated as invalid.
+func JWTMiddleware(store models.UserStore, sessionStore models.SessionStore, publicPaths ...string) mux.MiddlewareFunc {
+	public := make(map[string]bool, len(publicPaths))
+	for _, p := range publicPaths {
+		public[p] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			required := !public[r.URL.Path] && !isReadOnly(r.Method)
+
+			tokenString := getTokenFromRequest(r)
+			if tokenString == "" {
+				if required {
+					permissionDenied(w)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			claims, u, err := authenticate(store, sessionStore, tokenString)
+			if err != nil {
+				log.Printf("failed to authenticate token: %v", err)
+				if required {
+					permissionDenied(w)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), UserKey, claims.UserID)
+			ctx = context.WithValue(ctx, SessionKey, claims.SessionID)
+			ctx = context.WithValue(ctx, RoleKey, Role(u.Role))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// authenticate resolves a token to its claims and the user it belongs to.
+// The user is loaded on every request so role changes apply immediately.
+func authenticate(store models.UserStore, sessionStore models.SessionStore, tokenString string) (*Claims, *models.User, error) {
+	claims, err := ValidateJWT([]byte(config.Envs.JWTSecret), tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s, err := sessionStore.GetSessionByID(claims.SessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.UserID != claims.UserID || !s.IsActive(time.Now()) {
+		return nil, nil, fmt.Errorf("session %d is no longer active", s.ID)
+	}
+
+	u, err := store.GetUserByID(int(claims.UserID))
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Deactivated {
+		return nil, nil, fmt.Errorf("user %d is deactivated", u.ID)
+	}
+
+	return claims, u, nil
+}
+
+func GetUserIDFromContext(ctx context.Context) uint32 {
+	userID, ok := ctx.Value(UserKey).(uint32)
+	if !ok {
+		return 0
+	}
+
+	return userID
+}
+
+func GetSessionIDFromContext(ctx context.Context) uint32 {
+	sessionID, ok := ctx.Value(SessionKey).(uint32)
+	if !ok {
+		return 0
+	}
+
+	return sessionID
+}
+
+func GetRoleFromContext(ctx context.Context) Role {
+	role, ok := ctx.Value(RoleKey).(Role)
+	if !ok {
+		return ""
+	}
+
+	return role
+}
+
+// ActingUserID returns the ID of the authenticated user making the request.
+// Handlers that still accept a user ID from the client pass it as supplied;
+// a non-zero value that differs from the authenticated user is forbidden.
+func ActingUserID(r *http.Request, supplied uint32) (uint32, error) {
+	userID := GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		return 0, errPermissionDenied
+	}
+
+	if supplied != 0 && supplied != userID {
+		return 0, utils.Forbidden("cannot act on behalf of user %d", supplied)
+	}
+
+	return userID, nil
+}
+
+func getTokenFromRequest(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
+func isReadOnly(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+var errPermissionDenied = utils.Unauthorized("permission denied")
+
+func permissionDenied(w http.ResponseWriter) {
+	utils.WriteError(w, http.StatusUnauthorized, errPermissionDenied)
+}
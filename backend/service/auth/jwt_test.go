@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/config"
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestCreateJWT(t *testing.T) {
+	secret := []byte("secret")
+
+	token, err := CreateJWT(secret, 1, 1)
+	if err != nil {
+		t.Errorf("error creating JWT: %v", err)
+	}
+
+	if token == "" {
+		t.Error("expected token to be not empty")
+	}
+
+	claims, err := ValidateJWT(secret, token)
+	if err != nil {
+		t.Fatalf("error validating JWT: %v", err)
+	}
+
+	if claims.UserID != 1 || claims.SessionID != 1 {
+		t.Errorf("expected user id 1 and session id 1, got %d and %d", claims.UserID, claims.SessionID)
+	}
+
+	if _, err := ValidateJWT([]byte("wrong secret"), token); err == nil {
+		t.Error("expected token signed with another secret to be rejected")
+	}
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(JWTMiddleware(&mockUserStore{}, &mockSessionStore{}, "/login"))
+
+	var gotUserID uint32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		gotUserID = GetUserIDFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}
+	router.HandleFunc("/login", handler).Methods("POST")
+	router.HandleFunc("/edit-user", handler).Methods("PUT")
+	router.HandleFunc("/users", handler).Methods("GET")
+
+	token, err := CreateJWT([]byte(config.Envs.JWTSecret), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revokedToken, err := CreateJWT([]byte(config.Envs.JWTSecret), 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should reject mutating request without a token", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, "/edit-user", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("should reject mutating request with an invalid token", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, "/edit-user", nil)
+		req.Header.Set("Authorization", "Bearer not-a-token")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("should reject a token whose session was revoked", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, "/edit-user", nil)
+		req.Header.Set("Authorization", "Bearer "+revokedToken)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("should allow public and read-only requests without a token", func(t *testing.T) {
+		for _, req := range []*http.Request{
+			httptest.NewRequest(http.MethodPost, "/login", nil),
+			httptest.NewRequest(http.MethodGet, "/users", nil),
+		} {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Errorf("%s %s: expected status code %d, got %d", req.Method, req.URL.Path, http.StatusOK, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should put the user id on the context", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, "/edit-user", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		if gotUserID != 1 {
+			t.Errorf("expected user id 1 on the context, got %d", gotUserID)
+		}
+	})
+}
+
+type mockUserStore struct {
+	models.UserStore
+}
+
+func (s *mockUserStore) GetUserByID(id int) (*models.User, error) {
+	if id == 1 {
+		return &models.User{ID: 1, Username: "adamjtroup", Role: string(RoleUser), Password: "$2a$10$luQ7PyQR0KQeliaN15Y55uMFFPzdwDW8VhjEPvIWfJUizTN4IGps2"}, nil
+	}
+	return nil, utils.NotFound("user not found")
+}
+
+type mockSessionStore struct {
+	models.SessionStore
+}
+
+func (s *mockSessionStore) GetSessionByID(id uint32) (*models.Session, error) {
+	expiresAt := time.Now().Add(time.Hour)
+	switch id {
+	case 1:
+		return &models.Session{ID: 1, UserID: 1, ExpiresAt: expiresAt}, nil
+	case 2:
+		revokedAt := time.Now().Add(-time.Minute)
+		return &models.Session{ID: 2, UserID: 1, ExpiresAt: expiresAt, RevokedAt: &revokedAt}, nil
+	}
+	return nil, utils.NotFound("session not found")
+}
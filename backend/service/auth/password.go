@@ -1,6 +1,11 @@
 package auth
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"fmt"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"golang.org/x/crypto/bcrypt"
+)
 
 func HashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -15,3 +20,26 @@ func ComparePasswords(hashed string, plain []byte) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashed), plain)
 	return err == nil
 }
+
+// HashNewPassword applies the password policy to a password a user is switching to
+// and hashes it. Both changing and resetting a password go through here.
+func HashNewPassword(newPassword, confirmNewPassword, currentHash string) (string, error) {
+	if newPassword != confirmNewPassword {
+		return "", utils.Invalid("new password did not match confirm new password")
+	}
+
+	if err := utils.CheckPasswordPolicy(newPassword); err != nil {
+		return "", err
+	}
+
+	if currentHash != "" && ComparePasswords(currentHash, []byte(newPassword)) {
+		return "", utils.Invalid("new password cannot be the same as current password")
+	}
+
+	hashedPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return "", fmt.Errorf("error hashing new password: %v", err)
+	}
+
+	return hashedPassword, nil
+}
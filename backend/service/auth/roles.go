@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+)
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Higher ranks include every permission of the lower ones
+var roleRanks = map[Role]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if _, ok := roleRanks[r]; !ok {
+		return "", fmt.Errorf("unknown role '%s'", s)
+	}
+	return r, nil
+}
+
+// Includes reports whether a user with role r may do what other is allowed to do
+func (r Role) Includes(other Role) bool {
+	return roleRanks[r] >= roleRanks[other]
+}
+
+// RequireRole only lets authenticated users with at least the given role through
+func RequireRole(role Role, handlerFunc utils.HandlerFunc) utils.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if GetUserIDFromContext(r.Context()) == 0 {
+			return errPermissionDenied
+		}
+
+		if !GetRoleFromContext(r.Context()).Includes(role) {
+			return utils.Forbidden("requires %s role", role)
+		}
+
+		return handlerFunc(w, r)
+	}
+}
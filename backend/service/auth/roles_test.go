@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+)
+
+func TestRoleIncludes(t *testing.T) {
+	if !RoleAdmin.Includes(RoleModerator) || !RoleAdmin.Includes(RoleUser) {
+		t.Error("expected admin to include every other role")
+	}
+	if RoleUser.Includes(RoleAdmin) || RoleModerator.Includes(RoleAdmin) {
+		t.Error("expected only admins to include admin")
+	}
+	if Role("").Includes(RoleUser) {
+		t.Error("expected an empty role to include nothing")
+	}
+
+	if _, err := ParseRole("superuser"); err == nil {
+		t.Error("expected unknown role to fail to parse")
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	handler := utils.MakeHandler(RequireRole(RoleAdmin, func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}))
+
+	cases := []struct {
+		name   string
+		userID uint32
+		role   Role
+		want   int
+	}{
+		{"anonymous", 0, "", http.StatusUnauthorized},
+		{"user", 1, RoleUser, http.StatusForbidden},
+		{"moderator", 1, RoleModerator, http.StatusForbidden},
+		{"admin", 1, RoleAdmin, http.StatusOK},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if c.userID != 0 {
+				ctx := context.WithValue(req.Context(), UserKey, c.userID)
+				ctx = context.WithValue(ctx, RoleKey, c.role)
+				req = req.WithContext(ctx)
+			}
+
+			rr := httptest.NewRecorder()
+			handler(rr, req)
+
+			if rr.Code != c.want {
+				t.Errorf("expected status code %d, got %d", c.want, rr.Code)
+			}
+		})
+	}
+}
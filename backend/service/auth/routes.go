@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+// Handler serves enrolment in and management of two-factor authentication.
+// The second login step itself lives with /login.
+type Handler struct {
+	store     models.TwoFactorStore
+	userStore models.UserStore
+	now       func() time.Time
+}
+
+func NewHandler(store models.TwoFactorStore, userStore models.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore, now: time.Now}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/2fa", utils.MakeHandler(h.handleGetTwoFactor)).Methods("GET")
+	router.HandleFunc("/2fa/enrol", utils.MakeHandler(h.handleEnrol)).Methods("POST")
+	router.HandleFunc("/2fa/confirm", utils.MakeHandler(h.handleConfirm)).Methods("POST")
+	router.HandleFunc("/2fa/recovery-codes", utils.MakeHandler(h.handleRegenerateRecoveryCodes)).Methods("POST")
+	router.HandleFunc("/2fa", utils.MakeHandler(h.handleDisable)).Methods("DELETE")
+}
+
+func (h *Handler) handleGetTwoFactor(w http.ResponseWriter, r *http.Request) error {
+	userID, err := ActingUserID(r, 0)
+	if err != nil {
+		return err
+	}
+
+	tf, err := h.store.GetTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+
+	status := models.TwoFactorStatus{Enabled: tf.Enabled()}
+	if status.Enabled {
+		status.ConfirmedAt = tf.ConfirmedAt
+		status.RecoveryCodesRemaining, err = h.store.CountRecoveryCodes(userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, status)
+}
+
+func (h *Handler) handleEnrol(w http.ResponseWriter, r *http.Request) error {
+	userID, err := ActingUserID(r, 0)
+	if err != nil {
+		return err
+	}
+
+	u, err := h.userStore.GetUserByID(int(userID))
+	if err != nil {
+		return err
+	}
+
+	tf, err := h.store.GetTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+	if tf.Enabled() {
+		return utils.Conflict("two-factor authentication is already enabled, disable it first")
+	}
+
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return err
+	}
+
+	// Enrolling again before confirming replaces the secret
+	if err := h.store.SaveTwoFactor(userID, secret); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusCreated, models.TwoFactorEnrolment{
+		Secret: secret,
+		URI:    TOTPURI(u.Username, secret),
+	})
+}
+
+func (h *Handler) handleConfirm(w http.ResponseWriter, r *http.Request) error {
+	var payload models.TwoFactorCodePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
+	}
+
+	userID, err := ActingUserID(r, 0)
+	if err != nil {
+		return err
+	}
+
+	tf, err := h.store.GetTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+	if tf == nil {
+		return utils.NotFound("no two-factor enrolment to confirm")
+	}
+	if tf.Enabled() {
+		return utils.Conflict("two-factor authentication is already enabled")
+	}
+
+	step, ok := ValidateTOTP(tf.Secret, payload.Code, h.now(), tf.LastUsedStep)
+	if !ok {
+		return utils.Invalid("invalid code")
+	}
+
+	codes, hashes, err := NewRecoveryCodes()
+	if err != nil {
+		return err
+	}
+
+	if err := h.store.ConfirmTwoFactor(userID, step, hashes); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *Handler) handleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) error {
+	userID, err := h.confirmPassword(r)
+	if err != nil {
+		return err
+	}
+
+	tf, err := h.store.GetTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+	if !tf.Enabled() {
+		return utils.Conflict("two-factor authentication is not enabled")
+	}
+
+	codes, hashes, err := NewRecoveryCodes()
+	if err != nil {
+		return err
+	}
+
+	if err := h.store.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *Handler) handleDisable(w http.ResponseWriter, r *http.Request) error {
+	userID, err := h.confirmPassword(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.store.DeleteTwoFactor(userID); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+// confirmPassword reads a models.ConfirmPasswordPayload and checks it against
+// the acting user's password
+func (h *Handler) confirmPassword(r *http.Request) (uint32, error) {
+	var payload models.ConfirmPasswordPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return 0, err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return 0, utils.ValidationError(err)
+	}
+
+	userID, err := ActingUserID(r, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	u, err := h.userStore.GetUserByID(int(userID))
+	if err != nil {
+		return 0, err
+	}
+
+	if !ComparePasswords(u.Password, []byte(payload.ConfirmPassword)) {
+		return 0, utils.Forbidden("invalid password")
+	}
+
+	return userID, nil
+}
@@ -32,3 +32,28 @@ func TestComparePasswords(t *testing.T) {
 		t.Errorf("expected password to not match hash")
 	}
 }
+
+func TestHashNewPassword(t *testing.T) {
+	current, err := HashPassword("Sample123!")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := HashNewPassword("Sample1234!", "Sample12345!", current); err == nil {
+		t.Error("expected mismatched confirmation to fail")
+	}
+	if _, err := HashNewPassword("weakpassword", "weakpassword", current); err == nil {
+		t.Error("expected password failing the policy to fail")
+	}
+	if _, err := HashNewPassword("Sample123!", "Sample123!", current); err == nil {
+		t.Error("expected reusing the current password to fail")
+	}
+
+	hash, err := HashNewPassword("Sample1234!", "Sample1234!", current)
+	if err != nil {
+		t.Fatalf("expected valid new password to be hashed: %v", err)
+	}
+	if !ComparePasswords(hash, []byte("Sample1234!")) {
+		t.Error("expected new hash to match the new password")
+	}
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+)
+
+// Store keeps users' TOTP secrets and recovery codes
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetTwoFactor(userID uint32) (*models.TwoFactor, error) {
+	var tf models.TwoFactor
+	var confirmedAt sql.NullTime
+
+	err := s.db.QueryRow("SELECT user_id, secret, confirmed_at, last_used_step, created_at FROM two_factor WHERE user_id = ?", userID).
+		Scan(&tf.UserID, &tf.Secret, &confirmedAt, &tf.LastUsedStep, &tf.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if confirmedAt.Valid {
+		tf.ConfirmedAt = &confirmedAt.Time
+	}
+
+	return &tf, nil
+}
+
+func (s *Store) SaveTwoFactor(userID uint32, secret string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO two_factor (user_id, secret) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE secret = VALUES(secret), confirmed_at = NULL, last_used_step = 0, created_at = CURRENT_TIMESTAMP`,
+		userID, secret)
+	if err != nil {
+		return fmt.Errorf("failed to save two-factor secret: %v", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) ConfirmTwoFactor(userID uint32, step int64, recoveryCodeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE two_factor SET confirmed_at = CURRENT_TIMESTAMP, last_used_step = ? WHERE user_id = ? AND confirmed_at IS NULL",
+		step, userID)
+	if err != nil {
+		return fmt.Errorf("failed to confirm two-factor authentication: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("no two-factor enrolment to confirm")
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) SetLastUsedStep(userID uint32, step int64) (bool, error) {
+	// The comparison makes this safe against the same code being used concurrently
+	result, err := s.db.Exec("UPDATE two_factor SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?", step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record totp step: %v", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+func (s *Store) ReplaceRecoveryCodes(userID uint32, recoveryCodeHashes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) CountRecoveryCodes(userID uint32) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL", userID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (s *Store) UseRecoveryCode(userID uint32, codeHash string) (bool, error) {
+	result, err := s.db.Exec("UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %v", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+func (s *Store) DeleteTwoFactor(userID uint32) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM two_factor WHERE user_id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete two-factor secret: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID uint32, recoveryCodeHashes []string) error {
+	_, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+
+	for _, hash := range recoveryCodeHashes {
+		_, err = tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, hash)
+		if err != nil {
+			return fmt.Errorf("failed to save recovery code: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/config"
+)
+
+// NewRefreshToken returns an opaque refresh token for the client and the hash
+// that is stored in the sessions table. The token itself is never persisted.
+func NewRefreshToken() (token string, hash string, err error) {
+	token, err = randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	return token, HashToken(token), nil
+}
+
+// NewSignedToken returns a token for a single purpose, such as verifying an
+// email, and the hash to store for it. The signature binds the token to its
+// purpose so it can't be replayed against another endpoint.
+func NewSignedToken(purpose string) (token string, hash string, err error) {
+	payload, err := randomString(24)
+	if err != nil {
+		return "", "", err
+	}
+
+	token = payload + "." + sign(purpose, payload)
+	return token, HashToken(token), nil
+}
+
+// VerifySignedToken checks a token was issued by NewSignedToken for purpose
+// and returns the hash to look it up by
+func VerifySignedToken(purpose, token string) (string, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(purpose, payload))) {
+		return "", fmt.Errorf("invalid token")
+	}
+
+	return HashToken(token), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func sign(purpose, payload string) string {
+	mac := hmac.New(sha256.New, []byte(config.Envs.JWTSecret))
+	mac.Write([]byte(purpose + "." + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
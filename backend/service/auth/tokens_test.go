@@ -0,0 +1,28 @@
+package auth
+
+import "testing"
+
+func TestSignedToken(t *testing.T) {
+	token, hash, err := NewSignedToken("verify_email")
+	if err != nil {
+		t.Fatalf("error creating token: %v", err)
+	}
+
+	got, err := VerifySignedToken("verify_email", token)
+	if err != nil {
+		t.Fatalf("expected token to verify: %v", err)
+	}
+	if got != hash {
+		t.Errorf("expected hash %s, got %s", hash, got)
+	}
+
+	if _, err := VerifySignedToken("reset_password", token); err == nil {
+		t.Error("expected token to be rejected for another purpose")
+	}
+	if _, err := VerifySignedToken("verify_email", token+"x"); err == nil {
+		t.Error("expected tampered token to be rejected")
+	}
+	if _, err := VerifySignedToken("verify_email", "no-signature"); err == nil {
+		t.Error("expected unsigned token to be rejected")
+	}
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA1 secret from the test vectors in RFC 6238, appendix B
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// The RFC lists 8 digit codes, these are their last 6 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, test := range tests {
+		code, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(test.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.code {
+			t.Errorf("at %d expected code %s, got %s", test.unix, test.code, code)
+		}
+	}
+
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("expected an invalid secret to be rejected")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := TOTPStep(now)
+
+	t.Run("should accept the current code", func(t *testing.T) {
+		got, ok := ValidateTOTP(rfcSecret, "081804", now, 0)
+		if !ok || got != step {
+			t.Errorf("expected the code to match step %d, got %d %v", step, got, ok)
+		}
+	})
+
+	t.Run("should allow a step of clock drift", func(t *testing.T) {
+		if _, ok := ValidateTOTP(rfcSecret, "081804", now.Add(30*time.Second), 0); !ok {
+			t.Error("expected the previous step's code to be accepted")
+		}
+		if _, ok := ValidateTOTP(rfcSecret, "081804", now.Add(90*time.Second), 0); ok {
+			t.Error("expected a code from three steps ago to be rejected")
+		}
+	})
+
+	t.Run("should not accept a used step", func(t *testing.T) {
+		if _, ok := ValidateTOTP(rfcSecret, "081804", now, step); ok {
+			t.Error("expected a replayed code to be rejected")
+		}
+	})
+
+	t.Run("should reject malformed codes", func(t *testing.T) {
+		for _, code := range []string{"", "81804", "0818040", "abcdef"} {
+			if _, ok := ValidateTOTP(rfcSecret, code, now, 0); ok {
+				t.Errorf("expected %q to be rejected", code)
+			}
+		}
+	})
+}
+
+func TestTOTPURI(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uri, err := url.Parse(TOTPURI("adamjtroup", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("expected an otpauth://totp URI, got %s", uri)
+	}
+	if !strings.HasSuffix(uri.Path, TOTPIssuer+":adamjtroup") {
+		t.Errorf("expected the label to name the issuer and account, got %s", uri.Path)
+	}
+	if uri.Query().Get("secret") != secret || uri.Query().Get("issuer") != TOTPIssuer {
+		t.Errorf("expected the secret and issuer in the query, got %s", uri.RawQuery)
+	}
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+)
+
+const (
+	PurposeTwoFactor = "two_factor"
+	// Challenges from /reactivate-account, which also reactivate the account
+	PurposeReactivate = "reactivate"
+
+	twoFactorChallengeExpiration = 5 * time.Minute
+	recoveryCodeCount            = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewRecoveryCodes returns a fresh set of one-time recovery codes to show the
+// user once, and their hashes to store
+func NewRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		// 8 characters, grouped as xxxx-xxxx to be easier to copy down
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		code = code[:4] + "-" + code[4:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode hashes a recovery code, ignoring case, spaces and dashes
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return HashToken(code)
+}
+
+// NewTwoFactorChallenge issues the token a client trades, along with a second
+// factor, for a session at /login/2fa once the user's password has been
+// checked. purpose is PurposeTwoFactor or PurposeReactivate.
+func NewTwoFactorChallenge(store models.UserTokenStore, userID uint32, purpose string, now time.Time) (string, error) {
+	token, hash, err := NewSignedToken(purpose)
+	if err != nil {
+		return "", err
+	}
+
+	if err := store.CreateToken(userID, purpose, hash, now.Add(twoFactorChallengeExpiration)); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// VerifySecondFactor checks code against the user's confirmed TOTP secret, or
+// failing that their unused recovery codes. Neither can be used twice.
+func VerifySecondFactor(store models.TwoFactorStore, tf *models.TwoFactor, code string, now time.Time) (bool, error) {
+	if !tf.Enabled() {
+		return false, nil
+	}
+
+	if step, ok := ValidateTOTP(tf.Secret, code, now, tf.LastUsedStep); ok {
+		return store.SetLastUsedStep(tf.UserID, step)
+	}
+
+	return store.UseRecoveryCode(tf.UserID, HashRecoveryCode(code))
+}
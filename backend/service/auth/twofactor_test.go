@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/gorilla/mux"
+)
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("expected %d codes, got %d", recoveryCodeCount, len(codes))
+	}
+
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if seen[code] {
+			t.Errorf("expected codes to be unique, got %s twice", code)
+		}
+		seen[code] = true
+
+		if hashes[i] == code || hashes[i] != HashRecoveryCode(code) {
+			t.Errorf("expected the hash of %s to be stored", code)
+		}
+	}
+
+	if HashRecoveryCode("ABCD EFGH") != HashRecoveryCode("abcd-efgh") {
+		t.Error("expected case, spaces and dashes to be ignored")
+	}
+}
+
+func TestTwoFactor(t *testing.T) {
+	store := newMockTwoFactorStore()
+	handler := NewHandler(store, &mockUserStore{})
+
+	// Tests run against a fixed clock so codes can be worked out ahead of time
+	now := time.Date(2024, time.August, 18, 12, 0, 0, 0, time.UTC)
+	handler.now = func() time.Time { return now }
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	do := func(method, path string, payload any) *httptest.ResponseRecorder {
+		marshal, _ := json.Marshal(payload)
+
+		req, err := http.NewRequest(method, path, bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), UserKey, uint32(1)))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	var secret string
+	var recoveryCodes []string
+
+	t.Run("should enrol with a secret and otpauth URI", func(t *testing.T) {
+		rr := do(http.MethodPost, "/2fa/enrol", nil)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, received %d. Response body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+
+		var enrolment models.TwoFactorEnrolment
+		if err := json.NewDecoder(rr.Body).Decode(&enrolment); err != nil {
+			t.Fatal(err)
+		}
+		if enrolment.Secret == "" || enrolment.URI != TOTPURI("adamjtroup", enrolment.Secret) {
+			t.Errorf("expected a secret and its URI, got %+v", enrolment)
+		}
+		secret = enrolment.Secret
+	})
+
+	t.Run("should not be enabled before confirming", func(t *testing.T) {
+		if store.twoFactor[1].Enabled() {
+			t.Error("expected enrolment to be unconfirmed")
+		}
+	})
+
+	t.Run("should not confirm with a wrong code", func(t *testing.T) {
+		code, _ := TOTPCode(secret, TOTPStep(now)+5)
+
+		rr := do(http.MethodPost, "/2fa/confirm", models.TwoFactorCodePayload{Code: code})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should confirm and return recovery codes", func(t *testing.T) {
+		code, _ := TOTPCode(secret, TOTPStep(now))
+
+		rr := do(http.MethodPost, "/2fa/confirm", models.TwoFactorCodePayload{Code: code})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var res models.RecoveryCodesResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if len(res.RecoveryCodes) != recoveryCodeCount {
+			t.Errorf("expected %d recovery codes, got %d", recoveryCodeCount, len(res.RecoveryCodes))
+		}
+		recoveryCodes = res.RecoveryCodes
+	})
+
+	t.Run("should not enrol again while enabled", func(t *testing.T) {
+		rr := do(http.MethodPost, "/2fa/enrol", nil)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusConflict, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should verify each second factor once", func(t *testing.T) {
+		// The code used to confirm can't be used to log in
+		code, _ := TOTPCode(secret, TOTPStep(now))
+		if ok, _ := VerifySecondFactor(store, store.twoFactor[1], code, now); ok {
+			t.Error("expected the confirmation code to be used up")
+		}
+
+		later := now.Add(time.Minute)
+		code, _ = TOTPCode(secret, TOTPStep(later))
+		if ok, _ := VerifySecondFactor(store, store.twoFactor[1], code, later); !ok {
+			t.Error("expected a new code to be accepted")
+		}
+
+		if ok, _ := VerifySecondFactor(store, store.twoFactor[1], recoveryCodes[0], later); !ok {
+			t.Error("expected a recovery code to be accepted")
+		}
+		if ok, _ := VerifySecondFactor(store, store.twoFactor[1], recoveryCodes[0], later); ok {
+			t.Error("expected a used recovery code to be rejected")
+		}
+	})
+
+	t.Run("should count remaining recovery codes", func(t *testing.T) {
+		rr := do(http.MethodGet, "/2fa", nil)
+
+		var status models.TwoFactorStatus
+		if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+		if !status.Enabled || status.RecoveryCodesRemaining != recoveryCodeCount-1 {
+			t.Errorf("expected enabled with %d recovery codes left, got %+v", recoveryCodeCount-1, status)
+		}
+	})
+
+	t.Run("should regenerate recovery codes with the password", func(t *testing.T) {
+		rr := do(http.MethodPost, "/2fa/recovery-codes", models.ConfirmPasswordPayload{ConfirmPassword: "Sample123!"})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		if ok, _ := VerifySecondFactor(store, store.twoFactor[1], recoveryCodes[1], now); ok {
+			t.Error("expected old recovery codes to stop working")
+		}
+	})
+
+	t.Run("should not disable with the wrong password", func(t *testing.T) {
+		rr := do(http.MethodDelete, "/2fa", models.ConfirmPasswordPayload{ConfirmPassword: "Wrong123!"})
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should disable with the password", func(t *testing.T) {
+		rr := do(http.MethodDelete, "/2fa", models.ConfirmPasswordPayload{ConfirmPassword: "Sample123!"})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		if store.twoFactor[1].Enabled() {
+			t.Error("expected two-factor authentication to be disabled")
+		}
+	})
+}
+
+type mockTwoFactorStore struct {
+	twoFactor     map[uint32]*models.TwoFactor
+	recoveryCodes map[uint32]map[string]bool
+}
+
+func newMockTwoFactorStore() *mockTwoFactorStore {
+	return &mockTwoFactorStore{twoFactor: make(map[uint32]*models.TwoFactor), recoveryCodes: make(map[uint32]map[string]bool)}
+}
+
+func (s *mockTwoFactorStore) GetTwoFactor(userID uint32) (*models.TwoFactor, error) {
+	return s.twoFactor[userID], nil
+}
+
+func (s *mockTwoFactorStore) SaveTwoFactor(userID uint32, secret string) error {
+	s.twoFactor[userID] = &models.TwoFactor{UserID: userID, Secret: secret}
+	delete(s.recoveryCodes, userID)
+	return nil
+}
+
+func (s *mockTwoFactorStore) ConfirmTwoFactor(userID uint32, step int64, recoveryCodeHashes []string) error {
+	tf := s.twoFactor[userID]
+	if tf == nil || tf.ConfirmedAt != nil {
+		return fmt.Errorf("no two-factor enrolment to confirm")
+	}
+	confirmedAt := time.Now()
+	tf.ConfirmedAt = &confirmedAt
+	tf.LastUsedStep = step
+	return s.ReplaceRecoveryCodes(userID, recoveryCodeHashes)
+}
+
+func (s *mockTwoFactorStore) SetLastUsedStep(userID uint32, step int64) (bool, error) {
+	tf := s.twoFactor[userID]
+	if step <= tf.LastUsedStep {
+		return false, nil
+	}
+	tf.LastUsedStep = step
+	return true, nil
+}
+
+func (s *mockTwoFactorStore) ReplaceRecoveryCodes(userID uint32, recoveryCodeHashes []string) error {
+	s.recoveryCodes[userID] = make(map[string]bool)
+	for _, hash := range recoveryCodeHashes {
+		s.recoveryCodes[userID][hash] = true
+	}
+	return nil
+}
+
+func (s *mockTwoFactorStore) CountRecoveryCodes(userID uint32) (int, error) {
+	return len(s.recoveryCodes[userID]), nil
+}
+
+func (s *mockTwoFactorStore) UseRecoveryCode(userID uint32, codeHash string) (bool, error) {
+	if !s.recoveryCodes[userID][codeHash] {
+		return false, nil
+	}
+	delete(s.recoveryCodes[userID], codeHash)
+	return true, nil
+}
+
+func (s *mockTwoFactorStore) DeleteTwoFactor(userID uint32) error {
+	delete(s.twoFactor, userID)
+	delete(s.recoveryCodes, userID)
+	return nil
+}
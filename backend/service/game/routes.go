@@ -1,16 +1,17 @@
 package game
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
-	"net/url"
+	"slices"
 	"strconv"
-	"time"
+	"strings"
 
-	"github.com/ajtroup1/platinum-trophy-tracker/config"
 	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/rawg"
 	"github.com/ajtroup1/platinum-trophy-tracker/utils"
 	"github.com/gorilla/mux"
 )
@@ -18,304 +19,524 @@ import (
 type Handler struct {
 	store models.GameStore
 	userStore models.UserGameStore
+	achievementStore models.AchievementStore
+	screenshotStore models.ScreenshotStore
+	rawg rawg.Client
+	jobs models.JobQueue
 }
 
-func NewHandler(store models.GameStore, userStore models.UserGameStore) *Handler {
-	return &Handler{store: store, userStore: userStore}
+func NewHandler(store models.GameStore, userStore models.UserGameStore, achievementStore models.AchievementStore, screenshotStore models.ScreenshotStore, rawg rawg.Client, jobs models.JobQueue) *Handler {
+	return &Handler{store: store, userStore: userStore, achievementStore: achievementStore, screenshotStore: screenshotStore, rawg: rawg, jobs: jobs}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/games", h.handleGetAllGames).Methods("GET")
-	router.HandleFunc("/games/{id:[0-9]+}", h.handleGetGameByID).Methods("GET")
-	router.HandleFunc("/game-search", h.handleSearchForGame).Methods("POST")
-	router.HandleFunc("/add-game-db/{id:[0-9]+}", h.handleAddGameToDB).Methods("POST")
+	router.HandleFunc("/games", utils.MakeHandler(h.handleGetAllGames)).Methods("GET")
+	router.HandleFunc("/games/search", utils.MakeHandler(h.handleSearchGames)).Methods("GET")
+	router.HandleFunc("/games/{id:[0-9]+}", utils.MakeHandler(h.handleGetGameByID)).Methods("GET")
+	router.HandleFunc("/games/{id:[0-9]+}", utils.MakeHandler(auth.RequireRole(auth.RoleAdmin, h.handleEditGame))).Methods("PUT")
+	router.HandleFunc("/games/{id:[0-9]+}", utils.MakeHandler(auth.RequireRole(auth.RoleAdmin, h.handleDeleteGame))).Methods("DELETE")
+	router.HandleFunc("/games/{id:[0-9]+}/achievements", utils.MakeHandler(h.handleGetAchievements)).Methods("GET")
+	router.HandleFunc("/games/{id:[0-9]+}/screenshots", utils.MakeHandler(h.handleGetScreenshots)).Methods("GET")
+	router.HandleFunc("/games/{id:[0-9]+}/resync", utils.MakeHandler(auth.RequireRole(auth.RoleAdmin, h.handleResyncGame))).Methods("POST")
+	router.HandleFunc("/game-search", utils.MakeHandler(h.handleSearchForGame)).Methods("POST")
+	router.HandleFunc("/add-game-db/{id:[0-9]+}", utils.MakeHandler(h.handleAddGameToDB)).Methods("POST")
 }
 
-func (h *Handler) handleGetAllGames(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleGetAllGames(w http.ResponseWriter, r *http.Request) error {
 	gs, err := h.store.GetAllGames()
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("error receiving games: %v", err))
-		return
+		return fmt.Errorf("error receiving games: %w", err)
 	}
 
-	utils.WriteJSON(w, http.StatusOK, gs)
+	return utils.WriteJSON(w, http.StatusOK, gs)
 }
 
-func (h *Handler) handleGetGameByID(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
+// What GET /games/{id} can load along with the game, all of them unless the
+// include query parameter lists some
+var gameIncludes = []string{"platforms", "genres", "achievements", "screenshots", "stats"}
 
-	id, err := strconv.Atoi(idStr)
+func (h *Handler) handleGetGameByID(w http.ResponseWriter, r *http.Request) error {
+	id, err := gameIDFromPath(r)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid game id: %v", err))
-		return
+		return err
+	}
+
+	include, err := parseInclude(r)
+	if err != nil {
+		return err
 	}
 
 	g, err := h.store.GetGameByID(uint(id))
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("error receiving game with id %d: %v", int(id), err))
-		return
+		return err
 	}
 
-	utils.WriteJSON(w, http.StatusOK, g)
+	if include["platforms"] {
+		if g.Platforms, err = h.store.GetGamePlatforms(g.ID); err != nil {
+			return err
+		}
+	}
+	if include["genres"] {
+		if g.Genres, err = h.store.GetGameGenres(g.ID); err != nil {
+			return err
+		}
+	}
+	if include["achievements"] {
+		achievements, err := h.achievementStore.GetAllAchievementsByGame(g.ID)
+		if err != nil {
+			return fmt.Errorf("error receiving achievements: %w", err)
+		}
+		g.Achievements = make([]models.Achievement, 0, len(achievements))
+		for _, a := range achievements {
+			g.Achievements = append(g.Achievements, *a)
+		}
+	}
+	if include["screenshots"] {
+		if g.Screenshots, err = h.screenshots(g.ID); err != nil {
+			return err
+		}
+	}
+	if include["stats"] {
+		if g.Stats, err = h.store.GetGameStats(g.ID); err != nil {
+			return err
+		}
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, g)
 }
 
-func (h *Handler) handleSearchForGame(w http.ResponseWriter, r *http.Request) {
-	// Extract the "val" query parameter
-	queryParams := r.URL.Query()
-	val := queryParams.Get("val")
+// parseInclude reads a comma separated include query parameter, such as
+// "platforms,stats". An empty one includes nothing.
+func parseInclude(r *http.Request) (map[string]bool, error) {
+	include := make(map[string]bool)
 
-	// Check if the query parameter is present
-	if val == "" {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing query parameter 'val'"))
-		return
+	params := r.URL.Query()
+	if !params.Has("include") {
+		for _, name := range gameIncludes {
+			include[name] = true
+		}
+		return include, nil
+	}
+
+	for _, name := range strings.Split(params.Get("include"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(gameIncludes, name) {
+			return nil, utils.Invalid("unknown include '%s', expected some of %s", name, strings.Join(gameIncludes, ", "))
+		}
+		include[name] = true
 	}
 
-	// Prepare the API request URL
-	rawgKey := config.Envs.RAWGKey
-	apiURL := "https://api.rawg.io/api/games"
-	reqURL, err := url.Parse(apiURL)
+	return include, nil
+}
+
+// handleGetAchievements lists a game's achievements, sorted by rarity when the
+// sort query parameter is "rarity" (rarest first) or "-rarity" (most common
+// first). Achievements of unknown rarity come last either way.
+func (h *Handler) handleGetAchievements(w http.ResponseWriter, r *http.Request) error {
+	id, err := gameIDFromPath(r)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to parse RAWG API URL: %v", err))
-		return
+		return err
 	}
 
-	// Set query parameters
-	query := reqURL.Query()
-	query.Set("key", rawgKey)
-	query.Set("search", val)
-	query.Set("page_size", "50")
-	reqURL.RawQuery = query.Encode()
+	g, err := h.store.GetGameByID(uint(id))
+	if err != nil {
+		return err
+	}
 
-	// Make the API request
-	resp, err := http.Get(reqURL.String())
+	var found []*models.Achievement
+	switch sort := r.URL.Query().Get("sort"); sort {
+	case "":
+		found, err = h.achievementStore.GetAllAchievementsByGame(g.ID)
+	case "rarity", "-rarity":
+		found, err = h.achievementStore.GetAchievementsByRarity(g.ID, sort == "rarity")
+	default:
+		return utils.Invalid("unknown sort '%s', expected rarity or -rarity", sort)
+	}
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to make API request: %v", err))
-		return
+		return fmt.Errorf("error receiving achievements: %w", err)
 	}
-	defer resp.Body.Close()
 
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
+	achievements := make([]models.Achievement, 0, len(found))
+	for _, a := range found {
+		achievements = append(achievements, *a)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, achievements)
+}
+
+func (h *Handler) handleGetScreenshots(w http.ResponseWriter, r *http.Request) error {
+	id, err := gameIDFromPath(r)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to read response body: %v", err))
-		return
+		return err
 	}
 
-	// Check if the response status is OK
-	if resp.StatusCode != http.StatusOK {
-		utils.WriteError(w, resp.StatusCode, fmt.Errorf("unexpected response status: %v", resp.Status))
-		return
+	g, err := h.store.GetGameByID(uint(id))
+	if err != nil {
+		return err
 	}
 
-	// Parse the JSON response
-	var gameResponse models.RAWGGameResponse
-	if err := json.Unmarshal(body, &gameResponse); err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to parse JSON response: %v", err))
-		return
+	screenshots, err := h.screenshots(g.ID)
+	if err != nil {
+		return err
 	}
 
-	// Create a list of ReturnSearchGamePayload objects
-	var filteredGames []models.ReturnSearchGamePayload
-	for _, game := range gameResponse.Results {
-		filteredGame := models.ReturnSearchGamePayload{
-			ID:       game.ID,
-			Name:     game.Name,
-			CoverURL: game.BackgroundIMG, // or other image URL field if necessary
-		}
-		filteredGames = append(filteredGames, filteredGame)
+	return utils.WriteJSON(w, http.StatusOK, screenshots)
+}
+
+// screenshots lists the game's screenshots, empty rather than nil when it has none
+func (h *Handler) screenshots(gameID uint32) ([]models.Screenshot, error) {
+	found, err := h.screenshotStore.GetScreenshotsByGame(gameID)
+	if err != nil {
+		return nil, fmt.Errorf("error receiving screenshots: %w", err)
 	}
 
-	// Respond with the filtered list of games
-	utils.WriteJSON(w, http.StatusOK, filteredGames)
+	screenshots := make([]models.Screenshot, 0, len(found))
+	for _, screenshot := range found {
+		screenshots = append(screenshots, *screenshot)
+	}
+
+	return screenshots, nil
 }
 
-func (h *Handler) handleAddGameToDB(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
+func (h *Handler) handleEditGame(w http.ResponseWriter, r *http.Request) error {
+	id, err := gameIDFromPath(r)
+	if err != nil {
+		return err
+	}
 
-	// Extract the "user" query parameter
-	queryParams := r.URL.Query()
-	userIDStr := queryParams.Get("user")
+	var payload models.EditGamePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
+	}
 
-	// Check if the query parameter is present
-	if userIDStr == "" {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing query parameter 'user'"))
-		return
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
 	}
 
-	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	g, err := h.store.GetGameByID(uint(id))
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("error parsing user ID: %v", err))
-		return
+		return err
 	}
 
-	// Prepare the API request URL
-	rawgKey := config.Envs.RAWGKey
-	apiURL := "https://api.rawg.io/api/games/" + idStr
-	reqURL, err := url.Parse(apiURL)
+	g.Name = payload.Name
+	g.Description = payload.Description
+	g.ReleaseDate = payload.ReleaseDate
+	g.BackgroundIMG = payload.BackgroundIMG
+	g.Rating = payload.Rating
+	g.Website = payload.Website
+
+	err = h.store.EditGame(*g)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to parse RAWG API URL: %v", err))
-		return
+		return err
 	}
 
-	// Set query parameters
-	query := reqURL.Query()
-	query.Set("key", rawgKey)
-	reqURL.RawQuery = query.Encode()
+	return utils.WriteJSON(w, http.StatusOK, g)
+}
 
-	// Make the API request
-	resp, err := http.Get(reqURL.String())
+func (h *Handler) handleDeleteGame(w http.ResponseWriter, r *http.Request) error {
+	id, err := gameIDFromPath(r)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to make API request: %v", err))
-		return
+		return err
+	}
+
+	if _, err := h.store.GetGameByID(uint(id)); err != nil {
+		return err
 	}
-	defer resp.Body.Close()
 
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
+	err = h.store.DeleteGame(uint32(id))
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to read response body: %v", err))
-		return
+		return err
 	}
 
-	// Check if the response status is OK
-	if resp.StatusCode != http.StatusOK {
-		utils.WriteError(w, resp.StatusCode, fmt.Errorf("unexpected response status: %v", resp.Status))
-		return
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+// handleResyncGame queues a refresh of the game from RAWG. The job's result
+// is a ResyncReport of what changed.
+func (h *Handler) handleResyncGame(w http.ResponseWriter, r *http.Request) error {
+	id, err := gameIDFromPath(r)
+	if err != nil {
+		return err
 	}
 
-	var game models.GameResponse
-	err = json.Unmarshal(body, &game)
+	userID, err := auth.ActingUserID(r, 0)
 	if err != nil {
-		fmt.Println("Error unmarshalling JSON:", err)
-		return
-	}
-
-	// Read initial game information into the database
-	initial_game := models.Game{
-		ID:            uint32(game.ID),
-		RAWGID:        uint(game.ID),
-		Name:          game.Name,
-		Slug:          game.Slug,
-		Description:   game.Description,
-		ReleaseDate:   game.Released,
-		BackgroundIMG: game.BackgroundImage,
-		Rating:        uint(game.Metacritic),
-		Website:       game.Website,
-		CreatedAt:     time.Now(),
-	}
-	add_game, err := h.store.AddGame(initial_game)
+		return err
+	}
+
+	g, err := h.store.GetGameByID(uint(id))
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to add game: %v", err))
-		return
+		return err
 	}
 
-	// Read platforms into the database
-	for _, platformReq := range game.Platforms {
-		platform := platformReq.Platform
-		name := platform.Name
+	job, err := h.jobs.Enqueue(models.Job{Kind: models.JobResyncGame, UserID: userID, RAWGID: g.RAWGID})
+	if err != nil {
+		return err
+	}
 
-		err = h.store.AddGamePlatform(name, add_game.ID)
-		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to add platform: %v", err))
-			return
+	return utils.WriteJSON(w, http.StatusAccepted, job)
+}
+
+func (h *Handler) handleSearchForGame(w http.ResponseWriter, r *http.Request) error {
+	// Extract the "val" query parameter
+	queryParams := r.URL.Query()
+	val := queryParams.Get("val")
+
+	// Check if the query parameter is present
+	if val == "" {
+		return utils.Invalid("missing query parameter 'val'")
+	}
+
+	// Clients can ask for fresh results past the RAWG cache
+	ctx := r.Context()
+	if r.Header.Get("Cache-Control") == "no-cache" {
+		ctx = rawg.WithoutCache(ctx)
+	}
+
+	games, err := h.rawg.SearchGames(ctx, val)
+	if err != nil {
+		return err
+	}
+
+	// Create a list of ReturnSearchGamePayload objects
+	var filteredGames []models.ReturnSearchGamePayload
+	for _, game := range games {
+		filteredGame := models.ReturnSearchGamePayload{
+			ID:       game.ID,
+			Name:     game.Name,
+			CoverURL: game.BackgroundIMG, // or other image URL field if necessary
 		}
+		filteredGames = append(filteredGames, filteredGame)
 	}
 
-	// Read genres into the database
-	for _, genreReq := range game.Genres {
-		genre := genreReq.Name
+	// Respond with the filtered list of games
+	return utils.WriteJSON(w, http.StatusOK, filteredGames)
+}
 
-		err = h.store.AddGameGenre(genre, add_game.ID)
-		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to add genre: %v", err))
-			return
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// handleSearchGames searches the catalog and, when it has fewer games than
+// asked for, RAWG. Games are listed once, catalog matches first, and say
+// whether they are imported and tracked by the user searching.
+func (h *Handler) handleSearchGames(w http.ResponseWriter, r *http.Request) error {
+	params := r.URL.Query()
+
+	query := strings.TrimSpace(params.Get("q"))
+	if query == "" {
+		return utils.Invalid("missing query parameter 'q'")
+	}
+
+	limit := defaultSearchLimit
+	if l := params.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			return utils.Invalid("limit must be a number from 1 to %d", maxSearchLimit)
 		}
+		limit = n
 	}
 
-	// Track game for user
-	err = h.userStore.TrackGame(uint32(userID), add_game.ID)
+	filter := models.GameSearchFilter{Platform: params.Get("platform"), Genre: params.Get("genre")}
+
+	games, err := h.store.SearchGames(query, filter, limit)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("error tracking game: %v", err))
-		return
+		return err
 	}
 
-	// Read achievements into the database with pagination
-	apiURL = "https://api.rawg.io/api/games/" + idStr + "/achievements"
-	reqURL, err = url.Parse(apiURL)
-	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to parse RAWG API URL: %v", err))
-		return
+	results := make([]models.GameSearchResult, 0, limit)
+	seen := make(map[uint]bool)
+	for _, g := range games {
+		results = append(results, catalogResult(g))
+		seen[g.RAWGID] = true
 	}
 
-	finished := false
-	for !finished {
-		// Set query parameters
-		query = reqURL.Query()
-		query.Set("key", rawgKey)
-		reqURL.RawQuery = query.Encode()
+	if len(results) < limit {
+		ctx := r.Context()
+		if r.Header.Get("Cache-Control") == "no-cache" {
+			ctx = rawg.WithoutCache(ctx)
+		}
 
-		// Make the API request
-		resp, err = http.Get(reqURL.String())
+		found, err := h.rawg.SearchGames(ctx, query)
 		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to make API request: %v", err))
-			return
+			// The catalog's games are better than an error
+			if len(results) == 0 {
+				return err
+			}
+			log.Printf("game search: %v", err)
 		}
-		defer resp.Body.Close()
 
-		// Read the response body
-		body, err = io.ReadAll(resp.Body)
+		fromRAWG, err := h.rawgResults(found, filter, seen, limit-len(results))
 		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to read response body: %v", err))
-			return
+			return err
 		}
+		results = append(results, fromRAWG...)
+	}
 
-		// Check if the response status is OK
-		if resp.StatusCode != http.StatusOK {
-			utils.WriteError(w, resp.StatusCode, fmt.Errorf("unexpected response status: %v", resp.Status))
-			return
+	if userID := auth.GetUserIDFromContext(r.Context()); userID != 0 {
+		userGames, err := h.userStore.GetAllUserGames(userID)
+		if err != nil {
+			return err
 		}
 
-		// Decode the JSON from the body
-		var data struct {
-			Results []models.Achievement `json:"results"`
-			Next    string               `json:"next"`
+		tracked := make(map[uint32]bool, len(userGames))
+		for _, ug := range userGames {
+			tracked[ug.GameID] = true
 		}
-		if err := json.Unmarshal(body, &data); err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("error decoding json: %v", err))
-			return
+		for i := range results {
+			results[i].Tracked = results[i].Imported && tracked[results[i].GameID]
 		}
+	}
 
-		// Insert achievements into the database
-		for _, achievement := range data.Results {
-			achievement.GameID = uint(add_game.ID)
-			achID, err := h.store.AddAchievement(achievement)
-			if err != nil {
-				utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("error adding achievement: %v", err))
-				return
-			}
-			if achID == -1 {
-				utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("error adding achievement"))
-				return
-			}
-			err = h.store.AddUserAchievement(uint32(userID), add_game.ID, uint32(achID))
-			if err != nil {
-				utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("error adding achievement: %v", err))
-				return
-			}
+	return utils.WriteJSON(w, http.StatusOK, results)
+}
+
+// rawgResults turns up to limit RAWG games that pass the filter and weren't
+// already found into results, using the catalog's copy of those imported
+// since
+func (h *Handler) rawgResults(found []models.RAWGGame, filter models.GameSearchFilter, seen map[uint]bool, limit int) ([]models.GameSearchResult, error) {
+	var results []models.GameSearchResult
+	var rawgIDs []uint
+	for _, g := range found {
+		if len(results) == limit {
+			break
+		}
+		if seen[g.ID] || !rawgGameMatches(g, filter) {
+			continue
+		}
+		seen[g.ID] = true
+
+		result := models.GameSearchResult{
+			RAWGID:      g.ID,
+			Name:        g.Name,
+			Slug:        g.Slug,
+			CoverURL:    g.BackgroundIMG,
+			ReleaseDate: g.Released,
+		}
+		for _, p := range g.Platforms {
+			result.Platforms = append(result.Platforms, p.Platform.Name)
+		}
+		for _, genre := range g.Genres {
+			result.Genres = append(result.Genres, genre.Name)
 		}
 
-		// Check if there are more pages
-		if data.Next == "" {
-			finished = true
-		} else {
-			// Update the URL for the next request
-			reqURL, err = url.Parse(data.Next)
-			if err != nil {
-				utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("failed to parse next page URL: %v", err))
-				return
-			}
+		results = append(results, result)
+		rawgIDs = append(rawgIDs, g.ID)
+	}
+
+	imported, err := h.store.GetGamesByRAWGIDs(rawgIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byRAWGID := make(map[uint]*models.Game, len(imported))
+	for _, g := range imported {
+		byRAWGID[g.RAWGID] = g
+	}
+	for i := range results {
+		if g, ok := byRAWGID[results[i].RAWGID]; ok {
+			results[i] = catalogResult(g)
 		}
 	}
+
+	return results, nil
+}
+
+func catalogResult(g *models.Game) models.GameSearchResult {
+	result := models.GameSearchResult{
+		RAWGID:      g.RAWGID,
+		GameID:      g.ID,
+		Name:        g.Name,
+		Slug:        g.Slug,
+		CoverURL:    g.BackgroundIMG,
+		ReleaseDate: g.ReleaseDate,
+		Genres:      g.Genres,
+		Imported:    true,
+	}
+	for _, p := range g.Platforms {
+		result.Platforms = append(result.Platforms, p.Name)
+	}
+
+	return result
+}
+
+// rawgGameMatches reports whether RAWG lists the game on the filter's
+// platform and in its genre
+func rawgGameMatches(g models.RAWGGame, filter models.GameSearchFilter) bool {
+	if filter.Platform != "" {
+		found := false
+		for _, p := range g.Platforms {
+			found = found || strings.EqualFold(p.Platform.Name, filter.Platform)
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if filter.Genre != "" {
+		found := false
+		for _, genre := range g.Genres {
+			found = found || strings.EqualFold(genre.Name, filter.Genre)
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (h *Handler) handleAddGameToDB(w http.ResponseWriter, r *http.Request) error {
+	rawgID, err := gameIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	// The "user" query parameter is optional, the game is tracked for the authenticated user
+	var suppliedUserID uint64
+	if userIDStr := r.URL.Query().Get("user"); userIDStr != "" {
+		suppliedUserID, err = strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil {
+			return utils.Invalid("error parsing user ID: %v", err)
+		}
+	}
+
+	userID, err := auth.ActingUserID(r, uint32(suppliedUserID))
+	if err != nil {
+		return err
+	}
+
+	// A game already in the catalog only needs tracking, which is quick
+	existing, err := h.store.GetGameByRAWGID(uint(rawgID))
+	if err == nil {
+		game, _, err := h.store.ImportGame(models.GameImport{Game: *existing}, userID)
+		if err != nil {
+			return err
+		}
+
+		return utils.WriteJSON(w, http.StatusOK, game)
+	}
+	if !errors.Is(err, utils.ErrNotFound) {
+		return err
+	}
+
+	// Fetching every page of achievements can take a while, so that happens in the background
+	job, err := h.jobs.Enqueue(models.Job{Kind: models.JobImportGame, UserID: userID, RAWGID: uint(rawgID)})
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusAccepted, job)
+}
+
+func gameIDFromPath(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, utils.Invalid("invalid game id: %v", err)
+	}
+	return id, nil
 }
@@ -0,0 +1,714 @@
+package game
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/rawg"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/rawg/rawgtest"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestGameSearch(t *testing.T) {
+	server := rawgtest.NewServer(t)
+	handler := NewHandler(newMockGameStore(), &mockUserGameStore{}, &mockAchievementStore{}, &mockScreenshotStore{}, rawg.NewClient(server.ClientConfig()), &mockJobQueue{})
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	t.Run("should return matching RAWG games", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/game-search?val=witcher", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var games []models.ReturnSearchGamePayload
+		if err := json.NewDecoder(rr.Body).Decode(&games); err != nil {
+			t.Fatal(err)
+		}
+		if len(games) != 3 || games[0].ID != rawgtest.Witcher3 || games[0].CoverURL == "" {
+			t.Errorf("expected the three Witcher games, got %+v", games)
+		}
+	})
+
+	t.Run("should fail if the search is missing", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/game-search", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should return a 502 when RAWG fails", func(t *testing.T) {
+		server.Fail("/games", http.StatusInternalServerError)
+
+		req, err := http.NewRequest(http.MethodPost, "/game-search?val=witcher", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadGateway {
+			t.Errorf("expected status code %d, got %d. Response body: %s", http.StatusBadGateway, rr.Code, rr.Body.String())
+		}
+	})
+}
+
+func TestSearchGames(t *testing.T) {
+	server := rawgtest.NewServer(t)
+	store := newMockGameStore()
+	store.games[1] = &models.Game{ID: 1, RAWGID: rawgtest.Witcher3, Name: "The Witcher 3: Wild Hunt", Slug: "the-witcher-3-wild-hunt",
+		Platforms: []models.Platform{{Name: "PC"}, {Name: "PlayStation 4"}}, Genres: []string{"Action", "RPG"}}
+	store.games[2] = &models.Game{ID: 2, RAWGID: 10035, Name: "Assassins of Kings", Slug: "assassins-of-kings",
+		Platforms: []models.Platform{{Name: "PC"}}, Genres: []string{"RPG"}}
+	userGames := &mockUserGameStore{games: []*models.UserGame{{UserID: 1, GameID: 2}}}
+
+	handler := NewHandler(store, userGames, &mockAchievementStore{}, &mockScreenshotStore{}, rawg.NewClient(server.ClientConfig()), &mockJobQueue{})
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	search := func(t *testing.T, path string, userID uint32) []models.GameSearchResult {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if userID != 0 {
+			req = withUser(req, userID)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var results []models.GameSearchResult
+		if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	t.Run("should list catalog games first and RAWG games once", func(t *testing.T) {
+		results := search(t, "/games/search?q=witcher", 1)
+
+		if len(results) != 3 {
+			t.Fatalf("expected 3 results, got %+v", results)
+		}
+		// RAWG knows Witcher 2 by a name the catalog doesn't
+		first, second, third := results[0], results[1], results[2]
+		if first.GameID != 1 || !first.Imported || first.Tracked {
+			t.Errorf("expected the catalog's Witcher 3, untracked, got %+v", first)
+		}
+		if second.GameID != 2 || !second.Imported || !second.Tracked {
+			t.Errorf("expected the catalog's Witcher 2, tracked, got %+v", second)
+		}
+		if third.RAWGID != 5563 || third.Imported || third.GameID != 0 || len(third.Platforms) == 0 {
+			t.Errorf("expected the Witcher from RAWG, got %+v", third)
+		}
+	})
+
+	t.Run("should filter by platform and genre", func(t *testing.T) {
+		results := search(t, "/games/search?q=witcher&platform=Xbox+360", 0)
+		if len(results) != 1 || results[0].RAWGID != 10035 {
+			t.Errorf("expected only Witcher 2 on Xbox 360, got %+v", results)
+		}
+
+		results = search(t, "/games/search?q=witcher&platform=playstation+4&genre=rpg", 0)
+		if len(results) != 1 || results[0].GameID != 1 {
+			t.Errorf("expected only Witcher 3 on PlayStation 4, got %+v", results)
+		}
+	})
+
+	t.Run("should not ask RAWG when the catalog has enough games", func(t *testing.T) {
+		before := server.Requests("/games")
+		results := search(t, "/games/search?q=witcher&limit=1", 0)
+
+		if len(results) != 1 || results[0].GameID != 1 {
+			t.Errorf("expected the catalog's Witcher 3, got %+v", results)
+		}
+		if n := server.Requests("/games"); n != before {
+			t.Errorf("expected no requests to RAWG, got %d", n-before)
+		}
+	})
+
+	t.Run("should return catalog games when RAWG fails", func(t *testing.T) {
+		server.Fail("/games", http.StatusServiceUnavailable)
+		defer server.Recover("/games")
+
+		results := search(t, "/games/search?q=witcher", 0)
+		if len(results) != 1 || results[0].GameID != 1 {
+			t.Errorf("expected the catalog's Witcher 3, got %+v", results)
+		}
+	})
+
+	t.Run("should fail without a search", func(t *testing.T) {
+		for _, path := range []string{"/games/search", "/games/search?q=witcher&limit=100"} {
+			req, err := http.NewRequest(http.MethodGet, path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status code %d, got %d", path, http.StatusBadRequest, rr.Code)
+			}
+		}
+	})
+}
+
+func TestAddGameToDB(t *testing.T) {
+	store := newMockGameStore()
+	jobs := &mockJobQueue{}
+	handler := NewHandler(store, &mockUserGameStore{}, &mockAchievementStore{}, &mockScreenshotStore{}, nil, jobs)
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	addGame := func(rawgID int, userID uint32) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/add-game-db/%d", rawgID), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if userID != 0 {
+			req = withUser(req, userID)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should fail without an authenticated user", func(t *testing.T) {
+		rr := addGame(rawgtest.Witcher3, 0)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+		if len(jobs.queued) != 0 {
+			t.Errorf("expected no job to be queued, got %+v", jobs.queued)
+		}
+	})
+
+	t.Run("should queue an import for a new game", func(t *testing.T) {
+		rr := addGame(rawgtest.Witcher3, 1)
+
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+		}
+
+		var job models.Job
+		if err := json.NewDecoder(rr.Body).Decode(&job); err != nil {
+			t.Fatal(err)
+		}
+		if job.ID == 0 || job.Kind != models.JobImportGame || job.UserID != 1 || job.RAWGID != rawgtest.Witcher3 || job.Status != models.JobQueued {
+			t.Errorf("unexpected job %+v", job)
+		}
+		if len(store.games) != 0 {
+			t.Errorf("expected the import to be left to the job, got %+v", store.games)
+		}
+	})
+
+	t.Run("should track a game that is already imported straight away", func(t *testing.T) {
+		store.games[1] = &models.Game{ID: 1, RAWGID: rawgtest.HollowKnight, Name: "Hollow Knight"}
+
+		rr := addGame(rawgtest.HollowKnight, 2)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if !store.tracked[[2]uint32{2, 1}] {
+			t.Errorf("expected the game to be tracked for user 2, got %v", store.tracked)
+		}
+		if len(jobs.queued) != 1 {
+			t.Errorf("expected no further jobs, got %+v", jobs.queued)
+		}
+	})
+}
+
+func TestGetGameByID(t *testing.T) {
+	store := newMockGameStore()
+	store.games[7] = &models.Game{ID: 7, RAWGID: rawgtest.Witcher3, Name: "The Witcher 3: Wild Hunt",
+		Platforms: []models.Platform{{ID: 4, Name: "PlayStation 4"}, {ID: 14, Name: "PC"}}, Genres: []string{"Action", "RPG"}}
+	store.stats[7] = &models.GameStats{Trackers: 3, Platinums: 1}
+	common, uncommon := 84.12, 39.77
+	achievements := &mockAchievementStore{achievements: map[uint32][]*models.Achievement{
+		7: {{ID: 1, Name: "Lilac and Gooseberries", Percent: &common, GameID: 7}, {ID: 2, Name: "Kaer Morhen Forever", Percent: &uncommon, GameID: 7}},
+	}}
+	screenshots := &mockScreenshotStore{screenshots: map[uint32][]*models.Screenshot{
+		7: {{ID: 1, GameID: 7, ImgURL: "https://media.rawg.io/1.jpg"}},
+	}}
+	handler := NewHandler(store, &mockUserGameStore{}, achievements, screenshots, nil, &mockJobQueue{})
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	getGame := func(path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	decode := func(t *testing.T, rr *httptest.ResponseRecorder) models.Game {
+		t.Helper()
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var game models.Game
+		if err := json.NewDecoder(rr.Body).Decode(&game); err != nil {
+			t.Fatal(err)
+		}
+		return game
+	}
+
+	t.Run("should return the whole game by default", func(t *testing.T) {
+		game := decode(t, getGame("/games/7"))
+
+		if len(game.Platforms) != 2 || len(game.Genres) != 2 || len(game.Screenshots) != 1 {
+			t.Errorf("expected platforms, genres and screenshots, got %+v", game)
+		}
+		if len(game.Achievements) != 2 || game.Achievements[1].Percent == nil || *game.Achievements[1].Percent != 39.77 {
+			t.Errorf("expected both achievements with their rarity, got %+v", game.Achievements)
+		}
+		if game.Stats == nil || game.Stats.Trackers != 3 || game.Stats.Platinums != 1 {
+			t.Errorf("expected 3 trackers and 1 platinum, got %+v", game.Stats)
+		}
+	})
+
+	t.Run("should only load what is included", func(t *testing.T) {
+		game := decode(t, getGame("/games/7?include=genres,stats"))
+
+		if game.Platforms != nil || game.Achievements != nil || game.Screenshots != nil {
+			t.Errorf("expected only genres and stats, got %+v", game)
+		}
+		if len(game.Genres) != 2 || game.Stats == nil {
+			t.Errorf("expected genres and stats, got %+v", game)
+		}
+
+		game = decode(t, getGame("/games/7?include="))
+		if game.Name != "The Witcher 3: Wild Hunt" || game.Genres != nil || game.Stats != nil {
+			t.Errorf("expected only the game, got %+v", game)
+		}
+	})
+
+	t.Run("should fail for an unknown include", func(t *testing.T) {
+		if rr := getGame("/games/7?include=reviews"); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should fail for an unknown game", func(t *testing.T) {
+		if rr := getGame("/games/8"); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+func TestGetAchievements(t *testing.T) {
+	store := newMockGameStore()
+	store.games[7] = &models.Game{ID: 7, Name: "The Witcher 3: Wild Hunt"}
+	store.games[8] = &models.Game{ID: 8, Name: "Hollow Knight"}
+	common, rare := 84.12, 5.5
+	achievements := &mockAchievementStore{achievements: map[uint32][]*models.Achievement{
+		7: {
+			{ID: 1, Name: "Lilac and Gooseberries", Percent: &common, GameID: 7},
+			{ID: 2, Name: "Gwent Master", GameID: 7},
+			{ID: 3, Name: "Walk on the Wild Side", Percent: &rare, GameID: 7},
+		},
+	}}
+	handler := NewHandler(store, &mockUserGameStore{}, achievements, &mockScreenshotStore{}, nil, &mockJobQueue{})
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	ids := func(t *testing.T, rr *httptest.ResponseRecorder) []uint32 {
+		t.Helper()
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var achievements []models.Achievement
+		if err := json.NewDecoder(rr.Body).Decode(&achievements); err != nil {
+			t.Fatal(err)
+		}
+		ids := []uint32{}
+		for _, a := range achievements {
+			ids = append(ids, a.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/games/7/achievements", "[1 2 3]"},
+		{"/games/7/achievements?sort=rarity", "[3 1 2]"},
+		{"/games/7/achievements?sort=-rarity", "[1 3 2]"},
+		{"/games/8/achievements", "[]"},
+	}
+	for _, tt := range tests {
+		t.Run("should list "+tt.path, func(t *testing.T) {
+			if got := fmt.Sprint(ids(t, get(tt.path))); got != tt.expected {
+				t.Errorf("expected achievements %s, got %s", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("should fail for an unknown sort", func(t *testing.T) {
+		if rr := get("/games/7/achievements?sort=name"); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should fail for an unknown game", func(t *testing.T) {
+		if rr := get("/games/9/achievements"); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+func TestGetScreenshots(t *testing.T) {
+	store := newMockGameStore()
+	store.games[7] = &models.Game{ID: 7, Name: "The Witcher 3: Wild Hunt"}
+	store.games[8] = &models.Game{ID: 8, Name: "Hollow Knight"}
+	screenshots := &mockScreenshotStore{screenshots: map[uint32][]*models.Screenshot{
+		7: {
+			{ID: 1, GameID: 7, RAWGID: 30336, ImgURL: "https://media.rawg.io/1.jpg", Width: 1920, Height: 1080},
+			{ID: 2, GameID: 7, RAWGID: 30337, ImgURL: "https://media.rawg.io/2.jpg", Width: 1920, Height: 1080},
+		},
+	}}
+	handler := NewHandler(store, &mockUserGameStore{}, &mockAchievementStore{}, screenshots, nil, &mockJobQueue{})
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	get := func(t *testing.T, path string, v any) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if err := json.NewDecoder(rr.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("should list a game's screenshots", func(t *testing.T) {
+		var found []models.Screenshot
+		get(t, "/games/7/screenshots", &found)
+
+		if len(found) != 2 || found[0].ImgURL != "https://media.rawg.io/1.jpg" || found[0].Width != 1920 {
+			t.Errorf("expected both screenshots, got %+v", found)
+		}
+	})
+
+	t.Run("should include the gallery in the game", func(t *testing.T) {
+		var game models.Game
+		get(t, "/games/7", &game)
+
+		if len(game.Screenshots) != 2 || game.Screenshots[1].RAWGID != 30337 {
+			t.Errorf("expected both screenshots, got %+v", game.Screenshots)
+		}
+	})
+
+	t.Run("should list no screenshots as empty", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/games/8/screenshots", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if body := strings.TrimSpace(rr.Body.String()); body != "[]" {
+			t.Errorf("expected an empty list, got %s", body)
+		}
+	})
+
+	t.Run("should fail for an unknown game", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/games/9/screenshots", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+func TestResyncGame(t *testing.T) {
+	store := newMockGameStore()
+	store.games[7] = &models.Game{ID: 7, RAWGID: rawgtest.Witcher3, Name: "The Witcher 3: Wild Hunt"}
+	jobs := &mockJobQueue{}
+	handler := NewHandler(store, &mockUserGameStore{}, &mockAchievementStore{}, &mockScreenshotStore{}, nil, jobs)
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	resync := func(path string, role auth.Role) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = withUser(req, 1)
+		req = req.WithContext(context.WithValue(req.Context(), auth.RoleKey, role))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should be admin only", func(t *testing.T) {
+		if rr := resync("/games/7/resync", auth.RoleUser); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should fail for an unknown game", func(t *testing.T) {
+		if rr := resync("/games/8/resync", auth.RoleAdmin); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should queue a resync", func(t *testing.T) {
+		rr := resync("/games/7/resync", auth.RoleAdmin)
+
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusAccepted, rr.Code, rr.Body.String())
+		}
+		if len(jobs.queued) != 1 || jobs.queued[0].Kind != models.JobResyncGame || jobs.queued[0].RAWGID != rawgtest.Witcher3 {
+			t.Errorf("expected a resync job for the game, got %+v", jobs.queued)
+		}
+	})
+}
+
+// withUser authenticates the request as the given user, the way the auth middleware would
+func withUser(req *http.Request, userID uint32) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), auth.UserKey, userID))
+}
+
+type mockGameStore struct {
+	models.GameStore
+	games   map[uint32]*models.Game
+	stats   map[uint32]*models.GameStats
+	tracked map[[2]uint32]bool
+}
+
+func newMockGameStore() *mockGameStore {
+	return &mockGameStore{
+		games:   make(map[uint32]*models.Game),
+		stats:   make(map[uint32]*models.GameStats),
+		tracked: make(map[[2]uint32]bool),
+	}
+}
+
+// GetGameByID returns only the game's row, like the real store
+func (s *mockGameStore) GetGameByID(id uint) (*models.Game, error) {
+	g, ok := s.games[uint32(id)]
+	if !ok {
+		return nil, utils.NotFound("game not found with id '%d'", id)
+	}
+	row := *g
+	row.Platforms, row.Genres = nil, nil
+	return &row, nil
+}
+
+func (s *mockGameStore) GetGamePlatforms(gameID uint32) ([]models.Platform, error) {
+	return s.games[gameID].Platforms, nil
+}
+
+func (s *mockGameStore) GetGameGenres(gameID uint32) ([]string, error) {
+	return s.games[gameID].Genres, nil
+}
+
+func (s *mockGameStore) GetGameStats(gameID uint32) (*models.GameStats, error) {
+	if stats, ok := s.stats[gameID]; ok {
+		return stats, nil
+	}
+	return &models.GameStats{}, nil
+}
+
+func (s *mockGameStore) GetGameByRAWGID(rawgID uint) (*models.Game, error) {
+	for _, g := range s.games {
+		if g.RAWGID == rawgID {
+			return g, nil
+		}
+	}
+	return nil, utils.NotFound("game not found with rawg id '%d'", rawgID)
+}
+
+func (s *mockGameStore) ImportGame(imp models.GameImport, userID uint32) (*models.Game, bool, error) {
+	game, err := s.GetGameByRAWGID(imp.Game.RAWGID)
+	created := err != nil
+	if created {
+		game = &imp.Game
+		game.ID = uint32(len(s.games) + 1)
+		s.games[game.ID] = game
+	}
+
+	s.tracked[[2]uint32{userID, game.ID}] = true
+	return game, created, nil
+}
+
+// SearchGames matches games whose name contains the query
+func (s *mockGameStore) SearchGames(query string, filter models.GameSearchFilter, limit int) ([]*models.Game, error) {
+	var games []*models.Game
+	for id := uint32(1); id <= uint32(len(s.games)); id++ {
+		g, ok := s.games[id]
+		if !ok || !strings.Contains(strings.ToLower(g.Name), strings.ToLower(query)) || len(games) == limit {
+			continue
+		}
+
+		onPlatform := filter.Platform == ""
+		for _, p := range g.Platforms {
+			onPlatform = onPlatform || strings.EqualFold(p.Name, filter.Platform)
+		}
+		inGenre := filter.Genre == ""
+		for _, genre := range g.Genres {
+			inGenre = inGenre || strings.EqualFold(genre, filter.Genre)
+		}
+
+		if onPlatform && inGenre {
+			games = append(games, g)
+		}
+	}
+	return games, nil
+}
+
+func (s *mockGameStore) GetGamesByRAWGIDs(rawgIDs []uint) ([]*models.Game, error) {
+	var games []*models.Game
+	for _, id := range rawgIDs {
+		if g, err := s.GetGameByRAWGID(id); err == nil {
+			games = append(games, g)
+		}
+	}
+	return games, nil
+}
+
+type mockUserGameStore struct {
+	models.UserGameStore
+	games []*models.UserGame
+}
+
+func (s *mockUserGameStore) GetAllUserGames(userID uint32) ([]*models.UserGame, error) {
+	var games []*models.UserGame
+	for _, ug := range s.games {
+		if ug.UserID == userID {
+			games = append(games, ug)
+		}
+	}
+	return games, nil
+}
+
+type mockAchievementStore struct {
+	models.AchievementStore
+	achievements map[uint32][]*models.Achievement
+}
+
+func (s *mockAchievementStore) GetAllAchievementsByGame(gameID uint32) ([]*models.Achievement, error) {
+	return s.achievements[gameID], nil
+}
+
+// GetAchievementsByRarity sorts like the store, unknown rarities last
+func (s *mockAchievementStore) GetAchievementsByRarity(gameID uint32, rarestFirst bool) ([]*models.Achievement, error) {
+	sorted := slices.Clone(s.achievements[gameID])
+	slices.SortStableFunc(sorted, func(a, b *models.Achievement) int {
+		switch {
+		case a.Percent == nil || b.Percent == nil:
+			return cmp.Compare(boolToInt(a.Percent == nil), boolToInt(b.Percent == nil))
+		case rarestFirst:
+			return cmp.Compare(*a.Percent, *b.Percent)
+		default:
+			return cmp.Compare(*b.Percent, *a.Percent)
+		}
+	})
+	return sorted, nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type mockScreenshotStore struct {
+	screenshots map[uint32][]*models.Screenshot
+}
+
+func (s *mockScreenshotStore) GetScreenshotsByGame(gameID uint32) ([]*models.Screenshot, error) {
+	return s.screenshots[gameID], nil
+}
+
+type mockJobQueue struct {
+	queued []models.Job
+}
+
+func (q *mockJobQueue) Enqueue(job models.Job) (*models.Job, error) {
+	job.ID = uint32(len(q.queued) + 1)
+	job.Status = models.JobQueued
+	q.queued = append(q.queued, job)
+	return &job, nil
+}
@@ -2,10 +2,15 @@ package game
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/achievement"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
 )
 
 type Store struct {
@@ -17,7 +22,7 @@ func NewStore(db *sql.DB) *Store {
 }
 
 func (s *Store) GetAllGames() ([]*models.Game, error) {
-	rows, err := s.db.Query("SELECT * FROM games")
+	rows, err := s.db.Query("SELECT id, rawg_id, name, slug, description, release_date, background_img, rating, website, created_at FROM games")
 	if err != nil {
 		return nil, err
 	}
@@ -40,85 +45,396 @@ func (s *Store) GetAllGames() ([]*models.Game, error) {
 }
 
 func (s *Store) GetGameByID(id uint) (*models.Game, error) {
-	row := s.db.QueryRow("SELECT * FROM games WHERE id = ?", id)
+	row := s.db.QueryRow("SELECT id, rawg_id, name, slug, description, release_date, background_img, rating, website, created_at FROM games WHERE id = ?", id)
 	var game models.Game
 	err := scanGame(row, &game)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("game not found with id '%d'", id)
+		}
 		return nil, err
 	}
 	return &game, nil
 }
 
-func (s *Store) AddGame(game models.Game) (models.Game, error) {
-	result, err := s.db.Exec("INSERT INTO games (rawg_id, name, slug, description, release_date, background_img, rating, website, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		game.RAWGID, game.Name, game.Slug, game.Description, game.ReleaseDate, game.BackgroundIMG, game.Rating, game.Website, game.CreatedAt)
+func (s *Store) GetGameByRAWGID(rawgID uint) (*models.Game, error) {
+	row := s.db.QueryRow("SELECT id, rawg_id, name, slug, description, release_date, background_img, rating, website, created_at FROM games WHERE rawg_id = ?", rawgID)
+	var game models.Game
+	err := scanGame(row, &game)
 	if err != nil {
-		return models.Game{}, err
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("game not found with rawg id '%d'", rawgID)
+		}
+		return nil, err
 	}
+	return &game, nil
+}
 
-	lastID, err := result.LastInsertId()
+func (s *Store) SearchGames(query string, filter models.GameSearchFilter, limit int) ([]*models.Game, error) {
+	query = strings.TrimSpace(query)
+	pattern := "%" + escapeLike(query) + "%"
+	slugPattern := "%" + escapeLike(strings.Join(strings.Fields(strings.ToLower(query)), "-")) + "%"
+
+	// FULLTEXT finds the words in any order, LIKE catches partial words and
+	// words too short for the index while the user is still typing
+	where := "(g.name LIKE ? OR g.slug LIKE ?"
+	args := []interface{}{pattern, slugPattern}
+	terms := fulltextTerms(query)
+	if terms != "" {
+		where += " OR MATCH(g.name, g.slug) AGAINST (? IN BOOLEAN MODE)"
+		args = append(args, terms)
+	}
+	where += ")"
+
+	if filter.Platform != "" {
+		where += " AND EXISTS (SELECT 1 FROM game_platforms gp JOIN platforms p ON p.id = gp.platform_id WHERE gp.game_id = g.id AND p.name = ?)"
+		args = append(args, filter.Platform)
+	}
+	if filter.Genre != "" {
+		where += " AND EXISTS (SELECT 1 FROM game_genres gg WHERE gg.game_id = g.id AND gg.genre = ?)"
+		args = append(args, filter.Genre)
+	}
+
+	// Exact names first, then names starting with the query, then by relevance
+	order := "g.name = ? DESC, g.name LIKE ? DESC"
+	args = append(args, query, escapeLike(query)+"%")
+	if terms != "" {
+		order += ", MATCH(g.name, g.slug) AGAINST (? IN BOOLEAN MODE) DESC"
+		args = append(args, terms)
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.Query("SELECT g.id, g.rawg_id, g.name, g.slug, g.description, g.release_date, g.background_img, g.rating, g.website, g.created_at FROM games g WHERE "+
+		where+" ORDER BY "+order+", g.name LIMIT ?", args...)
 	if err != nil {
-		return models.Game{}, err
+		return nil, fmt.Errorf("failed to search games: %v", err)
 	}
+	defer rows.Close()
 
-	var insertedGame models.Game
-	err = s.db.QueryRow("SELECT id, rawg_id, name, slug, description, release_date, background_img, rating, website, created_at FROM games WHERE id = ?", lastID).Scan(
-		&insertedGame.ID, &insertedGame.RAWGID, &insertedGame.Name, &insertedGame.Slug, &insertedGame.Description,
-		&insertedGame.ReleaseDate, &insertedGame.BackgroundIMG, &insertedGame.Rating, &insertedGame.Website, &insertedGame.CreatedAt,
-	)
+	var games []*models.Game
+	for rows.Next() {
+		var game models.Game
+		if err := scanGame(rows, &game); err != nil {
+			return nil, err
+		}
+		games = append(games, &game)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadPlatformsAndGenres(games); err != nil {
+		return nil, err
+	}
+
+	return games, nil
+}
+
+func (s *Store) GetGamesByRAWGIDs(rawgIDs []uint) ([]*models.Game, error) {
+	if len(rawgIDs) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(rawgIDs))
+	for i, id := range rawgIDs {
+		args[i] = id
+	}
+
+	rows, err := s.db.Query("SELECT id, rawg_id, name, slug, description, release_date, background_img, rating, website, created_at FROM games WHERE rawg_id IN ("+placeholders(len(args))+")", args...)
 	if err != nil {
-		return models.Game{}, err
+		return nil, err
+	}
+	defer rows.Close()
+
+	var games []*models.Game
+	for rows.Next() {
+		var game models.Game
+		if err := scanGame(rows, &game); err != nil {
+			return nil, err
+		}
+		games = append(games, &game)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadPlatformsAndGenres(games); err != nil {
+		return nil, err
 	}
 
-	return insertedGame, nil
+	return games, nil
 }
 
-func (s *Store) AddGamePlatform(name string, gameID uint32) error {
-	var platformID uint
+func (s *Store) GetGamePlatforms(gameID uint32) ([]models.Platform, error) {
+	rows, err := s.db.Query("SELECT p.id, p.name, p.imgurl, p.release_year FROM game_platforms gp JOIN platforms p ON p.id = gp.platform_id WHERE gp.game_id = ? ORDER BY p.id", gameID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get platforms: %v", err)
+	}
+	defer rows.Close()
 
-	row := s.db.QueryRow("SELECT id FROM platforms WHERE name = ?", name)
+	platforms := []models.Platform{}
+	for rows.Next() {
+		var platform models.Platform
+		var imgURL, releaseYear sql.NullString
+		if err := rows.Scan(&platform.ID, &platform.Name, &imgURL, &releaseYear); err != nil {
+			return nil, err
+		}
+		platform.ImgURL = imgURL.String
+		platform.ReleaseYear = releaseYear.String
 
-	err := row.Scan(&platformID)
+		platforms = append(platforms, platform)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return platforms, nil
+}
+
+func (s *Store) GetGameGenres(gameID uint32) ([]string, error) {
+	rows, err := s.db.Query("SELECT genre FROM game_genres WHERE game_id = ? ORDER BY genre", gameID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			// No platform found with the given name
-			return fmt.Errorf("platform with name %s not found", name)
+		return nil, fmt.Errorf("failed to get genres: %v", err)
+	}
+	defer rows.Close()
+
+	genres := []string{}
+	for rows.Next() {
+		var genre string
+		if err := rows.Scan(&genre); err != nil {
+			return nil, err
 		}
-		return err
+		genres = append(genres, genre)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
 
-	_, err = s.db.Exec("INSERT INTO game_platforms (game_id, platform_id) VALUES (?, ?)",
-		gameID, platformID)
+func (s *Store) GetGameStats(gameID uint32) (*models.GameStats, error) {
+	var stats models.GameStats
+	err := s.db.QueryRow("SELECT COUNT(*), COUNT(completed_at) FROM user_games WHERE game_id = ?", gameID).
+		Scan(&stats.Trackers, &stats.Platinums)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to count players: %v", err)
 	}
 
-	return nil
+	return &stats, nil
 }
 
-func (s *Store) AddGameGenre(name string, gameID uint32) error {
-	_, err := s.db.Exec("INSERT INTO game_genres (game_id, genre) VALUES (?, ?)",
-		gameID, name)
+// loadPlatformsAndGenres fills in the platforms and genres of each game
+func (s *Store) loadPlatformsAndGenres(games []*models.Game) error {
+	if len(games) == 0 {
+		return nil
+	}
+
+	byID := make(map[uint32]*models.Game, len(games))
+	args := make([]interface{}, len(games))
+	for i, game := range games {
+		byID[game.ID] = game
+		args[i] = game.ID
+	}
+	in := placeholders(len(args))
+
+	rows, err := s.db.Query("SELECT gp.game_id, p.id, p.name, p.imgurl, p.release_year FROM game_platforms gp JOIN platforms p ON p.id = gp.platform_id WHERE gp.game_id IN ("+in+") ORDER BY p.id", args...)
 	if err != nil {
+		return fmt.Errorf("failed to get platforms: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var gameID uint32
+		var platform models.Platform
+		var imgURL, releaseYear sql.NullString
+		if err := rows.Scan(&gameID, &platform.ID, &platform.Name, &imgURL, &releaseYear); err != nil {
+			return err
+		}
+		platform.ImgURL = imgURL.String
+		platform.ReleaseYear = releaseYear.String
+
+		byID[gameID].Platforms = append(byID[gameID].Platforms, platform)
+	}
+	if err := rows.Err(); err != nil {
 		return err
 	}
 
-	return nil
+	genreRows, err := s.db.Query("SELECT game_id, genre FROM game_genres WHERE game_id IN ("+in+") ORDER BY genre", args...)
+	if err != nil {
+		return fmt.Errorf("failed to get genres: %v", err)
+	}
+	defer genreRows.Close()
+
+	for genreRows.Next() {
+		var gameID uint32
+		var genre string
+		if err := genreRows.Scan(&gameID, &genre); err != nil {
+			return err
+		}
+
+		byID[gameID].Genres = append(byID[gameID].Genres, genre)
+	}
+
+	return genreRows.Err()
+}
+
+func (s *Store) ImportGame(imp models.GameImport, userID uint32) (*models.Game, bool, error) {
+	gameID, created, err := s.importGame(imp, userID)
+	if utils.IsDuplicateEntry(err) {
+		// Another import of the same game got there first, this time we find it
+		gameID, created, err = s.importGame(imp, userID)
+	}
+	if err != nil {
+		if utils.IsDuplicateEntry(err) {
+			return nil, false, utils.Conflict("a game with the slug '%s' already exists", imp.Game.Slug)
+		}
+		return nil, false, err
+	}
+
+	game, err := s.GetGameByID(uint(gameID))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return game, created, nil
+}
+
+func (s *Store) importGame(imp models.GameImport, userID uint32) (uint32, bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, false, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var gameID uint32
+	err = tx.QueryRow("SELECT id FROM games WHERE rawg_id = ? FOR UPDATE", imp.Game.RAWGID).Scan(&gameID)
+	created := err == sql.ErrNoRows
+	if err != nil && !created {
+		return 0, false, err
+	}
+
+	if created {
+		gameID, err = insertGame(tx, imp)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	if err := trackGame(tx, userID, gameID); err != nil {
+		return 0, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
+	}
+
+	return gameID, created, nil
 }
 
-func (s *Store) AddAchievement(achievement models.Achievement) (int32, error) {
-	result, err := s.db.Exec("INSERT INTO achievements (name, description, imgurl, percent, game_id) VALUES (?, ?, ?, ?, ?)",
-		achievement.Name, achievement.Description, achievement.ImgURL, achievement.Percent, achievement.GameID)
+func insertGame(tx *sql.Tx, imp models.GameImport) (uint32, error) {
+	game := imp.Game
+	result, err := tx.Exec("INSERT INTO games (rawg_id, name, slug, description, release_date, background_img, rating, website) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		game.RAWGID, game.Name, game.Slug, game.Description, game.ReleaseDate, game.BackgroundIMG, game.Rating, game.Website)
 	if err != nil {
-		return -1, err
+		return 0, err
 	}
 
 	lastID, err := result.LastInsertId()
 	if err != nil {
-		return -1, err
+		return 0, err
 	}
+	gameID := uint32(lastID)
 
-	return int32(lastID), nil
+	// RAWG knows platforms we don't, those are skipped
+	for _, name := range imp.Platforms {
+		_, err = tx.Exec("INSERT IGNORE INTO game_platforms (game_id, platform_id) SELECT ?, id FROM platforms WHERE name = ?",
+			gameID, name)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add platform: %v", err)
+		}
+	}
+
+	for _, name := range imp.Genres {
+		_, err = tx.Exec("INSERT IGNORE INTO game_genres (game_id, genre) VALUES (?, ?)", gameID, name)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add genre: %v", err)
+		}
+	}
+
+	for _, achievement := range imp.Achievements {
+		if _, err := insertAchievement(tx, achievement, gameID); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, screenshot := range imp.Screenshots {
+		if err := insertScreenshot(tx, screenshot, gameID); err != nil {
+			return 0, err
+		}
+	}
+
+	return gameID, nil
+}
+
+func insertAchievement(tx *sql.Tx, achievement models.Achievement, gameID uint32) (uint32, error) {
+	rawgID := sql.NullInt64{Int64: int64(achievement.RAWGID), Valid: achievement.RAWGID != 0}
+
+	grade := achievement.Grade
+	if grade == "" {
+		grade = models.GradeBronze
+	}
+
+	result, err := tx.Exec("INSERT INTO achievements (rawg_id, name, description, imgurl, percent, grade, game_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		rawgID, achievement.Name, achievement.Description, achievement.ImgURL, achievement.Percent, grade, gameID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add achievement: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(id), nil
+}
+
+func insertScreenshot(tx *sql.Tx, screenshot models.Screenshot, gameID uint32) error {
+	rawgID := sql.NullInt64{Int64: int64(screenshot.RAWGID), Valid: screenshot.RAWGID != 0}
+
+	_, err := tx.Exec("INSERT INTO screenshots (game_id, rawg_id, imgurl, width, height) VALUES (?, ?, ?, ?, ?)",
+		gameID, rawgID, screenshot.ImgURL, screenshot.Width, screenshot.Height)
+	if err != nil {
+		return fmt.Errorf("failed to add screenshot: %v", err)
+	}
+
+	return nil
+}
+
+// trackGame tracks the game for the user along with each of its
+// achievements, unless they already track it
+func trackGame(tx *sql.Tx, userID, gameID uint32) error {
+	// The unique index on user_games makes this a no-op for a game that's
+	// already tracked, even by a concurrent request
+	res, err := tx.Exec("INSERT IGNORE INTO user_games (user_id, game_id) VALUES (?, ?)", userID, gameID)
+	if err != nil {
+		return fmt.Errorf("failed to track game: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec("INSERT INTO user_achievements (user_id, game_id, achievement_id) SELECT ?, game_id, id FROM achievements WHERE game_id = ?",
+		userID, gameID)
+	if err != nil {
+		return fmt.Errorf("failed to add user achievements: %v", err)
+	}
+
+	return nil
 }
 
 func (s *Store) AddUserAchievement(userID, gameID, achID uint32) error {
@@ -131,22 +447,418 @@ func (s *Store) AddUserAchievement(userID, gameID, achID uint32) error {
 	return nil
 }
 
-func scanGame(scanner interface {
-	Scan(dest ...interface{}) error
-}, game *models.Game) error {
-	var platforms, genres, screenshots string
+func (s *Store) EditGame(game models.Game) error {
+	_, err := s.db.Exec("UPDATE games SET name = ?, description = ?, release_date = ?, background_img = ?, rating = ?, website = ? WHERE id = ?",
+		game.Name, game.Description, game.ReleaseDate, game.BackgroundIMG, game.Rating, game.Website, game.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update game: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteGame removes a game from the catalog along with everything that references it
+func (s *Store) DeleteGame(id uint32) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"user_achievements", "user_games", "achievements", "screenshots", "game_genres", "game_platforms"} {
+		_, err = tx.Exec("DELETE FROM "+table+" WHERE game_id = ?", id)
+		if err != nil {
+			return fmt.Errorf("failed to delete from %s: %v", table, err)
+		}
+	}
+
+	// Finished import jobs keep their history
+	_, err = tx.Exec("UPDATE jobs SET game_id = NULL WHERE game_id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to detach jobs: %v", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM games WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete game: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) ResyncGame(gameID uint32, imp models.GameImport) (*models.ResyncReport, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	report := &models.ResyncReport{GameID: gameID}
+
+	var current models.Game
+	row := tx.QueryRow("SELECT id, rawg_id, name, slug, description, release_date, background_img, rating, website, created_at FROM games WHERE id = ? FOR UPDATE", gameID)
+	if err := scanGame(row, &current); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("game not found with id '%d'", gameID)
+		}
+		return nil, err
+	}
+
+	if err := resyncGameFields(tx, current, imp.Game, report); err != nil {
+		return nil, err
+	}
+
+	// Platforms RAWG knows and we don't are skipped, like on import
+	report.PlatformsAdded, report.PlatformsRemoved, err = resyncNames(tx, gameID, imp.Platforms,
+		"SELECT p.name FROM game_platforms gp JOIN platforms p ON p.id = gp.platform_id WHERE gp.game_id = ?",
+		"INSERT IGNORE INTO game_platforms (game_id, platform_id) SELECT ?, id FROM platforms WHERE name = ?",
+		"DELETE gp FROM game_platforms gp JOIN platforms p ON p.id = gp.platform_id WHERE gp.game_id = ? AND p.name = ?")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resync platforms: %v", err)
+	}
+
+	report.GenresAdded, report.GenresRemoved, err = resyncNames(tx, gameID, imp.Genres,
+		"SELECT genre FROM game_genres WHERE game_id = ?",
+		"INSERT IGNORE INTO game_genres (game_id, genre) VALUES (?, ?)",
+		"DELETE FROM game_genres WHERE game_id = ? AND genre = ?")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resync genres: %v", err)
+	}
 
-	err := scanner.Scan(&game.ID, &game.Name, &game.Slug, &platforms, &game.ReleaseDate, &game.BackgroundIMG, &game.Rating, &genres, &screenshots, &game.CreatedAt)
+	if err := resyncAchievements(tx, gameID, imp.Achievements, report); err != nil {
+		return nil, err
+	}
+
+	if err := resyncScreenshots(tx, gameID, imp.Screenshots, report); err != nil {
+		return nil, err
+	}
+
+	// A completed game with achievements the user hasn't earned, such as new DLC, isn't completed any more
+	result, err := tx.Exec(`
+		UPDATE user_games ug SET completed_at = NULL
+		WHERE ug.game_id = ? AND ug.completed_at IS NOT NULL AND EXISTS (
+			SELECT 1 FROM user_achievements ua WHERE ua.user_id = ug.user_id AND ua.game_id = ug.game_id AND ua.completed = FALSE
+		)`, gameID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-evaluate completions: %v", err)
+	}
+	reopened, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	report.CompletionsReopened = int(reopened)
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func resyncGameFields(tx *sql.Tx, current, fetched models.Game, report *models.ResyncReport) error {
+	fields := []struct {
+		name    string
+		changed bool
+	}{
+		{"name", current.Name != fetched.Name},
+		{"slug", current.Slug != fetched.Slug},
+		{"description", current.Description != fetched.Description},
+		{"releaseDate", current.ReleaseDate != fetched.ReleaseDate},
+		{"backgroundImg", current.BackgroundIMG != fetched.BackgroundIMG},
+		{"rating", current.Rating != fetched.Rating},
+		{"website", current.Website != fetched.Website},
+	}
+	for _, f := range fields {
+		if f.changed {
+			report.UpdatedFields = append(report.UpdatedFields, f.name)
+		}
+	}
+	if len(report.UpdatedFields) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec("UPDATE games SET name = ?, slug = ?, description = ?, release_date = ?, background_img = ?, rating = ?, website = ? WHERE id = ?",
+		fetched.Name, fetched.Slug, fetched.Description, fetched.ReleaseDate, fetched.BackgroundIMG, fetched.Rating, fetched.Website, current.ID)
 	if err != nil {
+		if utils.IsDuplicateEntry(err) {
+			return utils.Conflict("a game with the slug '%s' already exists", fetched.Slug)
+		}
+		return fmt.Errorf("failed to update game: %v", err)
+	}
+
+	return nil
+}
+
+// resyncScreenshots makes the game's screenshots match fetched. Nothing
+// refers to a screenshot, so those RAWG no longer lists are deleted.
+func resyncScreenshots(tx *sql.Tx, gameID uint32, fetched []models.Screenshot, report *models.ResyncReport) error {
+	rows, err := tx.Query("SELECT id, rawg_id FROM screenshots WHERE game_id = ?", gameID)
+	if err != nil {
+		return fmt.Errorf("failed to get screenshots: %v", err)
+	}
+	defer rows.Close()
+
+	current := make(map[uint32]uint32) // By RAWG ID
+	var stale []uint32
+	for rows.Next() {
+		var id uint32
+		var rawgID sql.NullInt64
+		if err := rows.Scan(&id, &rawgID); err != nil {
+			return err
+		}
+		if !rawgID.Valid {
+			stale = append(stale, id)
+			continue
+		}
+		current[uint32(rawgID.Int64)] = id
+	}
+	if err := rows.Err(); err != nil {
 		return err
 	}
 
-	if err := json.Unmarshal([]byte(platforms), &game.Platforms); err != nil {
+	wanted := make(map[uint32]bool, len(fetched))
+	for _, screenshot := range fetched {
+		if wanted[screenshot.RAWGID] {
+			continue
+		}
+		wanted[screenshot.RAWGID] = true
+
+		if id, ok := current[screenshot.RAWGID]; ok {
+			_, err := tx.Exec("UPDATE screenshots SET imgurl = ?, width = ?, height = ? WHERE id = ?",
+				screenshot.ImgURL, screenshot.Width, screenshot.Height, id)
+			if err != nil {
+				return fmt.Errorf("failed to update screenshot: %v", err)
+			}
+			continue
+		}
+
+		if err := insertScreenshot(tx, screenshot, gameID); err != nil {
+			return err
+		}
+		report.ScreenshotsAdded++
+	}
+
+	for rawgID, id := range current {
+		if !wanted[rawgID] {
+			stale = append(stale, id)
+		}
+	}
+	for _, id := range stale {
+		if _, err := tx.Exec("DELETE FROM screenshots WHERE id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete screenshot: %v", err)
+		}
+		report.ScreenshotsRemoved++
+	}
+
+	return nil
+}
+
+// resyncNames makes the names selected for the game match fetched, reporting
+// the names that were added and removed. An insert that affects no rows,
+// such as for a platform we don't know, doesn't count as added.
+func resyncNames(tx *sql.Tx, gameID uint32, fetched []string, selectQuery, insertQuery, deleteQuery string) ([]string, []string, error) {
+	rows, err := tx.Query(selectQuery, gameID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	current := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, nil, err
+		}
+		current[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var added, removed []string
+	wanted := make(map[string]bool, len(fetched))
+	for _, name := range fetched {
+		if wanted[name] {
+			continue
+		}
+		wanted[name] = true
+
+		if current[name] {
+			continue
+		}
+		result, err := tx.Exec(insertQuery, gameID, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if n, err := result.RowsAffected(); err == nil && n > 0 {
+			added = append(added, name)
+		}
+	}
+
+	for name := range current {
+		if wanted[name] {
+			continue
+		}
+		if _, err := tx.Exec(deleteQuery, gameID, name); err != nil {
+			return nil, nil, err
+		}
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+
+	return added, removed, nil
+}
+
+// resyncAchievements updates the game's achievements that RAWG changed and
+// adds new ones for every user tracking the game. Achievements are matched by
+// RAWG ID, or by name for those imported before we kept RAWG IDs. Ones RAWG
+// no longer lists are kept, so nobody loses what they have earned.
+func resyncAchievements(tx *sql.Tx, gameID uint32, fetched []models.Achievement, report *models.ResyncReport) error {
+	rows, err := tx.Query("SELECT id, rawg_id, name, description, imgurl, percent, grade FROM achievements WHERE game_id = ? FOR UPDATE", gameID)
+	if err != nil {
 		return err
 	}
-	if err := json.Unmarshal([]byte(genres), &game.Genres); err != nil {
+	defer rows.Close()
+
+	byRAWGID := make(map[uint32]*models.Achievement)
+	byName := make(map[string]*models.Achievement)
+	hasPlatinum := false
+	for rows.Next() {
+		var a models.Achievement
+		var rawgID sql.NullInt64
+		var description, imgURL sql.NullString
+		if err := rows.Scan(&a.ID, &rawgID, &a.Name, &description, &imgURL, &a.Percent, &a.Grade); err != nil {
+			return err
+		}
+		a.RAWGID = uint32(rawgID.Int64)
+		a.Description = description.String
+		a.ImgURL = imgURL.String
+		hasPlatinum = hasPlatinum || a.Grade == models.GradePlatinum
+
+		if a.RAWGID != 0 {
+			byRAWGID[a.RAWGID] = &a
+		} else {
+			byName[a.Name] = &a
+		}
+	}
+	if err := rows.Err(); err != nil {
 		return err
 	}
+	rows.Close()
+
+	var trackers []uint32
+	userRows, err := tx.Query("SELECT user_id FROM user_games WHERE game_id = ?", gameID)
+	if err != nil {
+		return err
+	}
+	defer userRows.Close()
+	for userRows.Next() {
+		var userID uint32
+		if err := userRows.Scan(&userID); err != nil {
+			return err
+		}
+		trackers = append(trackers, userID)
+	}
+	if err := userRows.Err(); err != nil {
+		return err
+	}
+	userRows.Close()
+
+	for _, a := range fetched {
+		existing := byRAWGID[a.RAWGID]
+		if existing == nil {
+			existing = byName[a.Name]
+			delete(byName, a.Name)
+		}
+
+		if existing != nil {
+			changed := existing.Name != a.Name || existing.Description != a.Description || existing.ImgURL != a.ImgURL || !samePercent(existing.Percent, a.Percent)
+			if !changed && existing.RAWGID == a.RAWGID {
+				continue
+			}
+
+			_, err := tx.Exec("UPDATE achievements SET rawg_id = ?, name = ?, description = ?, imgurl = ?, percent = ? WHERE id = ?",
+				a.RAWGID, a.Name, a.Description, a.ImgURL, a.Percent, existing.ID)
+			if err != nil {
+				return fmt.Errorf("failed to update achievement: %v", err)
+			}
+			// Only recording the RAWG ID isn't a change anyone needs to hear about
+			if changed {
+				report.AchievementsUpdated = append(report.AchievementsUpdated, a.Name)
+			}
+			continue
+		}
+
+		// Existing grades are kept, so a new platinum would make it two
+		a.Grade = achievement.GradeAdded(a, hasPlatinum)
+		hasPlatinum = hasPlatinum || a.Grade == models.GradePlatinum
+
+		achID, err := insertAchievement(tx, a, gameID)
+		if err != nil {
+			return err
+		}
+		report.AchievementsAdded = append(report.AchievementsAdded, a.Name)
+
+		for _, userID := range trackers {
+			_, err := tx.Exec("INSERT INTO user_achievements (user_id, game_id, achievement_id) VALUES (?, ?, ?)", userID, gameID, achID)
+			if err != nil {
+				return fmt.Errorf("failed to add user achievement: %v", err)
+			}
+			report.UserAchievementsAdded++
+		}
+	}
+
+	return nil
+}
+
+// samePercent compares percents by value, an unknown percent only matches
+// another unknown one
+func samePercent(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// fulltextTerms turns a search into a boolean mode query that requires every
+// word, matching words that start with it. Words shorter than InnoDB indexes
+// are left to LIKE.
+func fulltextTerms(query string) string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(word) >= 3 {
+			terms = append(terms, "+"+word+"*")
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func scanGame(scanner interface {
+	Scan(dest ...interface{}) error
+}, game *models.Game) error {
+	var description, releaseDate, backgroundIMG, website sql.NullString
+	var rating sql.NullFloat64
+
+	err := scanner.Scan(&game.ID, &game.RAWGID, &game.Name, &game.Slug, &description, &releaseDate, &backgroundIMG, &rating, &website, &game.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	game.Description = description.String
+	game.ReleaseDate = releaseDate.String
+	game.BackgroundIMG = backgroundIMG.String
+	game.Rating = uint(rating.Float64)
+	game.Website = website.String
 
 	return nil
 }
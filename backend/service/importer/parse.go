@@ -0,0 +1,242 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+)
+
+const (
+	FormatSteam = "steam" // Steam's GetPlayerAchievements JSON for one game
+	FormatPSN   = "psn"   // A PSN trophy list CSV with headers
+	FormatCSV   = "csv"   // game, achievement name, unlocked at
+)
+
+// Column names a PSN trophy export may use, lowercased
+var psnColumns = map[string][]string{
+	"game":   {"game", "title", "game title"},
+	"trophy": {"trophy", "trophy name", "name"},
+	"earned": {"earned", "earned on", "date earned", "unlocked", "unlocked at"},
+}
+
+// Unlock times are read in the first of these layouts that fits, as UTC
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"01/02/2006 15:04:05",
+	"01/02/2006 15:04",
+	"01/02/2006",
+}
+
+// parse reads the unlocks of an export file. Rows that can't be read are
+// returned as misses, only a file that can't be read at all fails.
+func parse(format string, r io.Reader) ([]models.ImportRow, []models.ImportMiss, error) {
+	switch format {
+	case FormatSteam:
+		return parseSteam(r)
+	case FormatPSN:
+		return parsePSN(r)
+	case FormatCSV:
+		return parseCSV(r)
+	default:
+		return nil, nil, utils.Invalid("unknown format '%s', expected %s, %s or %s", format, FormatSteam, FormatPSN, FormatCSV)
+	}
+}
+
+func parseSteam(r io.Reader) ([]models.ImportRow, []models.ImportMiss, error) {
+	var export struct {
+		PlayerStats struct {
+			GameName     string `json:"gameName"`
+			Achievements []struct {
+				APIName    string `json:"apiname"`
+				Name       string `json:"name"` // Only exported with a language
+				Achieved   int    `json:"achieved"`
+				UnlockTime int64  `json:"unlocktime"`
+			} `json:"achievements"`
+		} `json:"playerstats"`
+	}
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, nil, readError(err, "invalid Steam JSON: %v")
+	}
+
+	stats := export.PlayerStats
+	if stats.GameName == "" {
+		return nil, nil, utils.Invalid("playerstats.gameName is missing")
+	}
+
+	var rows []models.ImportRow
+	for i, a := range stats.Achievements {
+		if a.Achieved != 1 {
+			continue
+		}
+
+		row := models.ImportRow{Line: i + 1, Game: stats.GameName, Achievement: a.Name}
+		if row.Achievement == "" {
+			row.Achievement = a.APIName
+		}
+		if a.UnlockTime > 0 {
+			t := time.Unix(a.UnlockTime, 0).UTC()
+			row.UnlockedAt = &t
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil, nil
+}
+
+func parsePSN(r io.Reader) ([]models.ImportRow, []models.ImportMiss, error) {
+	reader := newCSVReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, readError(err, "invalid PSN CSV: %v")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		for column, aliases := range psnColumns {
+			for _, alias := range aliases {
+				if _, ok := columns[column]; !ok && name == alias {
+					columns[column] = i
+				}
+			}
+		}
+	}
+	for _, column := range []string{"game", "trophy", "earned"} {
+		if _, ok := columns[column]; !ok {
+			return nil, nil, utils.Invalid("PSN CSV has no %s column, expected one of %s", column, strings.Join(psnColumns[column], ", "))
+		}
+	}
+
+	var rows []models.ImportRow
+	var misses []models.ImportMiss
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, readError(err, "invalid PSN CSV: %v")
+		}
+		line, _ := reader.FieldPos(0)
+
+		// Trophies without an earned date haven't been earned
+		earned := field(record, columns["earned"])
+		if earned == "" {
+			continue
+		}
+
+		row := models.ImportRow{Line: line, Game: field(record, columns["game"]), Achievement: field(record, columns["trophy"])}
+		if miss, ok := readRow(&row, earned); !ok {
+			misses = append(misses, miss)
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, misses, nil
+}
+
+func parseCSV(r io.Reader) ([]models.ImportRow, []models.ImportMiss, error) {
+	reader := newCSVReader(r)
+
+	var rows []models.ImportRow
+	var misses []models.ImportMiss
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, readError(err, "invalid CSV: %v")
+		}
+		line, _ := reader.FieldPos(0)
+
+		// The header row is optional
+		if first && strings.EqualFold(field(record, 0), "game") {
+			continue
+		}
+
+		row := models.ImportRow{Line: line, Game: field(record, 0), Achievement: field(record, 1)}
+		if miss, ok := readRow(&row, field(record, 2)); !ok {
+			misses = append(misses, miss)
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, misses, nil
+}
+
+// readRow checks a CSV row names a game and achievement and sets when it was
+// unlocked, if it says
+func readRow(row *models.ImportRow, unlockedAt string) (models.ImportMiss, bool) {
+	if row.Game == "" || row.Achievement == "" {
+		return models.ImportMiss{ImportRow: *row, Reason: "missing game or achievement"}, false
+	}
+
+	if unlockedAt != "" {
+		t, ok := parseTime(unlockedAt)
+		if !ok {
+			return models.ImportMiss{ImportRow: *row, Reason: "unreadable unlock time '" + unlockedAt + "'"}, false
+		}
+		row.UnlockedAt = &t
+	}
+
+	return models.ImportMiss{}, true
+}
+
+func parseTime(value string) (time.Time, bool) {
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0).UTC(), true
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func newCSVReader(r io.Reader) *csv.Reader {
+	br := bufio.NewReader(r)
+	// Spreadsheet apps like to start exports with a byte order mark
+	if bom, err := br.Peek(3); err == nil && string(bom) == "\ufeff" {
+		br.Discard(3)
+	}
+
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return reader
+}
+
+func field(record []string, i int) string {
+	if i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// readError tells a file over the size limit apart from a malformed one
+func readError(err error, format string) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return utils.Invalid("import files can't be larger than %d bytes", tooLarge.Limit)
+	}
+	return utils.Invalid(format, err)
+}
@@ -0,0 +1,211 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+// Export files are small, even years of trophies fit in a fraction of this
+const maxImportSize = 5 << 20
+
+// Handler imports progress recorded by other tools into the games a user tracks
+type Handler struct {
+	gameStore     models.GameStore
+	userGameStore models.UserGameStore
+	achStore      models.AchievementStore
+}
+
+func NewHandler(gameStore models.GameStore, userGameStore models.UserGameStore, achStore models.AchievementStore) *Handler {
+	return &Handler{gameStore: gameStore, userGameStore: userGameStore, achStore: achStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/import-progress", utils.MakeHandler(h.handleImportProgress)).Methods("POST")
+}
+
+// handleImportProgress reads the export file in the body and earns every
+// achievement it matches, or only reports the matches on a dry run
+func (h *Handler) handleImportProgress(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	var supplied uint64
+	if v := query.Get("userID"); v != "" {
+		var err error
+		if supplied, err = strconv.ParseUint(v, 10, 32); err != nil {
+			return utils.Invalid("invalid user id: %v", err)
+		}
+	}
+	userID, err := auth.ActingUserID(r, uint32(supplied))
+	if err != nil {
+		return err
+	}
+
+	dryRun := false
+	if v := query.Get("dryRun"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			return utils.Invalid("invalid dryRun: %v", err)
+		}
+	}
+
+	format := strings.ToLower(query.Get("format"))
+	rows, misses, err := parse(format, http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		return err
+	}
+
+	report := models.ImportReport{
+		Format:    format,
+		DryRun:    dryRun,
+		Rows:      len(rows) + len(misses),
+		Matched:   []models.ImportMatch{},
+		Unmatched: misses,
+	}
+	if report.Unmatched == nil {
+		report.Unmatched = []models.ImportMiss{}
+	}
+
+	m := &matcher{h: h, userID: userID, now: time.Now(), games: make(map[string]*matchedGame)}
+	for _, row := range rows {
+		match, reason, err := m.match(row)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			report.Unmatched = append(report.Unmatched, models.ImportMiss{ImportRow: row, Reason: reason})
+			continue
+		}
+		report.Matched = append(report.Matched, match)
+	}
+
+	if !dryRun && len(report.Matched) > 0 {
+		unlocks := make([]models.AchievementUnlock, 0, len(report.Matched))
+		for _, match := range report.Matched {
+			unlocks = append(unlocks, models.AchievementUnlock{AchievementID: match.AchievementID, CompletedAt: match.UnlockedAt})
+		}
+
+		if err := h.achStore.ImportAchievements(userID, unlocks); err != nil {
+			return fmt.Errorf("error importing achievements: %w", err)
+		}
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, report)
+}
+
+type matchedGame struct {
+	id           uint32
+	reason       string // Why the game's rows can't be imported, if they can't
+	achievements map[string]uint32
+}
+
+// matcher resolves rows to the user's tracked games and their achievements,
+// looking each game up once
+type matcher struct {
+	h      *Handler
+	userID uint32
+	now    time.Time
+	games  map[string]*matchedGame
+}
+
+func (m *matcher) match(row models.ImportRow) (models.ImportMatch, string, error) {
+	if row.UnlockedAt != nil && row.UnlockedAt.After(m.now) {
+		return models.ImportMatch{}, "unlocked in the future", nil
+	}
+
+	game, err := m.game(row.Game)
+	if err != nil {
+		return models.ImportMatch{}, "", err
+	}
+	if game.reason != "" {
+		return models.ImportMatch{}, game.reason, nil
+	}
+
+	achievementID, ok := game.achievements[normalize(row.Achievement)]
+	if !ok {
+		return models.ImportMatch{}, "achievement not found in game", nil
+	}
+
+	return models.ImportMatch{ImportRow: row, GameID: game.id, AchievementID: achievementID}, "", nil
+}
+
+// game finds the game a row names by RAWG ID, slug or name
+func (m *matcher) game(ref string) (*matchedGame, error) {
+	key := normalize(ref)
+	if game, ok := m.games[key]; ok {
+		return game, nil
+	}
+
+	game := &matchedGame{}
+	m.games[key] = game
+
+	found, err := m.findGame(ref)
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		game.reason = "game not found"
+		return game, nil
+	}
+	game.id = found.ID
+
+	_, err = m.h.userGameStore.GetUserGameByID(m.userID, found.ID)
+	if errors.Is(err, utils.ErrNotFound) {
+		game.reason = "game isn't tracked"
+		return game, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	achievements, err := m.h.achStore.GetAllAchievementsByGame(found.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving game achievements: %w", err)
+	}
+	game.achievements = make(map[string]uint32, len(achievements))
+	for _, a := range achievements {
+		game.achievements[normalize(a.Name)] = a.ID
+	}
+
+	return game, nil
+}
+
+func (m *matcher) findGame(ref string) (*models.Game, error) {
+	if rawgID, err := strconv.ParseUint(ref, 10, 32); err == nil {
+		game, err := m.h.gameStore.GetGameByRAWGID(uint(rawgID))
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil, nil
+		}
+		return game, err
+	}
+
+	games, err := m.h.gameStore.SearchGames(clean(ref), models.GameSearchFilter{}, 10)
+	if err != nil {
+		return nil, fmt.Errorf("error searching games: %w", err)
+	}
+	for _, game := range games {
+		if strings.EqualFold(game.Slug, ref) || normalize(game.Name) == normalize(ref) {
+			return game, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// normalize lets names match across tools that disagree on case, spacing and
+// trademark signs
+func normalize(name string) string {
+	return strings.ToLower(clean(name))
+}
+
+func clean(name string) string {
+	name = strings.NewReplacer("™", "", "®", "", "©", "").Replace(name)
+	return strings.Join(strings.Fields(name), " ")
+}
@@ -0,0 +1,188 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestImportProgress(t *testing.T) {
+	games := &mockGameStore{games: []*models.Game{
+		{ID: 1, RAWGID: 3328, Name: "The Witcher 3: Wild Hunt", Slug: "the-witcher-3-wild-hunt"},
+		{ID: 2, RAWGID: 9767, Name: "Hollow Knight", Slug: "hollow-knight"},
+		{ID: 3, RAWGID: 58175, Name: "God of War", Slug: "god-of-war-2"},
+	}}
+	userGames := &mockUserGameStore{tracked: map[uint32]bool{1: true, 2: true}}
+	achievements := &mockAchievementStore{achievements: map[uint32][]*models.Achievement{
+		1: {{ID: 10, Name: "Lilac and Gooseberries"}, {ID: 11, Name: "Kaer Morhen Forever"}},
+		2: {{ID: 20, Name: "Falsehood"}, {ID: 21, Name: "Protagonist"}},
+	}}
+
+	handler := NewHandler(games, userGames, achievements)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	post := func(query, body string) (*httptest.ResponseRecorder, models.ImportReport) {
+		req, err := http.NewRequest(http.MethodPost, "/import-progress?"+query, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, uint32(1)))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		var report models.ImportReport
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr, report
+	}
+
+	t.Run("should report Steam unlocks on a dry run without importing them", func(t *testing.T) {
+		rr, report := post("format=steam&dryRun=true", `{"playerstats": {"gameName": "The Witcher 3: Wild Hunt™", "achievements": [
+			{"apiname": "ACH_LILAC", "name": "Lilac and Gooseberries", "achieved": 1, "unlocktime": 1724188440},
+			{"apiname": "ACH_KAER", "name": "Kaer Morhen Forever", "achieved": 0, "unlocktime": 0},
+			{"apiname": "ACH_GWENT", "name": "Gwent Master", "achieved": 1, "unlocktime": 1724188500}
+		]}}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		if report.Rows != 2 || len(report.Matched) != 1 || len(report.Unmatched) != 1 {
+			t.Fatalf("expected 1 matched and 1 unmatched row, got %+v", report)
+		}
+		if m := report.Matched[0]; m.AchievementID != 10 || m.UnlockedAt == nil || !m.UnlockedAt.Equal(time.Unix(1724188440, 0)) {
+			t.Errorf("unexpected match %+v", m)
+		}
+		if achievements.imported != nil {
+			t.Errorf("expected nothing to be imported, got %+v", achievements.imported)
+		}
+	})
+
+	t.Run("should import PSN trophies of tracked games", func(t *testing.T) {
+		rr, report := post("format=psn", "\ufeffTitle,Trophy Name,Grade,Earned On\n"+
+			"Hollow Knight,Falsehood,Bronze,2024-08-20 21:14:00\n"+
+			"Hollow Knight,Protagonist,Bronze,\n"+
+			"God of War,Path to Alfheim,Silver,2024-08-21\n")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		if len(report.Matched) != 1 || len(report.Unmatched) != 1 || report.Unmatched[0].Reason != "game isn't tracked" {
+			t.Fatalf("expected 1 matched row and 1 untracked game, got %+v", report)
+		}
+		if len(achievements.imported) != 1 || achievements.imported[0].AchievementID != 20 ||
+			!achievements.imported[0].CompletedAt.Equal(time.Date(2024, 8, 20, 21, 14, 0, 0, time.UTC)) {
+			t.Errorf("unexpected import %+v", achievements.imported)
+		}
+	})
+
+	t.Run("should explain every generic CSV row it can't match", func(t *testing.T) {
+		achievements.imported = nil
+		future := time.Now().Add(time.Hour).Format(time.RFC3339)
+		rr, report := post("format=csv", "game,achievement name,unlocked at\n"+
+			"3328,kaer morhen  forever,\n"+
+			"the-witcher-3-wild-hunt,Lilac and Gooseberries,yesterday\n"+
+			"hollow-knight,Falsehood,"+future+"\n"+
+			"Hollow Knight,Steel Soul,2024-08-20\n"+
+			"Half-Life 3,Anything,2024-08-20\n")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		if report.Rows != 5 || len(report.Matched) != 1 || report.Matched[0].AchievementID != 11 || report.Matched[0].UnlockedAt != nil {
+			t.Fatalf("expected only the RAWG ID row to match, got %+v", report)
+		}
+
+		reasons := make(map[int]string)
+		for _, miss := range report.Unmatched {
+			reasons[miss.Line] = miss.Reason
+		}
+		expected := map[int]string{
+			3: "unreadable unlock time 'yesterday'",
+			4: "unlocked in the future",
+			5: "achievement not found in game",
+			6: "game not found",
+		}
+		for line, reason := range expected {
+			if reasons[line] != reason {
+				t.Errorf("expected line %d to be unmatched with %q, got %q", line, reason, reasons[line])
+			}
+		}
+	})
+
+	t.Run("should fail for unknown formats", func(t *testing.T) {
+		if rr, _ := post("format=xml", "<trophies/>"); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should fail for PSN exports without the needed columns", func(t *testing.T) {
+		if rr, _ := post("format=psn", "Game,Trophy\nHollow Knight,Falsehood\n"); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+type mockGameStore struct {
+	models.GameStore
+	games []*models.Game
+}
+
+func (s *mockGameStore) GetGameByRAWGID(rawgID uint) (*models.Game, error) {
+	for _, g := range s.games {
+		if uint(g.RAWGID) == rawgID {
+			return g, nil
+		}
+	}
+	return nil, utils.NotFound("game not found with rawg id '%d'", rawgID)
+}
+
+func (s *mockGameStore) SearchGames(query string, filter models.GameSearchFilter, limit int) ([]*models.Game, error) {
+	var games []*models.Game
+	for _, g := range s.games {
+		if strings.Contains(strings.ToLower(g.Name), strings.ToLower(query)) || strings.Contains(g.Slug, strings.ToLower(query)) {
+			games = append(games, g)
+		}
+	}
+	return games, nil
+}
+
+type mockUserGameStore struct {
+	models.UserGameStore
+	tracked map[uint32]bool
+}
+
+func (s *mockUserGameStore) GetUserGameByID(userID, gameID uint32) (*models.UserGame, error) {
+	if !s.tracked[gameID] {
+		return nil, utils.NotFound("user game not found")
+	}
+	return &models.UserGame{}, nil
+}
+
+type mockAchievementStore struct {
+	models.AchievementStore
+	achievements map[uint32][]*models.Achievement
+	imported     []models.AchievementUnlock
+}
+
+func (s *mockAchievementStore) GetAllAchievementsByGame(gameID uint32) ([]*models.Achievement, error) {
+	return s.achievements[gameID], nil
+}
+
+func (s *mockAchievementStore) ImportAchievements(userID uint32, unlocks []models.AchievementUnlock) error {
+	s.imported = append(s.imported, unlocks...)
+	return nil
+}
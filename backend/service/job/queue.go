@@ -0,0 +1,236 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/rawg"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+)
+
+// How often idle workers look for jobs that became due, such as retries.
+// New jobs wake a worker straight away.
+const pollInterval = 5 * time.Second
+
+// How long a running job can go without an update before it is taken to be
+// abandoned by a server that stopped. Workers touch the jobs they run every
+// heartbeatInterval, so live jobs, on any server, never go stale.
+const (
+	staleAfter        = 5 * time.Minute
+	heartbeatInterval = time.Minute
+)
+
+// Why a job that was requeued or failed after going stale was
+const abandonedReason = "abandoned by a server that stopped"
+
+// Queue runs jobs from a JobStore on a pool of workers. Jobs that fail
+// because RAWG is unavailable are retried with exponential backoff.
+type Queue struct {
+	store       models.JobStore
+	gameStore   models.GameStore
+	rawg        rawg.Client
+	workers     int
+	maxAttempts int
+	backoff     time.Duration
+	heartbeat   time.Duration
+	now         func() time.Time
+	wake        chan struct{}
+}
+
+// NewQueue makes a queue of workers that each run one job at a time. A job
+// is attempted up to maxAttempts times, waiting backoff after the first
+// failure and twice as long after each one after that.
+func NewQueue(store models.JobStore, gameStore models.GameStore, rawg rawg.Client, workers, maxAttempts int, backoff time.Duration) *Queue {
+	return &Queue{
+		store:       store,
+		gameStore:   gameStore,
+		rawg:        rawg,
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		heartbeat:   heartbeatInterval,
+		now:         time.Now,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Start runs the workers until ctx is done. Jobs that were running when a
+// server stopped are run again once they are stale, checked at startup and
+// every heartbeat after.
+func (q *Queue) Start(ctx context.Context) error {
+	if err := q.store.RequeueStaleJobs(staleAfter, q.maxAttempts); err != nil {
+		return err
+	}
+
+	for i := 0; i < q.workers; i++ {
+		go q.work(ctx)
+	}
+	go q.requeueStale(ctx)
+
+	return nil
+}
+
+func (q *Queue) requeueStale(ctx context.Context) {
+	ticker := time.NewTicker(q.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := q.store.RequeueStaleJobs(staleAfter, q.maxAttempts); err != nil {
+				log.Printf("job queue: %v", err)
+				continue
+			}
+			// Requeued jobs are due straight away
+			select {
+			case q.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (q *Queue) Enqueue(job models.Job) (*models.Job, error) {
+	job.RunAt = q.now()
+
+	created, err := q.store.CreateJob(job)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return created, nil
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		ran, err := q.RunNext(ctx)
+		if err != nil {
+			log.Printf("job queue: %v", err)
+		}
+		if ran && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-q.wake:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// RunNext claims and runs the next job that is due, reporting whether there
+// was one
+func (q *Queue) RunNext(ctx context.Context) (bool, error) {
+	job, err := q.store.ClaimJob(q.now())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim job: %w", err)
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	stop := q.keepAlive(job.ID)
+	err = q.run(ctx, job)
+	stop()
+	if err == nil {
+		return true, nil
+	}
+
+	log.Printf("job %d (%s) attempt %d failed: %v", job.ID, job.Kind, job.Attempts, err)
+
+	// Only RAWG being unavailable is worth trying again
+	if errors.Is(err, utils.ErrUpstream) && job.Attempts < q.maxAttempts {
+		delay := q.backoff << (job.Attempts - 1)
+		return true, q.store.RetryJob(job.ID, q.now().Add(delay), reason(err))
+	}
+
+	return true, q.store.FailJob(job.ID, reason(err))
+}
+
+// keepAlive touches the job every heartbeat until the returned func is called,
+// so other servers don't requeue it while it runs
+func (q *Queue) keepAlive(id uint32) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(q.heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := q.store.TouchJob(id); err != nil {
+					log.Printf("job %d: %v", id, err)
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func (q *Queue) run(ctx context.Context, job *models.Job) error {
+	switch job.Kind {
+	case models.JobImportGame:
+		return q.runImport(ctx, job)
+	case models.JobResyncGame:
+		return q.runResync(ctx, job)
+	default:
+		return fmt.Errorf("unknown job kind '%s'", job.Kind)
+	}
+}
+
+// runImport imports the game for the user who asked for it
+func (q *Queue) runImport(ctx context.Context, job *models.Job) error {
+	imp, err := q.fetch(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	game, created, err := q.gameStore.ImportGame(*imp, job.UserID)
+	if err != nil {
+		return err
+	}
+
+	inserted := 0
+	if created {
+		inserted = len(imp.Achievements)
+	}
+
+	return q.store.CompleteJob(job.ID, game.ID, inserted, nil)
+}
+
+// fetch fetches the job's game from RAWG, recording each page fetched
+func (q *Queue) fetch(ctx context.Context, job *models.Job) (*models.GameImport, error) {
+	pages := 0
+	ctx = rawg.WithPageHook(ctx, func() {
+		pages++
+		if err := q.store.UpdateJobProgress(job.ID, pages, 0); err != nil {
+			log.Printf("job %d: %v", job.ID, err)
+		}
+	})
+
+	return rawg.FetchGameImport(ctx, q.rawg, job.RAWGID)
+}
+
+// reason is the part of err that is safe to show the user who queued the job
+func reason(err error) string {
+	var apiErr *utils.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Message
+	}
+	return "internal error"
+}
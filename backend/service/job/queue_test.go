@@ -0,0 +1,237 @@
+package job
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/rawg"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/rawg/rawgtest"
+)
+
+func TestQueue(t *testing.T) {
+	server := rawgtest.NewServer(t)
+	store := newMockJobStore()
+	gameStore := newMockGameStore()
+
+	queue := NewQueue(store, gameStore, rawg.NewClient(server.ClientConfig()), 1, 3, time.Minute)
+	now := time.Date(2024, 8, 20, 12, 0, 0, 0, time.UTC)
+	queue.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	runNext := func(t *testing.T) bool {
+		t.Helper()
+		ran, err := queue.RunNext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ran
+	}
+
+	t.Run("should do nothing without jobs", func(t *testing.T) {
+		if runNext(t) {
+			t.Error("expected no job to run")
+		}
+	})
+
+	t.Run("should import a game and record progress", func(t *testing.T) {
+		job, err := queue.Enqueue(models.Job{Kind: models.JobImportGame, UserID: 1, RAWGID: rawgtest.Witcher3})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !runNext(t) {
+			t.Fatal("expected the job to run")
+		}
+
+		job = store.jobs[job.ID]
+		if job.Status != models.JobSucceeded || job.Attempts != 1 {
+			t.Fatalf("expected the job to succeed first time, got %+v", job)
+		}
+		// The game, both pages of achievements and the page of screenshots
+		if job.PagesFetched != 4 || job.AchievementsInserted != 5 {
+			t.Errorf("expected 4 pages and 5 achievements, got %d and %d", job.PagesFetched, job.AchievementsInserted)
+		}
+		if game := gameStore.games[job.GameID]; game == nil || game.RAWGID != rawgtest.Witcher3 {
+			t.Errorf("expected the job to point at the imported game, got %+v", game)
+		}
+		if !gameStore.tracked[[2]uint32{1, job.GameID}] {
+			t.Errorf("expected the game to be tracked for user 1")
+		}
+	})
+
+	t.Run("should retry with backoff while RAWG is down", func(t *testing.T) {
+		server.Fail("/games/9767", http.StatusServiceUnavailable)
+
+		job, err := queue.Enqueue(models.Job{Kind: models.JobImportGame, UserID: 1, RAWGID: rawgtest.HollowKnight})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		runNext(t)
+		if job = store.jobs[job.ID]; job.Status != models.JobQueued || !job.RunAt.Equal(now.Add(time.Minute)) || job.Error == "" {
+			t.Fatalf("expected a retry in a minute, got %+v", job)
+		}
+
+		if runNext(t) {
+			t.Fatal("expected the retry to wait for its backoff")
+		}
+
+		now = now.Add(time.Minute)
+		runNext(t)
+		if job = store.jobs[job.ID]; job.Status != models.JobQueued || !job.RunAt.Equal(now.Add(2*time.Minute)) {
+			t.Fatalf("expected the backoff to double, got %+v", job)
+		}
+
+		server.Recover("/games/9767")
+		now = now.Add(2 * time.Minute)
+		runNext(t)
+		if job = store.jobs[job.ID]; job.Status != models.JobSucceeded || job.Attempts != 3 || job.Error != "" {
+			t.Errorf("expected the third attempt to succeed, got %+v", job)
+		}
+	})
+
+	t.Run("should give up after the last attempt", func(t *testing.T) {
+		server.Fail("/games/10035", http.StatusBadGateway)
+		defer server.Recover("/games/10035")
+
+		job, err := queue.Enqueue(models.Job{Kind: models.JobImportGame, UserID: 1, RAWGID: 10035})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 3; i++ {
+			now = now.Add(time.Hour)
+			runNext(t)
+		}
+
+		if job = store.jobs[job.ID]; job.Status != models.JobFailed || job.Attempts != 3 || job.Error != "RAWG responded with 502" {
+			t.Errorf("expected the job to fail after 3 attempts, got %+v", job)
+		}
+	})
+
+	t.Run("should not retry a game RAWG doesn't know", func(t *testing.T) {
+		job, err := queue.Enqueue(models.Job{Kind: models.JobImportGame, UserID: 1, RAWGID: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		runNext(t)
+
+		if job = store.jobs[job.ID]; job.Status != models.JobFailed || job.Attempts != 1 || job.Error != "game '1' not found on RAWG" {
+			t.Errorf("expected the job to fail straight away, got %+v", job)
+		}
+	})
+
+	t.Run("should run jobs left running by a restart", func(t *testing.T) {
+		job, err := queue.Enqueue(models.Job{Kind: models.JobImportGame, UserID: 2, RAWGID: rawgtest.Witcher3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.jobs[job.ID].Status = models.JobRunning
+		store.jobs[job.ID].UpdatedAt = time.Now().Add(-time.Hour)
+
+		// Another server is still running this one
+		running, err := queue.Enqueue(models.Job{Kind: models.JobImportGame, UserID: 2, RAWGID: rawgtest.HollowKnight})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.jobs[running.ID].Status = models.JobRunning
+		store.jobs[running.ID].UpdatedAt = time.Now()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		if err := queue.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for store.status(job.ID) != models.JobSucceeded {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected a worker to run the job, got %+v", store.jobs[job.ID])
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if status := store.status(running.ID); status != models.JobRunning {
+			t.Errorf("expected the job another server is running to be left alone, got %s", status)
+		}
+	})
+
+	t.Run("should fail jobs abandoned on their last attempt", func(t *testing.T) {
+		job, err := queue.Enqueue(models.Job{Kind: models.JobImportGame, UserID: 2, RAWGID: rawgtest.Witcher3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.jobs[job.ID].Status = models.JobRunning
+		store.jobs[job.ID].Attempts = 3
+		store.jobs[job.ID].UpdatedAt = time.Now().Add(-time.Hour)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		if err := queue.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if status := store.status(job.ID); status != models.JobFailed {
+			t.Errorf("expected the job to fail, got %s", status)
+		}
+	})
+
+	// Workers of the subtests above may still be stopping, so this gets a
+	// queue of its own
+	fast := NewQueue(store, gameStore, rawg.NewClient(server.ClientConfig()), 1, 3, time.Minute)
+	fast.heartbeat = 10 * time.Millisecond
+
+	t.Run("should requeue jobs that go stale after startup", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		if err := fast.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		// A worker on another server died after the startup check
+		job, err := fast.Enqueue(models.Job{Kind: models.JobImportGame, UserID: 3, RAWGID: rawgtest.Witcher3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.mu.Lock()
+		store.jobs[job.ID].Status = models.JobRunning
+		store.jobs[job.ID].UpdatedAt = time.Now().Add(-time.Hour)
+		store.mu.Unlock()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for store.status(job.ID) != models.JobSucceeded {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the stale job to be requeued and run, got %s", store.status(job.ID))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("should keep running jobs alive", func(t *testing.T) {
+		// A store of its own, out of reach of the workers and requeues above
+		store := newMockJobStore()
+		alive := NewQueue(store, gameStore, rawg.NewClient(server.ClientConfig()), 1, 3, time.Minute)
+		alive.heartbeat = 10 * time.Millisecond
+
+		job, err := store.CreateJob(models.Job{Kind: models.JobImportGame, UserID: 4, RAWGID: rawgtest.Witcher3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.jobs[job.ID].Status = models.JobRunning
+		store.jobs[job.ID].UpdatedAt = time.Now().Add(-time.Hour)
+
+		stop := alive.keepAlive(job.ID)
+		time.Sleep(50 * time.Millisecond)
+		stop()
+
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		if since := time.Since(store.jobs[job.ID].UpdatedAt); since > staleAfter {
+			t.Errorf("expected the job to be touched, last updated %v ago", since)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/rawg"
+)
+
+// runResync refreshes an imported game from RAWG and records what changed
+// as the job's result
+func (q *Queue) runResync(ctx context.Context, job *models.Job) error {
+	game, err := q.gameStore.GetGameByRAWGID(job.RAWGID)
+	if err != nil {
+		return err
+	}
+
+	// A resync is pointless if it only sees what RAWG said last time
+	imp, err := q.fetch(rawg.WithoutCache(ctx), job)
+	if err != nil {
+		return err
+	}
+
+	report, err := q.gameStore.ResyncGame(game.ID, *imp)
+	if err != nil {
+		return err
+	}
+
+	if report.Changed() {
+		log.Printf("resynced game %d: %d achievements added, %d updated, %d completions reopened",
+			game.ID, len(report.AchievementsAdded), len(report.AchievementsUpdated), report.CompletionsReopened)
+	}
+
+	result, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	return q.store.CompleteJob(job.ID, game.ID, len(report.AchievementsAdded), result)
+}
+
+// SweepCatalog queues a resync of every game in the catalog and returns how
+// many were queued. Games whose last resync hasn't run yet are skipped, so
+// resyncs don't pile up when the workers fall behind.
+func (q *Queue) SweepCatalog() (int, error) {
+	games, err := q.gameStore.GetAllGames()
+	if err != nil {
+		return 0, err
+	}
+
+	pending, err := q.store.GetPendingRAWGIDs(models.JobResyncGame)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, game := range games {
+		if pending[game.RAWGID] {
+			continue
+		}
+		if _, err := q.Enqueue(models.Job{Kind: models.JobResyncGame, RAWGID: game.RAWGID}); err != nil {
+			return queued, err
+		}
+		queued++
+	}
+
+	return queued, nil
+}
+
+// ScheduleResync sweeps the catalog every interval until ctx is done
+func (q *Queue) ScheduleResync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := q.SweepCatalog()
+			if err != nil {
+				log.Printf("catalog resync: failed after queueing %d games: %v", n, err)
+				continue
+			}
+			log.Printf("catalog resync: queued %d games", n)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/rawg"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/rawg/rawgtest"
+)
+
+func TestResync(t *testing.T) {
+	server := rawgtest.NewServer(t)
+	store := newMockJobStore()
+	gameStore := newMockGameStore()
+	gameStore.games[1] = &models.Game{ID: 1, RAWGID: rawgtest.Witcher3, Name: "The Witcher 3: Wild Hunt"}
+	gameStore.games[2] = &models.Game{ID: 2, RAWGID: rawgtest.HollowKnight, Name: "Hollow Knight"}
+	// Imported before the DLC achievements on the second page existed
+	gameStore.achievements[1] = []models.Achievement{
+		{Name: "Lilac and Gooseberries"},
+		{Name: "The King is Dead"},
+		{Name: "Wolf in Sheep's Clothing"},
+	}
+
+	queue := NewQueue(store, gameStore, rawg.NewClient(server.ClientConfig()), 1, 3, time.Minute)
+	ctx := context.Background()
+
+	t.Run("should report what changed", func(t *testing.T) {
+		job, err := queue.Enqueue(models.Job{Kind: models.JobResyncGame, UserID: 1, RAWGID: rawgtest.Witcher3})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := queue.RunNext(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		job = store.jobs[job.ID]
+		if job.Status != models.JobSucceeded || job.GameID != 1 || job.AchievementsInserted != 2 || job.PagesFetched != 4 {
+			t.Fatalf("unexpected job %+v", job)
+		}
+
+		var report models.ResyncReport
+		if err := json.Unmarshal(job.Result, &report); err != nil {
+			t.Fatal(err)
+		}
+		if len(report.AchievementsAdded) != 2 || report.AchievementsAdded[0] != "Necromancer" || report.ScreenshotsAdded != 3 {
+			t.Errorf("expected the two new achievements and the screenshots in the report, got %+v", report)
+		}
+	})
+
+	t.Run("should fail for a game that is no longer in the catalog", func(t *testing.T) {
+		job, err := queue.Enqueue(models.Job{Kind: models.JobResyncGame, RAWGID: 10035})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := queue.RunNext(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if job = store.jobs[job.ID]; job.Status != models.JobFailed || job.Attempts != 1 {
+			t.Errorf("expected the job to fail straight away, got %+v", job)
+		}
+	})
+
+	t.Run("should queue a resync of every game", func(t *testing.T) {
+		before := len(store.jobs)
+
+		n, err := queue.SweepCatalog()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 || len(store.jobs) != before+2 {
+			t.Fatalf("expected 2 jobs to be queued, got %d", n)
+		}
+
+		// The workers haven't got to the last sweep's jobs yet
+		if n, err := queue.SweepCatalog(); err != nil || n != 0 || len(store.jobs) != before+2 {
+			t.Fatalf("expected no further jobs while resyncs are queued, got %d: %v", n, err)
+		}
+
+		for ran := true; ran; {
+			if ran, err = queue.RunNext(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for id := uint32(before + 1); id <= uint32(before+2); id++ {
+			if job := store.jobs[id]; job.Kind != models.JobResyncGame || job.UserID != 0 || job.Status != models.JobSucceeded {
+				t.Errorf("expected a finished system resync, got %+v", job)
+			}
+			// Nothing is new the second time round
+			if job := store.jobs[id]; job.AchievementsInserted != 0 {
+				t.Errorf("expected no new achievements, got %+v", job)
+			}
+		}
+	})
+}
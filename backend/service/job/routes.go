@@ -0,0 +1,47 @@
+package job
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store models.JobStore
+}
+
+func NewHandler(store models.JobStore) *Handler {
+	return &Handler{store: store}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/jobs/{id:[0-9]+}", utils.MakeHandler(h.handleGetJob)).Methods("GET")
+}
+
+func (h *Handler) handleGetJob(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		return utils.Invalid("invalid job id: %v", err)
+	}
+
+	userID, err := auth.ActingUserID(r, 0)
+	if err != nil {
+		return err
+	}
+
+	job, err := h.store.GetJobByID(uint32(id))
+	if err != nil {
+		return err
+	}
+
+	// Other users' jobs, and jobs the system queued, are none of the user's business
+	if job.UserID != userID {
+		return utils.NotFound("job not found with id '%d'", id)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, job)
+}
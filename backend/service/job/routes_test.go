@@ -0,0 +1,298 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestGetJob(t *testing.T) {
+	store := newMockJobStore()
+	store.CreateJob(models.Job{Kind: models.JobImportGame, UserID: 1, RAWGID: 3328})
+	store.jobs[1].PagesFetched = 2
+
+	handler := NewHandler(store)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	getJob := func(path string, userID uint32) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if userID != 0 {
+			req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, userID))
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should return the user's job", func(t *testing.T) {
+		rr := getJob("/jobs/1", 1)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var job models.Job
+		if err := json.NewDecoder(rr.Body).Decode(&job); err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != models.JobQueued || job.PagesFetched != 2 || job.RAWGID != 3328 {
+			t.Errorf("unexpected job %+v", job)
+		}
+	})
+
+	t.Run("should hide other users' jobs", func(t *testing.T) {
+		if rr := getJob("/jobs/1", 2); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should fail for an unknown job", func(t *testing.T) {
+		if rr := getJob("/jobs/2", 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should fail without an authenticated user", func(t *testing.T) {
+		if rr := getJob("/jobs/1", 0); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+}
+
+type mockJobStore struct {
+	mu   sync.Mutex
+	jobs map[uint32]*models.Job
+}
+
+func newMockJobStore() *mockJobStore {
+	return &mockJobStore{jobs: make(map[uint32]*models.Job)}
+}
+
+func (s *mockJobStore) status(id uint32) models.JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[id].Status
+}
+
+func (s *mockJobStore) CreateJob(job models.Job) (*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job.ID = uint32(len(s.jobs) + 1)
+	job.Status = models.JobQueued
+	s.jobs[job.ID] = &job
+
+	created := job
+	return &created, nil
+}
+
+func (s *mockJobStore) GetJobByID(id uint32) (*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, utils.NotFound("job not found with id '%d'", id)
+	}
+
+	found := *job
+	return &found, nil
+}
+
+func (s *mockJobStore) GetPendingRAWGIDs(kind string) (map[uint]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := make(map[uint]bool)
+	for _, job := range s.jobs {
+		if job.Kind == kind && (job.Status == models.JobQueued || job.Status == models.JobRunning) {
+			pending[job.RAWGID] = true
+		}
+	}
+	return pending, nil
+}
+
+func (s *mockJobStore) ClaimJob(now time.Time) (*models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]uint32, 0, len(s.jobs))
+	for id := range s.jobs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		job := s.jobs[id]
+		if job.Status == models.JobQueued && !job.RunAt.After(now) {
+			job.Status = models.JobRunning
+			job.Attempts++
+			job.UpdatedAt = time.Now()
+
+			claimed := *job
+			return &claimed, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *mockJobStore) UpdateJobProgress(id uint32, pagesFetched, achievementsInserted int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[id].PagesFetched = pagesFetched
+	s.jobs[id].AchievementsInserted = achievementsInserted
+	return nil
+}
+
+func (s *mockJobStore) CompleteJob(id, gameID uint32, achievementsInserted int, result json.RawMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[id].Status = models.JobSucceeded
+	s.jobs[id].Result = result
+	s.jobs[id].GameID = gameID
+	s.jobs[id].AchievementsInserted = achievementsInserted
+	s.jobs[id].Error = ""
+	return nil
+}
+
+func (s *mockJobStore) RetryJob(id uint32, runAt time.Time, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[id].Status = models.JobQueued
+	s.jobs[id].RunAt = runAt
+	s.jobs[id].Error = reason
+	return nil
+}
+
+func (s *mockJobStore) FailJob(id uint32, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[id].Status = models.JobFailed
+	s.jobs[id].Error = reason
+	return nil
+}
+
+func (s *mockJobStore) TouchJob(id uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job := s.jobs[id]; job.Status == models.JobRunning {
+		job.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (s *mockJobStore) RequeueStaleJobs(staleAfter time.Duration, maxAttempts int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.Status != models.JobRunning || time.Since(job.UpdatedAt) <= staleAfter {
+			continue
+		}
+		job.Error = abandonedReason
+		if job.Attempts >= maxAttempts {
+			job.Status = models.JobFailed
+		} else {
+			job.Status = models.JobQueued
+		}
+	}
+	return nil
+}
+
+type mockGameStore struct {
+	models.GameStore
+	games        map[uint32]*models.Game
+	achievements map[uint32][]models.Achievement
+	screenshots  map[uint32][]models.Screenshot
+	tracked      map[[2]uint32]bool
+}
+
+func newMockGameStore() *mockGameStore {
+	return &mockGameStore{
+		games:        make(map[uint32]*models.Game),
+		achievements: make(map[uint32][]models.Achievement),
+		screenshots:  make(map[uint32][]models.Screenshot),
+		tracked:      make(map[[2]uint32]bool),
+	}
+}
+
+func (s *mockGameStore) ImportGame(imp models.GameImport, userID uint32) (*models.Game, bool, error) {
+	var game *models.Game
+	for _, g := range s.games {
+		if g.RAWGID == imp.Game.RAWGID {
+			game = g
+		}
+	}
+
+	created := game == nil
+	if created {
+		game = &imp.Game
+		game.ID = uint32(len(s.games) + 1)
+		s.games[game.ID] = game
+	}
+
+	s.tracked[[2]uint32{userID, game.ID}] = true
+	return game, created, nil
+}
+
+func (s *mockGameStore) GetGameByRAWGID(rawgID uint) (*models.Game, error) {
+	for _, g := range s.games {
+		if g.RAWGID == rawgID {
+			return g, nil
+		}
+	}
+	return nil, utils.NotFound("game not found with rawg id '%d'", rawgID)
+}
+
+func (s *mockGameStore) GetAllGames() ([]*models.Game, error) {
+	games := make([]*models.Game, 0, len(s.games))
+	for _, g := range s.games {
+		games = append(games, g)
+	}
+	return games, nil
+}
+
+// ResyncGame reports every fetched achievement and screenshot the game
+// didn't have as added
+func (s *mockGameStore) ResyncGame(gameID uint32, imp models.GameImport) (*models.ResyncReport, error) {
+	report := &models.ResyncReport{GameID: gameID}
+
+	known := make(map[string]bool)
+	for _, a := range s.achievements[gameID] {
+		known[a.Name] = true
+	}
+	for _, a := range imp.Achievements {
+		if !known[a.Name] {
+			report.AchievementsAdded = append(report.AchievementsAdded, a.Name)
+		}
+	}
+	s.achievements[gameID] = imp.Achievements
+
+	knownScreenshots := make(map[uint32]bool)
+	for _, sc := range s.screenshots[gameID] {
+		knownScreenshots[sc.RAWGID] = true
+	}
+	for _, sc := range imp.Screenshots {
+		if !knownScreenshots[sc.RAWGID] {
+			report.ScreenshotsAdded++
+		}
+	}
+	s.screenshots[gameID] = imp.Screenshots
+
+	return report, nil
+}
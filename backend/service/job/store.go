@@ -0,0 +1,198 @@
+package job
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+)
+
+const jobColumns = "id, kind, user_id, rawg_id, status, attempts, pages_fetched, achievements_inserted, game_id, error, result, run_at, created_at, updated_at"
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateJob(job models.Job) (*models.Job, error) {
+	userID := sql.NullInt64{Int64: int64(job.UserID), Valid: job.UserID != 0}
+
+	result, err := s.db.Exec("INSERT INTO jobs (kind, user_id, rawg_id, status, run_at) VALUES (?, ?, ?, ?, ?)",
+		job.Kind, userID, job.RAWGID, models.JobQueued, job.RunAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetJobByID(uint32(id))
+}
+
+func (s *Store) GetJobByID(id uint32) (*models.Job, error) {
+	row := s.db.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = ?", id)
+
+	var job models.Job
+	if err := scanJob(row, &job); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("job not found with id '%d'", id)
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (s *Store) GetPendingRAWGIDs(kind string) (map[uint]bool, error) {
+	rows, err := s.db.Query("SELECT DISTINCT rawg_id FROM jobs WHERE kind = ? AND status IN (?, ?)",
+		kind, models.JobQueued, models.JobRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pending := make(map[uint]bool)
+	for rows.Next() {
+		var rawgID uint
+		if err := rows.Scan(&rawgID); err != nil {
+			return nil, err
+		}
+		pending[rawgID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pending, nil
+}
+
+func (s *Store) ClaimJob(now time.Time) (*models.Job, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED lets each worker claim a different job
+	row := tx.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE status = ? AND run_at <= ? ORDER BY run_at, id LIMIT 1 FOR UPDATE SKIP LOCKED",
+		models.JobQueued, now)
+
+	var job models.Job
+	if err := scanJob(row, &job); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE jobs SET status = ?, attempts = attempts + 1 WHERE id = ?", models.JobRunning, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	job.Status = models.JobRunning
+	job.Attempts++
+
+	return &job, nil
+}
+
+func (s *Store) UpdateJobProgress(id uint32, pagesFetched, achievementsInserted int) error {
+	_, err := s.db.Exec("UPDATE jobs SET pages_fetched = ?, achievements_inserted = ? WHERE id = ?",
+		pagesFetched, achievementsInserted, id)
+	if err != nil {
+		return fmt.Errorf("failed to update job progress: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) CompleteJob(id, gameID uint32, achievementsInserted int, result json.RawMessage) error {
+	res := sql.NullString{String: string(result), Valid: len(result) > 0}
+
+	_, err := s.db.Exec("UPDATE jobs SET status = ?, game_id = ?, achievements_inserted = ?, result = ?, error = NULL WHERE id = ?",
+		models.JobSucceeded, gameID, achievementsInserted, res, id)
+	if err != nil {
+		return fmt.Errorf("failed to complete job: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) RetryJob(id uint32, runAt time.Time, reason string) error {
+	_, err := s.db.Exec("UPDATE jobs SET status = ?, run_at = ?, error = ? WHERE id = ?",
+		models.JobQueued, runAt, reason, id)
+	if err != nil {
+		return fmt.Errorf("failed to retry job: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) FailJob(id uint32, reason string) error {
+	_, err := s.db.Exec("UPDATE jobs SET status = ?, error = ? WHERE id = ?", models.JobFailed, reason, id)
+	if err != nil {
+		return fmt.Errorf("failed to fail job: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) TouchJob(id uint32) error {
+	_, err := s.db.Exec("UPDATE jobs SET updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?", id, models.JobRunning)
+	if err != nil {
+		return fmt.Errorf("failed to touch job: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) RequeueStaleJobs(staleAfter time.Duration, maxAttempts int) error {
+	// The claim that was abandoned counted as an attempt
+	_, err := s.db.Exec("UPDATE jobs SET status = ?, error = ? WHERE status = ? AND updated_at < NOW() - INTERVAL ? SECOND AND attempts >= ?",
+		models.JobFailed, abandonedReason, models.JobRunning, int(staleAfter.Seconds()), maxAttempts)
+	if err != nil {
+		return fmt.Errorf("failed to fail abandoned jobs: %v", err)
+	}
+
+	_, err = s.db.Exec("UPDATE jobs SET status = ?, error = ? WHERE status = ? AND updated_at < NOW() - INTERVAL ? SECOND",
+		models.JobQueued, abandonedReason, models.JobRunning, int(staleAfter.Seconds()))
+	if err != nil {
+		return fmt.Errorf("failed to requeue jobs: %v", err)
+	}
+
+	return nil
+}
+
+func scanJob(scanner interface {
+	Scan(dest ...interface{}) error
+}, job *models.Job) error {
+	var userID, gameID sql.NullInt64
+	var reason, result sql.NullString
+
+	err := scanner.Scan(&job.ID, &job.Kind, &userID, &job.RAWGID, &job.Status, &job.Attempts, &job.PagesFetched,
+		&job.AchievementsInserted, &gameID, &reason, &result, &job.RunAt, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	job.UserID = uint32(userID.Int64)
+	job.GameID = uint32(gameID.Int64)
+	job.Error = reason.String
+	if result.Valid {
+		job.Result = json.RawMessage(result.String)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package leaderboard
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// Handler ranks users for friendly competitions. Every leaderboard takes a
+// window query parameter, "month", "year" or "all" (the default), counting
+// only what was earned since the start of the current month or year.
+type Handler struct {
+	store     models.LeaderboardStore
+	gameStore models.GameStore
+	now       func() time.Time
+}
+
+func NewHandler(store models.LeaderboardStore, gameStore models.GameStore) *Handler {
+	return &Handler{store: store, gameStore: gameStore, now: time.Now}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/leaderboards/{board:platinums|trophies}", utils.MakeHandler(h.handleGetLeaderboard)).Methods("GET")
+	router.HandleFunc("/leaderboards/{board:platinums|trophies}/me", utils.MakeHandler(h.handleGetMyRank)).Methods("GET")
+	router.HandleFunc("/leaderboards/games/{id:[0-9]+}/fastest", utils.MakeHandler(h.handleGetLeaderboard)).Methods("GET")
+	router.HandleFunc("/leaderboards/games/{id:[0-9]+}/fastest/me", utils.MakeHandler(h.handleGetMyRank)).Methods("GET")
+}
+
+// handleGetLeaderboard returns a page of a leaderboard, set by the limit and
+// offset query parameters
+func (h *Handler) handleGetLeaderboard(w http.ResponseWriter, r *http.Request) error {
+	q, window, err := h.parseQuery(r)
+	if err != nil {
+		return err
+	}
+
+	params := r.URL.Query()
+
+	limit := defaultLimit
+	if l := params.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxLimit {
+			return utils.Invalid("limit must be a number from 1 to %d", maxLimit)
+		}
+		limit = n
+	}
+
+	offset := 0
+	if o := params.Get("offset"); o != "" {
+		n, err := strconv.Atoi(o)
+		if err != nil || n < 0 {
+			return utils.Invalid("offset must be a number of 0 or more")
+		}
+		offset = n
+	}
+
+	entries, total, err := h.store.GetLeaderboard(q, limit, offset)
+	if err != nil {
+		return fmt.Errorf("error receiving leaderboard: %w", err)
+	}
+
+	board := models.Leaderboard{
+		Board:   q.Board,
+		Window:  window,
+		GameID:  q.GameID,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+		Entries: make([]models.LeaderboardEntry, 0, len(entries)),
+	}
+	for _, e := range entries {
+		board.Entries = append(board.Entries, *e)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, board)
+}
+
+// handleGetMyRank returns the requester's place on a leaderboard, or a 404
+// when they aren't on it
+func (h *Handler) handleGetMyRank(w http.ResponseWriter, r *http.Request) error {
+	q, _, err := h.parseQuery(r)
+	if err != nil {
+		return err
+	}
+
+	entry, err := h.store.GetLeaderboardEntry(q, auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, entry)
+}
+
+// parseQuery reads which leaderboard is asked for from the path, and its
+// window from the query
+func (h *Handler) parseQuery(r *http.Request) (models.LeaderboardQuery, string, error) {
+	vars := mux.Vars(r)
+
+	q := models.LeaderboardQuery{Board: vars["board"]}
+	if id, ok := vars["id"]; ok {
+		gameID, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return q, "", utils.Invalid("invalid game id: %v", err)
+		}
+		if _, err := h.gameStore.GetGameByID(uint(gameID)); err != nil {
+			return q, "", err
+		}
+		q.Board = models.LeaderboardFastest
+		q.GameID = uint32(gameID)
+	}
+
+	window := r.URL.Query().Get("window")
+	now := h.now().UTC()
+	switch window {
+	case "", models.WindowAll:
+		window = models.WindowAll
+	case models.WindowMonth:
+		since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		q.Since = &since
+	case models.WindowYear:
+		since := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+		q.Since = &since
+	default:
+		return q, "", utils.Invalid("unknown window '%s', expected month, year or all", window)
+	}
+
+	return q, window, nil
+}
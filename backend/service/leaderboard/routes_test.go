@@ -0,0 +1,185 @@
+package leaderboard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestLeaderboards(t *testing.T) {
+	store := &mockLeaderboardStore{boards: map[string][]*models.LeaderboardEntry{
+		models.LeaderboardPlatinums: {
+			{Rank: 1, UserID: 2, Username: "gooseberries", Value: 12},
+			{Rank: 2, UserID: 1, Username: "adamjtroup", Value: 7},
+			{Rank: 2, UserID: 3, Username: "kaermorhen", Value: 7},
+		},
+		models.LeaderboardFastest: {
+			{Rank: 1, UserID: 3, Username: "kaermorhen", Value: 86400},
+		},
+	}}
+	games := &mockGameStore{games: map[uint]*models.Game{7: {ID: 7, Name: "The Witcher 3: Wild Hunt"}}}
+
+	handler := NewHandler(store, games)
+	handler.now = func() time.Time { return time.Date(2024, 8, 29, 18, 30, 0, 0, time.UTC) }
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	get := func(path string, userID uint32) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, userID))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	decode := func(t *testing.T, rr *httptest.ResponseRecorder) models.Leaderboard {
+		t.Helper()
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var board models.Leaderboard
+		if err := json.NewDecoder(rr.Body).Decode(&board); err != nil {
+			t.Fatal(err)
+		}
+		return board
+	}
+
+	t.Run("should list a page of the leaderboard", func(t *testing.T) {
+		board := decode(t, get("/leaderboards/platinums?limit=2&offset=1", 1))
+
+		if board.Board != models.LeaderboardPlatinums || board.Window != models.WindowAll || board.Total != 3 || board.Limit != 2 || board.Offset != 1 {
+			t.Errorf("unexpected leaderboard %+v", board)
+		}
+		if len(board.Entries) != 2 || board.Entries[0].UserID != 1 || board.Entries[1].Rank != 2 {
+			t.Errorf("expected the users tied second, got %+v", board.Entries)
+		}
+		if store.query.Since != nil {
+			t.Errorf("expected all time, got since %v", store.query.Since)
+		}
+	})
+
+	t.Run("should list nobody past the last page", func(t *testing.T) {
+		board := decode(t, get("/leaderboards/trophies?offset=20", 1))
+		if board.Entries == nil || len(board.Entries) != 0 {
+			t.Errorf("expected no entries, got %+v", board.Entries)
+		}
+	})
+
+	t.Run("should count from the start of the window", func(t *testing.T) {
+		tests := []struct {
+			window string
+			since  time.Time
+		}{
+			{models.WindowMonth, time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)},
+			{models.WindowYear, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		}
+		for _, tt := range tests {
+			board := decode(t, get("/leaderboards/platinums?window="+tt.window, 1))
+			if board.Window != tt.window || store.query.Since == nil || !store.query.Since.Equal(tt.since) {
+				t.Errorf("expected window %s since %v, got %s since %v", tt.window, tt.since, board.Window, store.query.Since)
+			}
+		}
+	})
+
+	t.Run("should rank a game's fastest platinums", func(t *testing.T) {
+		board := decode(t, get("/leaderboards/games/7/fastest", 1))
+
+		if board.Board != models.LeaderboardFastest || board.GameID != 7 || len(board.Entries) != 1 || board.Entries[0].Value != 86400 {
+			t.Errorf("unexpected leaderboard %+v", board)
+		}
+		if store.query.GameID != 7 {
+			t.Errorf("expected game 7, got %d", store.query.GameID)
+		}
+	})
+
+	t.Run("should look up the requester's rank", func(t *testing.T) {
+		rr := get("/leaderboards/platinums/me", 3)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var entry models.LeaderboardEntry
+		if err := json.NewDecoder(rr.Body).Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.UserID != 3 || entry.Rank != 2 || entry.Value != 7 {
+			t.Errorf("unexpected entry %+v", entry)
+		}
+
+		if rr := get("/leaderboards/games/7/fastest/me", 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for an unranked user, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should fail for bad parameters", func(t *testing.T) {
+		for _, path := range []string{
+			"/leaderboards/platinums?window=week",
+			"/leaderboards/platinums?limit=0",
+			"/leaderboards/platinums?limit=101",
+			"/leaderboards/platinums?offset=-1",
+		} {
+			if rr := get(path, 1); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusBadRequest, path, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should fail for an unknown game", func(t *testing.T) {
+		if rr := get("/leaderboards/games/8/fastest", 1); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+// mockLeaderboardStore serves ranked leaderboards as the store would rank
+// them, remembering the last query
+type mockLeaderboardStore struct {
+	boards map[string][]*models.LeaderboardEntry
+	query  models.LeaderboardQuery
+}
+
+func (s *mockLeaderboardStore) GetLeaderboard(q models.LeaderboardQuery, limit, offset int) ([]*models.LeaderboardEntry, int, error) {
+	s.query = q
+	entries := s.boards[q.Board]
+	if offset >= len(entries) {
+		return []*models.LeaderboardEntry{}, len(entries), nil
+	}
+	return entries[offset:min(offset+limit, len(entries))], len(entries), nil
+}
+
+func (s *mockLeaderboardStore) GetLeaderboardEntry(q models.LeaderboardQuery, userID uint32) (*models.LeaderboardEntry, error) {
+	s.query = q
+	for _, e := range s.boards[q.Board] {
+		if e.UserID == userID {
+			return e, nil
+		}
+	}
+	return nil, utils.NotFound("user '%d' isn't on the %s leaderboard", userID, q.Board)
+}
+
+type mockGameStore struct {
+	models.GameStore
+	games map[uint]*models.Game
+}
+
+func (s *mockGameStore) GetGameByID(id uint) (*models.Game, error) {
+	g, ok := s.games[id]
+	if !ok {
+		return nil, utils.NotFound("game not found with id '%d'", id)
+	}
+	return g, nil
+}
@@ -0,0 +1,126 @@
+package leaderboard
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetLeaderboard(q models.LeaderboardQuery, limit, offset int) ([]*models.LeaderboardEntry, int, error) {
+	ranked, args, err := rankedQuery(q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM ("+ranked+") ranked", args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query("SELECT position, user_id, username, imgurl, value FROM ("+ranked+") ranked ORDER BY position, reached_at, user_id LIMIT ? OFFSET ?",
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []*models.LeaderboardEntry{}
+	for rows.Next() {
+		var e models.LeaderboardEntry
+		if err := scanEntry(rows, &e); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+func (s *Store) GetLeaderboardEntry(q models.LeaderboardQuery, userID uint32) (*models.LeaderboardEntry, error) {
+	ranked, args, err := rankedQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	row := s.db.QueryRow("SELECT position, user_id, username, imgurl, value FROM ("+ranked+") ranked WHERE user_id = ?",
+		append(args, userID)...)
+
+	var e models.LeaderboardEntry
+	if err := scanEntry(row, &e); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("user '%d' isn't on the %s leaderboard", userID, q.Board)
+		}
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// rankedQuery ranks every active user on the leaderboard. The scores it ranks
+// are a user_id, the value ranked on and when the user reached it, which
+// breaks ties in listings.
+func rankedQuery(q models.LeaderboardQuery) (string, []interface{}, error) {
+	var scores, order string
+	var args []interface{}
+
+	switch q.Board {
+	case models.LeaderboardPlatinums:
+		scores = "SELECT user_id, COUNT(*) AS value, MAX(completed_at) AS reached_at FROM user_games WHERE completed_at IS NOT NULL"
+		if q.Since != nil {
+			scores += " AND completed_at >= ?"
+			args = append(args, *q.Since)
+		}
+		scores += " GROUP BY user_id"
+		order = "DESC"
+	case models.LeaderboardTrophies:
+		scores = "SELECT user_id, COUNT(*) AS value, MAX(completed_at) AS reached_at FROM user_achievements WHERE completed = TRUE"
+		if q.Since != nil {
+			scores += " AND completed_at >= ?"
+			args = append(args, *q.Since)
+		}
+		scores += " GROUP BY user_id"
+		order = "DESC"
+	case models.LeaderboardFastest:
+		// Backdated unlocks can put a platinum before the game was tracked,
+		// those don't count as fast
+		scores = "SELECT user_id, TIMESTAMPDIFF(SECOND, tracked_at, completed_at) AS value, completed_at AS reached_at FROM user_games WHERE game_id = ? AND completed_at IS NOT NULL AND completed_at >= tracked_at"
+		args = append(args, q.GameID)
+		if q.Since != nil {
+			scores += " AND completed_at >= ?"
+			args = append(args, *q.Since)
+		}
+		order = "ASC"
+	default:
+		return "", nil, fmt.Errorf("unknown leaderboard '%s'", q.Board)
+	}
+
+	return `
+		SELECT RANK() OVER (ORDER BY s.value ` + order + `) AS position, s.user_id, u.username, u.imgurl, s.value, s.reached_at
+		FROM (` + scores + `) s
+		JOIN users u ON u.id = s.user_id
+		WHERE u.deactivated = FALSE`, args, nil
+}
+
+func scanEntry(scanner interface {
+	Scan(dest ...interface{}) error
+}, e *models.LeaderboardEntry) error {
+	var imgURL sql.NullString
+	if err := scanner.Scan(&e.Rank, &e.UserID, &e.Username, &imgURL, &e.Value); err != nil {
+		return err
+	}
+	e.ImgURL = imgURL.String
+	return nil
+}
@@ -0,0 +1,29 @@
+package mail
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+)
+
+// LogMailer writes mail to a file or the console instead of sending it,
+// so verification and reset links can be followed during local development
+type LogMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLogMailer(w io.Writer) *LogMailer {
+	return &LogMailer{w: w}
+}
+
+func (m *LogMailer) Send(mail models.Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.w, "---- %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), mail.To, mail.Subject, mail.Body)
+	return err
+}
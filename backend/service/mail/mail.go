@@ -0,0 +1,27 @@
+package mail
+
+import (
+	"os"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/config"
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+)
+
+// NewMailer sends through SMTP when SMTP_HOST is set and otherwise logs mail
+// to MAIL_LOG_FILE, or stdout if that isn't set either
+func NewMailer(cfg config.Config) (models.Mailer, error) {
+	if cfg.SMTPHost != "" {
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.MailFrom), nil
+	}
+
+	if cfg.MailLogFile == "" {
+		return NewLogMailer(os.Stdout), nil
+	}
+
+	f, err := os.OpenFile(cfg.MailLogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLogMailer(f), nil
+}
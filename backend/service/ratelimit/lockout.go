@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+)
+
+// Lockout locks a key, e.g. an account, after too many failed attempts.
+// Every failure past maxFailures doubles the lock, starting at baseDelay and capped at maxDelay.
+type Lockout struct {
+	store       models.RateLimitStore
+	maxFailures int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	now         func() time.Time
+}
+
+func NewLockout(store models.RateLimitStore, maxFailures int, baseDelay, maxDelay time.Duration) *Lockout {
+	return &Lockout{
+		store:       store,
+		maxFailures: maxFailures,
+		baseDelay:   baseDelay,
+		maxDelay:    maxDelay,
+		now:         time.Now,
+	}
+}
+
+// Check returns false and the time left if key is locked
+func (l *Lockout) Check(key string) (bool, time.Duration) {
+	count, last := l.store.GetFailures(key)
+	if remaining := last.Add(l.delay(count)).Sub(l.now()); remaining > 0 {
+		return false, remaining
+	}
+
+	return true, 0
+}
+
+// Fail records a failed attempt and returns how long key is now locked for, if at all
+func (l *Lockout) Fail(key string) time.Duration {
+	return l.delay(l.store.AddFailure(key, l.now()))
+}
+
+func (l *Lockout) Reset(key string) {
+	l.store.ResetFailures(key)
+}
+
+func (l *Lockout) delay(failures int) time.Duration {
+	if failures < l.maxFailures {
+		return 0
+	}
+
+	delay := l.baseDelay
+	for i := l.maxFailures; i < failures; i++ {
+		delay *= 2
+		if delay >= l.maxDelay {
+			return l.maxDelay
+		}
+	}
+
+	return delay
+}
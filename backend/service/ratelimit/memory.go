@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Entries untouched for this long are dropped, a full bucket or a forgotten failure count looks the same as none
+const idleTimeout = 24 * time.Hour
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type failure struct {
+	count int
+	last  time.Time
+}
+
+// MemoryStore keeps rate limit state in process. It is enough for a single
+// API instance; several instances would need a shared implementation.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	failures  map[string]*failure
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:  make(map[string]*bucket),
+		failures: make(map[string]*failure),
+	}
+}
+
+func (s *MemoryStore) TakeToken(key string, rate float64, burst int, now time.Time) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		s.buckets[key] = b
+	}
+
+	// Refill for the time since the last request
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(burst), b.tokens+elapsed*rate)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+func (s *MemoryStore) ReturnToken(key string, burst int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.buckets[key]; ok {
+		b.tokens = math.Min(float64(burst), b.tokens+1)
+	}
+}
+
+func (s *MemoryStore) AddFailure(key string, now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	f, ok := s.failures[key]
+	if !ok {
+		f = &failure{}
+		s.failures[key] = f
+	}
+	f.count++
+	f.last = now
+
+	return f.count
+}
+
+func (s *MemoryStore) GetFailures(key string) (int, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.failures[key]
+	if !ok {
+		return 0, time.Time{}
+	}
+
+	return f.count, f.last
+}
+
+func (s *MemoryStore) ResetFailures(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, key)
+}
+
+// sweep drops idle entries at most once a minute so the maps don't grow forever.
+// The caller must hold the lock.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > idleTimeout {
+			delete(s.buckets, key)
+		}
+	}
+	for key, f := range s.failures {
+		if now.Sub(f.last) > idleTimeout {
+			delete(s.failures, key)
+		}
+	}
+}
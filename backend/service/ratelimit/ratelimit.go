@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+// Policy is a token bucket applied to a route, per client IP and/or per authenticated user
+type Policy struct {
+	Rate    float64 // Tokens added per second
+	Burst   int     // Bucket size
+	PerIP   bool
+	PerUser bool
+}
+
+func PerMinute(n int) float64 {
+	return float64(n) / 60
+}
+
+func PerHour(n int) float64 {
+	return float64(n) / 3600
+}
+
+// Limiter applies per-route policies to requests. Routes without their own
+// policy share the fallback policy.
+type Limiter struct {
+	store    models.RateLimitStore
+	fallback Policy
+	policies map[string]Policy
+	now      func() time.Time
+}
+
+func NewLimiter(store models.RateLimitStore, fallback Policy) *Limiter {
+	return &Limiter{
+		store:    store,
+		fallback: fallback,
+		policies: make(map[string]Policy),
+		now:      time.Now,
+	}
+}
+
+// Limit sets the policy for a route, identified by its full path template, e.g. "/api/v1/users/{id:[0-9]+}"
+func (l *Limiter) Limit(pathTemplate string, policy Policy) *Limiter {
+	l.policies[pathTemplate] = policy
+	return l
+}
+
+// Middleware must run after auth.JWTMiddleware for per-user limits to apply
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, policy := l.policyFor(r)
+
+		var keys []string
+		if policy.PerIP {
+			keys = append(keys, name+"|ip|"+utils.ClientIP(r))
+		}
+		if userID := auth.GetUserIDFromContext(r.Context()); policy.PerUser && userID != 0 {
+			keys = append(keys, name+"|user|"+strconv.Itoa(int(userID)))
+		}
+
+		// A request rejected by one bucket doesn't run, so it gives back what
+		// it took from the others. Otherwise a user over their limit would use
+		// up the limit of everyone sharing their IP.
+		now := l.now()
+		for i, key := range keys {
+			if ok, retryAfter := l.store.TakeToken(key, policy.Rate, policy.Burst, now); !ok {
+				for _, taken := range keys[:i] {
+					l.store.ReturnToken(taken, policy.Burst)
+				}
+				WriteTooManyRequests(w, retryAfter)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *Limiter) policyFor(r *http.Request) (string, Policy) {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			if policy, ok := l.policies[tpl]; ok {
+				return tpl, policy
+			}
+		}
+	}
+
+	return "*", l.fallback
+}
+
+// WriteTooManyRequests responds with a 429 and tells the client when to try again
+func WriteTooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	utils.WriteError(w, http.StatusTooManyRequests, TooManyRequests(w, retryAfter))
+}
+
+// TooManyRequests sets the Retry-After header for a handler that is about to
+// return the error
+func TooManyRequests(w http.ResponseWriter, retryAfter time.Duration) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	return utils.TooManyRequests("too many requests, retry in %d seconds", seconds)
+}
@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/gorilla/mux"
+)
+
+func TestTakeToken(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2024, time.August, 20, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := store.TakeToken("key", 1, 3, now); !ok {
+			t.Fatalf("expected request %d to fit in the burst", i+1)
+		}
+	}
+
+	ok, retryAfter := store.TakeToken("key", 1, 3, now)
+	if ok {
+		t.Fatal("expected the bucket to be empty")
+	}
+	if retryAfter != time.Second {
+		t.Errorf("expected to retry after 1s, got %v", retryAfter)
+	}
+
+	if ok, _ := store.TakeToken("key", 1, 3, now.Add(time.Second)); !ok {
+		t.Error("expected the bucket to refill after a second")
+	}
+	if ok, _ := store.TakeToken("other", 1, 3, now); !ok {
+		t.Error("expected other keys to have their own bucket")
+	}
+}
+
+func TestLimiterMiddleware(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore(), Policy{Rate: 100, Burst: 100, PerIP: true}).
+		Limit("/game-search", Policy{Rate: 1, Burst: 2, PerUser: true})
+	now := time.Date(2024, time.August, 20, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	router := mux.NewRouter()
+	router.Use(limiter.Middleware)
+	router.HandleFunc("/game-search", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	search := func(userID uint32) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/game-search", nil)
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, userID))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		rr := search(1)
+		if rr.Code != want {
+			t.Errorf("request %d: expected status code %d, got %d", i+1, want, rr.Code)
+		}
+		if want == http.StatusTooManyRequests && rr.Header().Get("Retry-After") != "1" {
+			t.Errorf("expected Retry-After of 1 second, got %q", rr.Header().Get("Retry-After"))
+		}
+	}
+
+	if rr := search(2); rr.Code != http.StatusOK {
+		t.Errorf("expected another user to have their own bucket, got %d", rr.Code)
+	}
+}
+
+func TestLimiterMiddlewareSharedIP(t *testing.T) {
+	store := NewMemoryStore()
+	limiter := NewLimiter(store, Policy{Rate: 1, Burst: 3, PerIP: true, PerUser: true})
+	now := time.Date(2024, time.August, 20, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	router := mux.NewRouter()
+	router.Use(limiter.Middleware)
+	router.HandleFunc("/games", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	get := func(userID uint32) int {
+		req := httptest.NewRequest(http.MethodGet, "/games", nil)
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, userID))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// User 1 spent a token from another IP, so they run out before this IP
+	// does and keep retrying
+	store.TakeToken("*|user|1", 1, 3, now)
+	for i := 0; i < 5; i++ {
+		get(1)
+	}
+
+	if code := get(2); code != http.StatusOK {
+		t.Errorf("expected another user behind the same IP to get through, got %d", code)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	lockout := NewLockout(NewMemoryStore(), 3, time.Minute, 5*time.Minute)
+	now := time.Date(2024, time.August, 20, 12, 0, 0, 0, time.UTC)
+	lockout.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if d := lockout.Fail("adamjtroup"); d != 0 {
+			t.Fatalf("expected no lock after %d failures, got %v", i+1, d)
+		}
+	}
+
+	// Every failure past the limit doubles the lock, up to the maximum
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute} {
+		if d := lockout.Fail("adamjtroup"); d != want {
+			t.Errorf("expected a %v lock, got %v", want, d)
+		}
+	}
+
+	if ok, remaining := lockout.Check("adamjtroup"); ok || remaining != 5*time.Minute {
+		t.Errorf("expected to be locked for 5m, got %v %v", ok, remaining)
+	}
+
+	now = now.Add(5 * time.Minute)
+	if ok, _ := lockout.Check("adamjtroup"); !ok {
+		t.Error("expected the lock to expire")
+	}
+
+	lockout.Reset("adamjtroup")
+	if d := lockout.Fail("adamjtroup"); d != 0 {
+		t.Errorf("expected reset to clear failures, got a %v lock", d)
+	}
+}
@@ -0,0 +1,191 @@
+package rawg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+)
+
+const (
+	endpointSearch       = "search"
+	endpointGame         = "game"
+	endpointAchievements = "achievements"
+	endpointScreenshots  = "screenshots"
+)
+
+// CacheTTL is how long each kind of response is served from the cache. A
+// zero TTL always asks RAWG.
+type CacheTTL struct {
+	Search       time.Duration
+	Game         time.Duration
+	Achievements time.Duration
+	Screenshots  time.Duration
+}
+
+type cacheCounters struct {
+	hits     atomic.Uint64
+	misses   atomic.Uint64
+	bypassed atomic.Uint64
+}
+
+// CachedClient serves RAWG responses from a RAWGCacheStore while they are
+// fresh and asks the wrapped client otherwise. Only successful responses are
+// cached, and a store that fails only costs a request to RAWG.
+type CachedClient struct {
+	client   Client
+	store    models.RAWGCacheStore
+	ttl      CacheTTL
+	now      func() time.Time
+	counters map[string]*cacheCounters
+}
+
+func NewCachedClient(client Client, store models.RAWGCacheStore, ttl CacheTTL) *CachedClient {
+	counters := make(map[string]*cacheCounters)
+	for _, endpoint := range []string{endpointSearch, endpointGame, endpointAchievements, endpointScreenshots} {
+		counters[endpoint] = &cacheCounters{}
+	}
+
+	return &CachedClient{
+		client:   client,
+		store:    store,
+		ttl:      ttl,
+		now:      time.Now,
+		counters: counters,
+	}
+}
+
+type bypassCacheKey struct{}
+
+// WithoutCache returns a context that makes a CachedClient ask RAWG even when
+// it has a fresh response. The new response still replaces the cached one.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassCacheKey{}, true)
+}
+
+func (c *CachedClient) SearchGames(ctx context.Context, query string) ([]models.RAWGGame, error) {
+	// Searches differ only in case and spacing often enough to share an entry,
+	// and hashing keeps arbitrary queries to a fixed length key
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.Join(strings.Fields(query), " "))))
+	key := endpointSearch + ":" + hex.EncodeToString(sum[:])
+
+	return cached(ctx, c, endpointSearch, key, c.ttl.Search, func() ([]models.RAWGGame, error) {
+		return c.client.SearchGames(ctx, query)
+	})
+}
+
+func (c *CachedClient) GetGame(ctx context.Context, id uint) (*models.GameResponse, error) {
+	return cached(ctx, c, endpointGame, gameKey(endpointGame, id), c.ttl.Game, func() (*models.GameResponse, error) {
+		return c.client.GetGame(ctx, id)
+	})
+}
+
+func (c *CachedClient) ListAchievements(ctx context.Context, gameID uint) ([]models.RAWGAchievement, error) {
+	return cached(ctx, c, endpointAchievements, gameKey(endpointAchievements, gameID), c.ttl.Achievements, func() ([]models.RAWGAchievement, error) {
+		return c.client.ListAchievements(ctx, gameID)
+	})
+}
+
+func (c *CachedClient) ListScreenshots(ctx context.Context, gameID uint) ([]models.RAWGScreenshot, error) {
+	return cached(ctx, c, endpointScreenshots, gameKey(endpointScreenshots, gameID), c.ttl.Screenshots, func() ([]models.RAWGScreenshot, error) {
+		return c.client.ListScreenshots(ctx, gameID)
+	})
+}
+
+// Invalidate drops everything cached about a game, searches aside
+func (c *CachedClient) Invalidate(gameID uint) error {
+	return c.store.Delete(gameKey(endpointGame, gameID), gameKey(endpointAchievements, gameID), gameKey(endpointScreenshots, gameID))
+}
+
+// Clear drops every cached response
+func (c *CachedClient) Clear() error {
+	return c.store.Clear()
+}
+
+func (c *CachedClient) Stats() []models.RAWGCacheStats {
+	ttls := []struct {
+		endpoint string
+		ttl      time.Duration
+	}{
+		{endpointSearch, c.ttl.Search},
+		{endpointGame, c.ttl.Game},
+		{endpointAchievements, c.ttl.Achievements},
+		{endpointScreenshots, c.ttl.Screenshots},
+	}
+
+	stats := make([]models.RAWGCacheStats, 0, len(ttls))
+	for _, t := range ttls {
+		counters := c.counters[t.endpoint]
+		s := models.RAWGCacheStats{
+			Endpoint:   t.endpoint,
+			TTLSeconds: int64(t.ttl.Seconds()),
+			Hits:       counters.hits.Load(),
+			Misses:     counters.misses.Load(),
+			Bypassed:   counters.bypassed.Load(),
+		}
+		if total := s.Hits + s.Misses; total > 0 {
+			s.HitRate = float64(s.Hits) / float64(total)
+		}
+		stats = append(stats, s)
+	}
+
+	return stats
+}
+
+func gameKey(endpoint string, id uint) string {
+	return fmt.Sprintf("%s:%d", endpoint, id)
+}
+
+// cached returns the response cached under key, or fetches it and caches it
+// for ttl
+func cached[T any](ctx context.Context, c *CachedClient, endpoint, key string, ttl time.Duration, fetch func() (T, error)) (T, error) {
+	counters := c.counters[endpoint]
+
+	if ttl <= 0 {
+		counters.misses.Add(1)
+		return fetch()
+	}
+
+	if bypass, _ := ctx.Value(bypassCacheKey{}).(bool); bypass {
+		counters.bypassed.Add(1)
+	} else {
+		value, ok, err := c.store.Get(key, c.now())
+		if err != nil {
+			log.Printf("rawg cache: failed to read %s: %v", key, err)
+		}
+		if ok {
+			var v T
+			err := json.Unmarshal(value, &v)
+			if err == nil {
+				counters.hits.Add(1)
+				return v, nil
+			}
+			// Left for the fresh response to overwrite
+			log.Printf("rawg cache: failed to decode %s: %v", key, err)
+		}
+		counters.misses.Add(1)
+	}
+
+	v, err := fetch()
+	if err != nil {
+		return v, err
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("rawg cache: failed to encode %s: %v", key, err)
+		return v, nil
+	}
+	if err := c.store.Set(key, value, c.now().Add(ttl)); err != nil {
+		log.Printf("rawg cache: failed to write %s: %v", key, err)
+	}
+
+	return v, nil
+}
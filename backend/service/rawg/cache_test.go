@@ -0,0 +1,189 @@
+package rawg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/service/rawg/rawgtest"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+)
+
+func TestCachedClient(t *testing.T) {
+	server := rawgtest.NewServer(t)
+	client := NewCachedClient(NewClient(server.ClientConfig()), NewMemoryStore(100), CacheTTL{
+		Search:       time.Minute,
+		Game:         time.Hour,
+		Achievements: time.Hour,
+	})
+	now := time.Date(2024, 8, 23, 12, 0, 0, 0, time.UTC)
+	client.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	stats := func(endpoint string) (hits, misses, bypassed uint64) {
+		for _, s := range client.Stats() {
+			if s.Endpoint == endpoint {
+				return s.Hits, s.Misses, s.Bypassed
+			}
+		}
+		t.Fatalf("no stats for %s", endpoint)
+		return
+	}
+
+	t.Run("should serve a repeated search from the cache", func(t *testing.T) {
+		for _, query := range []string{"witcher", "Witcher", "  witcher "} {
+			games, err := client.SearchGames(ctx, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(games) != 3 || games[0].ID != rawgtest.Witcher3 {
+				t.Errorf("expected the three Witcher games, got %+v", games)
+			}
+		}
+
+		if n := server.Requests("/games"); n != 1 {
+			t.Errorf("expected 1 request to RAWG, got %d", n)
+		}
+		if hits, misses, _ := stats(endpointSearch); hits != 2 || misses != 1 {
+			t.Errorf("expected 2 hits and 1 miss, got %d and %d", hits, misses)
+		}
+	})
+
+	t.Run("should ask RAWG again once a response expires", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		if _, err := client.SearchGames(ctx, "witcher"); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := server.Requests("/games"); n != 2 {
+			t.Errorf("expected 2 requests to RAWG, got %d", n)
+		}
+	})
+
+	t.Run("should bypass the cache when asked and keep the fresh response", func(t *testing.T) {
+		if _, err := client.SearchGames(WithoutCache(ctx), "witcher"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.SearchGames(ctx, "witcher"); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := server.Requests("/games"); n != 3 {
+			t.Errorf("expected 3 requests to RAWG, got %d", n)
+		}
+		if _, _, bypassed := stats(endpointSearch); bypassed != 1 {
+			t.Errorf("expected 1 bypass, got %d", bypassed)
+		}
+	})
+
+	t.Run("should cache every page of achievements as one response", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			achievements, err := client.ListAchievements(ctx, rawgtest.Witcher3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(achievements) != 5 || achievements[4].Percent != "39.77" {
+				t.Errorf("expected both pages of achievements, got %+v", achievements)
+			}
+		}
+
+		if n := server.Requests("/games/3328/achievements"); n != 2 {
+			t.Errorf("expected 2 requests for the 2 pages, got %d", n)
+		}
+	})
+
+	t.Run("should drop a game's responses when it is invalidated", func(t *testing.T) {
+		if _, err := client.GetGame(ctx, rawgtest.Witcher3); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Invalidate(rawgtest.Witcher3); err != nil {
+			t.Fatal(err)
+		}
+		game, err := client.GetGame(ctx, rawgtest.Witcher3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if game.Name != "The Witcher 3: Wild Hunt" || len(game.Platforms) != 3 {
+			t.Errorf("unexpected game %+v", game)
+		}
+		if n := server.Requests("/games/3328"); n != 2 {
+			t.Errorf("expected 2 requests to RAWG, got %d", n)
+		}
+	})
+
+	t.Run("should not cache failures", func(t *testing.T) {
+		server.Fail("/games/9767", http.StatusServiceUnavailable)
+		if _, err := client.GetGame(ctx, rawgtest.HollowKnight); !errors.Is(err, utils.ErrUpstream) {
+			t.Fatalf("expected an upstream error, got %v", err)
+		}
+
+		server.Recover("/games/9767")
+		if _, err := client.GetGame(ctx, rawgtest.HollowKnight); err != nil {
+			t.Errorf("expected the game once RAWG recovered, got %v", err)
+		}
+	})
+
+	t.Run("should not cache endpoints without a TTL", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if _, err := client.ListScreenshots(ctx, rawgtest.Witcher3); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if n := server.Requests("/games/3328/screenshots"); n != 2 {
+			t.Errorf("expected 2 requests to RAWG, got %d", n)
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(2)
+	now := time.Date(2024, 8, 23, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+
+	get := func(key string) string {
+		value, ok, err := store.Get(key, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			return ""
+		}
+		return string(value)
+	}
+
+	t.Run("should evict the least recently used entry", func(t *testing.T) {
+		store.Set("a", []byte("1"), later)
+		store.Set("b", []byte("2"), later)
+		get("a")
+		store.Set("c", []byte("3"), later)
+
+		if get("a") != "1" || get("b") != "" || get("c") != "3" {
+			t.Errorf("expected b to be evicted, got a=%q b=%q c=%q", get("a"), get("b"), get("c"))
+		}
+	})
+
+	t.Run("should not return expired entries", func(t *testing.T) {
+		store.Set("a", []byte("1"), now)
+		if get("a") != "" {
+			t.Error("expected a to have expired")
+		}
+	})
+
+	t.Run("should delete and clear entries", func(t *testing.T) {
+		store.Set("a", []byte("1"), later)
+		store.Delete("a")
+		if get("a") != "" {
+			t.Error("expected a to be deleted")
+		}
+
+		store.Clear()
+		if get("c") != "" {
+			t.Error("expected the store to be empty")
+		}
+	})
+}
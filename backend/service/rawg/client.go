@@ -0,0 +1,197 @@
+package rawg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/config"
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+)
+
+// Client is the part of the RAWG API the tracker uses. Errors are
+// utils.NotFound when RAWG doesn't know the game and utils.Upstream when
+// RAWG can't be reached or answers with something we can't read.
+type Client interface {
+	SearchGames(ctx context.Context, query string) ([]models.RAWGGame, error)
+	GetGame(ctx context.Context, id uint) (*models.GameResponse, error)
+	// ListAchievements and ListScreenshots follow every page of the listing
+	ListAchievements(ctx context.Context, gameID uint) ([]models.RAWGAchievement, error)
+	ListScreenshots(ctx context.Context, gameID uint) ([]models.RAWGScreenshot, error)
+}
+
+const (
+	searchPageSize = 50
+	listPageSize   = 40
+	// Stops a misbehaving upstream from paging us forever
+	maxPages = 50
+)
+
+// HTTPClient talks to the RAWG API at RAWG_BASE_URL with RAWG_KEY
+type HTTPClient struct {
+	baseURL string
+	key     string
+	http    *http.Client
+}
+
+func NewClient(cfg config.Config) *HTTPClient {
+	return &HTTPClient{
+		baseURL: strings.TrimRight(cfg.RAWGBaseURL, "/"),
+		key:     cfg.RAWGKey,
+		http:    &http.Client{Timeout: time.Second * time.Duration(cfg.RAWGTimeoutInSeconds)},
+	}
+}
+
+func (c *HTTPClient) SearchGames(ctx context.Context, query string) ([]models.RAWGGame, error) {
+	params := url.Values{}
+	params.Set("search", query)
+	params.Set("page_size", strconv.Itoa(searchPageSize))
+
+	var res models.RAWGGameResponse
+	if err := c.get(ctx, "/games", params, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Results, nil
+}
+
+func (c *HTTPClient) GetGame(ctx context.Context, id uint) (*models.GameResponse, error) {
+	var game models.GameResponse
+	if err := c.get(ctx, fmt.Sprintf("/games/%d", id), nil, &game); err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil, utils.NotFound("game '%d' not found on RAWG", id)
+		}
+		return nil, err
+	}
+
+	return &game, nil
+}
+
+func (c *HTTPClient) ListAchievements(ctx context.Context, gameID uint) ([]models.RAWGAchievement, error) {
+	var achievements []models.RAWGAchievement
+	err := c.list(ctx, fmt.Sprintf("/games/%d/achievements", gameID), func(body json.RawMessage) error {
+		var page []models.RAWGAchievement
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		achievements = append(achievements, page...)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil, utils.NotFound("game '%d' not found on RAWG", gameID)
+		}
+		return nil, err
+	}
+
+	return achievements, nil
+}
+
+func (c *HTTPClient) ListScreenshots(ctx context.Context, gameID uint) ([]models.RAWGScreenshot, error) {
+	var screenshots []models.RAWGScreenshot
+	err := c.list(ctx, fmt.Sprintf("/games/%d/screenshots", gameID), func(body json.RawMessage) error {
+		var page []models.RAWGScreenshot
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		screenshots = append(screenshots, page...)
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil, utils.NotFound("game '%d' not found on RAWG", gameID)
+		}
+		return nil, err
+	}
+
+	return screenshots, nil
+}
+
+// list calls add with the results of each page of a paginated listing. Pages
+// are requested by number rather than by following "next", which would send
+// our key wherever RAWG told us to.
+func (c *HTTPClient) list(ctx context.Context, path string, add func(results json.RawMessage) error) error {
+	for page := 1; page <= maxPages; page++ {
+		params := url.Values{}
+		params.Set("page", strconv.Itoa(page))
+		params.Set("page_size", strconv.Itoa(listPageSize))
+
+		var res struct {
+			Next    *string         `json:"next"`
+			Results json.RawMessage `json:"results"`
+		}
+		if err := c.get(ctx, path, params, &res); err != nil {
+			return err
+		}
+
+		if len(res.Results) > 0 {
+			if err := add(res.Results); err != nil {
+				return utils.Upstream(err, "failed to parse RAWG response")
+			}
+		}
+
+		if res.Next == nil || *res.Next == "" {
+			return nil
+		}
+	}
+
+	return utils.Upstream(fmt.Errorf("%s has more than %d pages", path, maxPages), "RAWG returned too many pages")
+}
+
+type pageHookKey struct{}
+
+// WithPageHook returns a context that makes the client call hook after each
+// page it fetches with that context, to report the progress of long imports
+func WithPageHook(ctx context.Context, hook func()) context.Context {
+	return context.WithValue(ctx, pageHookKey{}, hook)
+}
+
+// get decodes the JSON response to GET path into v
+func (c *HTTPClient) get(ctx context.Context, path string, params url.Values, v any) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("key", c.key)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to build RAWG request: %w", err)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// The URL in the error carries our key, keep it out of the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return utils.Upstream(err, "failed to reach RAWG")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return utils.NotFound("not found on RAWG")
+	}
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return utils.Upstream(fmt.Errorf("unexpected response status for %s: %v", path, resp.Status), "RAWG responded with %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return utils.Upstream(err, "failed to parse RAWG response")
+	}
+
+	if hook, ok := ctx.Value(pageHookKey{}).(func()); ok {
+		hook()
+	}
+
+	return nil
+}
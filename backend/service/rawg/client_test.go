@@ -0,0 +1,128 @@
+package rawg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/config"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/rawg/rawgtest"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+)
+
+func TestClient(t *testing.T) {
+	server := rawgtest.NewServer(t)
+	client := NewClient(server.ClientConfig())
+	ctx := context.Background()
+
+	t.Run("should search games by name", func(t *testing.T) {
+		games, err := client.SearchGames(ctx, "witcher")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(games) != 3 || games[0].ID != rawgtest.Witcher3 || games[0].BackgroundIMG == "" {
+			t.Errorf("expected the three Witcher games, got %+v", games)
+		}
+	})
+
+	t.Run("should get a game", func(t *testing.T) {
+		game, err := client.GetGame(ctx, rawgtest.Witcher3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if game.Name != "The Witcher 3: Wild Hunt" || len(game.Platforms) != 3 || len(game.Genres) != 2 {
+			t.Errorf("unexpected game %+v", game)
+		}
+	})
+
+	t.Run("should return not found for an unknown game", func(t *testing.T) {
+		_, err := client.GetGame(ctx, 1)
+		if !errors.Is(err, utils.ErrNotFound) {
+			t.Errorf("expected a not found error, got %v", err)
+		}
+
+		_, err = client.ListAchievements(ctx, 1)
+		if !errors.Is(err, utils.ErrNotFound) {
+			t.Errorf("expected a not found error, got %v", err)
+		}
+	})
+
+	t.Run("should follow every page of achievements", func(t *testing.T) {
+		achievements, err := client.ListAchievements(ctx, rawgtest.Witcher3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(achievements) != 5 || achievements[4].Name != "Kaer Morhen Forever" || achievements[4].Percent != "39.77" {
+			t.Errorf("expected both pages of achievements, got %+v", achievements)
+		}
+		if n := server.Requests("/games/3328/achievements"); n != 2 {
+			t.Errorf("expected 2 requests, got %d", n)
+		}
+	})
+
+	t.Run("should list nothing for a game without achievements", func(t *testing.T) {
+		achievements, err := client.ListAchievements(ctx, rawgtest.HollowKnight)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(achievements) != 0 {
+			t.Errorf("expected no achievements, got %+v", achievements)
+		}
+	})
+
+	t.Run("should list screenshots", func(t *testing.T) {
+		screenshots, err := client.ListScreenshots(ctx, rawgtest.Witcher3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(screenshots) != 3 || screenshots[0].Image == "" || screenshots[0].Width != 1920 {
+			t.Errorf("unexpected screenshots %+v", screenshots)
+		}
+	})
+
+	t.Run("should report RAWG failures as upstream errors", func(t *testing.T) {
+		server.Fail("/games/9767", http.StatusServiceUnavailable)
+
+		_, err := client.GetGame(ctx, rawgtest.HollowKnight)
+		if utils.StatusCode(err) != http.StatusBadGateway {
+			t.Errorf("expected an upstream error, got %v", err)
+		}
+	})
+
+	t.Run("should report a rejected key as an upstream error", func(t *testing.T) {
+		cfg := server.ClientConfig()
+		cfg.RAWGKey = "wrong"
+
+		_, err := NewClient(cfg).SearchGames(ctx, "witcher")
+		if utils.StatusCode(err) != http.StatusBadGateway {
+			t.Fatalf("expected an upstream error, got %v", err)
+		}
+		if strings.Contains(err.Error(), "wrong") {
+			t.Errorf("expected the key to stay out of the error, got %v", err)
+		}
+	})
+}
+
+func TestClientTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Second)
+	}))
+	defer slow.Close()
+
+	client := NewClient(config.Config{RAWGBaseURL: slow.URL, RAWGKey: "secret", RAWGTimeoutInSeconds: 1})
+
+	_, err := client.GetGame(context.Background(), rawgtest.Witcher3)
+	if utils.StatusCode(err) != http.StatusBadGateway {
+		t.Fatalf("expected an upstream error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("expected the key to stay out of the error, got %v", err)
+	}
+}
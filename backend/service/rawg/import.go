@@ -0,0 +1,79 @@
+package rawg
+
+import (
+	"context"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/achievement"
+)
+
+// FetchGameImport fetches everything GameStore.ImportGame needs for the game
+// with the RAWG ID id
+func FetchGameImport(ctx context.Context, c Client, id uint) (*models.GameImport, error) {
+	game, err := c.GetGame(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	fetched, err := c.ListAchievements(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// RAWG's IDs aren't ours
+	achievements := make([]models.Achievement, 0, len(fetched))
+	for _, a := range fetched {
+		achievements = append(achievements, models.Achievement{
+			RAWGID:      uint32(a.ID),
+			Name:        a.Name,
+			Description: a.Description,
+			ImgURL:      a.Image,
+			Percent:     achievement.ParsePercent(a.Percent),
+		})
+	}
+	achievement.InferGrades(achievements)
+
+	// Saves a request for the many games without any
+	var screenshots []models.RAWGScreenshot
+	if game.ScreenshotsCount > 0 {
+		screenshots, err = c.ListScreenshots(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	imp := &models.GameImport{
+		Game: models.Game{
+			RAWGID:        uint(game.ID),
+			Name:          game.Name,
+			Slug:          game.Slug,
+			Description:   game.Description,
+			ReleaseDate:   game.Released,
+			BackgroundIMG: game.BackgroundImage,
+			Rating:        uint(game.Metacritic),
+			Website:       game.Website,
+		},
+		Achievements: achievements,
+	}
+
+	for _, platform := range game.Platforms {
+		imp.Platforms = append(imp.Platforms, platform.Platform.Name)
+	}
+	for _, genre := range game.Genres {
+		imp.Genres = append(imp.Genres, genre.Name)
+	}
+
+	for _, screenshot := range screenshots {
+		if screenshot.IsDeleted {
+			continue
+		}
+		imp.Screenshots = append(imp.Screenshots, models.Screenshot{
+			RAWGID: uint32(screenshot.ID),
+			ImgURL: screenshot.Image,
+			Width:  screenshot.Width,
+			Height: screenshot.Height,
+		})
+	}
+
+	return imp, nil
+}
@@ -0,0 +1,206 @@
+// Package rawgtest runs a fake RAWG API for tests, serving responses
+// recorded from the real one
+package rawgtest
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/config"
+	"github.com/gorilla/mux"
+)
+
+// Key is the only API key the server accepts
+const Key = "rawgtest-key"
+
+// The games with fixtures
+const (
+	// The Witcher 3, with two pages of achievements and a page of screenshots
+	Witcher3 = 3328
+	// Hollow Knight, with no achievements or screenshots
+	HollowKnight = 9767
+)
+
+//go:embed fixtures
+var fixtures embed.FS
+
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests map[string]int
+	failures map[string]int
+}
+
+// NewServer starts a fake RAWG server that is closed when the test ends
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		requests: make(map[string]int),
+		failures: make(map[string]int),
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/games", s.handleSearch).Methods("GET")
+	router.HandleFunc("/games/{id:[0-9]+}", s.handleGame).Methods("GET")
+	router.HandleFunc("/games/{id:[0-9]+}/{list:achievements|screenshots}", s.handleList).Methods("GET")
+	router.NotFoundHandler = http.HandlerFunc(notFound)
+
+	s.Server = httptest.NewServer(s.middleware(router))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// ClientConfig is the configuration for a rawg.Client talking to s
+func (s *Server) ClientConfig() config.Config {
+	return config.Config{RAWGBaseURL: s.URL, RAWGKey: Key, RAWGTimeoutInSeconds: 5}
+}
+
+// Fail makes every request to path, such as "/games/3328", respond with status
+func (s *Server) Fail(path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = status
+}
+
+// Recover undoes Fail for path
+func (s *Server) Recover(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, path)
+}
+
+// Requests is the number of requests made to path
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		status, fail := s.failures[r.URL.Path]
+		s.mu.Unlock()
+
+		if r.URL.Query().Get("key") != Key {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "The key parameter is not provided"})
+			return
+		}
+		if fail {
+			writeJSON(w, status, map[string]string{"error": http.StatusText(status)})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleSearch filters the recorded results by name, which is close enough
+// to RAWG's fuzzy search for the fixtures
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var res struct {
+		Count    int               `json:"count"`
+		Next     *string           `json:"next"`
+		Previous *string           `json:"previous"`
+		Results  []json.RawMessage `json:"results"`
+	}
+	if err := readFixture("search.json", &res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	search := strings.ToLower(r.URL.Query().Get("search"))
+	results := make([]json.RawMessage, 0, len(res.Results))
+	for _, result := range res.Results {
+		var game struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(result, &game); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if strings.Contains(strings.ToLower(game.Name), search) {
+			results = append(results, result)
+		}
+	}
+
+	res.Count = len(results)
+	res.Results = results
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleGame(w http.ResponseWriter, r *http.Request) {
+	serveFixture(w, "games/"+mux.Vars(r)["id"]+".json")
+}
+
+// handleList serves the recorded pages of a game's achievements or
+// screenshots, and an empty page for games with nothing recorded
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if _, err := fs.Stat(fixtures, "fixtures/games/"+vars["id"]+".json"); err != nil {
+		notFound(w, r)
+		return
+	}
+
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		var err error
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 1 {
+			notFound(w, r)
+			return
+		}
+	}
+
+	name := "games/" + vars["id"] + "/" + vars["list"] + "_" + strconv.Itoa(page) + ".json"
+	if _, err := fs.Stat(fixtures, "fixtures/"+name); err != nil {
+		if page > 1 {
+			// RAWG's answer to a page past the end
+			writeJSON(w, http.StatusNotFound, map[string]string{"detail": "Invalid page."})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"count": 0, "next": nil, "previous": nil, "results": []any{}})
+		return
+	}
+
+	serveFixture(w, name)
+}
+
+func serveFixture(w http.ResponseWriter, name string) {
+	body, err := fixtures.ReadFile("fixtures/" + name)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"detail": "Not found."})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func readFixture(name string, v any) error {
+	body, err := fixtures.ReadFile("fixtures/" + name)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusNotFound, map[string]string{"detail": "Not found."})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
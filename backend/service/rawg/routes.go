@@ -0,0 +1,51 @@
+package rawg
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+// Handler lets admins watch and empty the RAWG cache
+type Handler struct {
+	cache *CachedClient
+}
+
+func NewHandler(cache *CachedClient) *Handler {
+	return &Handler{cache: cache}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/rawg/cache", utils.MakeHandler(auth.RequireRole(auth.RoleAdmin, h.handleGetStats))).Methods("GET")
+	router.HandleFunc("/rawg/cache", utils.MakeHandler(auth.RequireRole(auth.RoleAdmin, h.handleClear))).Methods("DELETE")
+	router.HandleFunc("/rawg/cache/games/{id:[0-9]+}", utils.MakeHandler(auth.RequireRole(auth.RoleAdmin, h.handleInvalidateGame))).Methods("DELETE")
+}
+
+func (h *Handler) handleGetStats(w http.ResponseWriter, r *http.Request) error {
+	return utils.WriteJSON(w, http.StatusOK, h.cache.Stats())
+}
+
+func (h *Handler) handleClear(w http.ResponseWriter, r *http.Request) error {
+	if err := h.cache.Clear(); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+// handleInvalidateGame drops the cached responses for a game, by its RAWG ID
+func (h *Handler) handleInvalidateGame(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		return utils.Invalid("invalid game id: %v", err)
+	}
+
+	if err := h.cache.Invalidate(uint(id)); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
@@ -0,0 +1,92 @@
+package rawg
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often Set also deletes expired rows, which are otherwise only replaced
+// when the same response is cached again
+const sweepInterval = time.Hour
+
+// Store caches responses in the rawg_cache table, so they survive restarts and
+// are shared by every API instance
+type Store struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Get(key string, now time.Time) ([]byte, bool, error) {
+	var value []byte
+	err := s.db.QueryRow("SELECT value FROM rawg_cache WHERE cache_key = ? AND expires_at > ?", key, now).Scan(&value)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+func (s *Store) Set(key string, value []byte, expiresAt time.Time) error {
+	_, err := s.db.Exec("INSERT INTO rawg_cache (cache_key, value, expires_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE value = VALUES(value), expires_at = VALUES(expires_at)",
+		key, value, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to cache response: %v", err)
+	}
+
+	return s.sweep(time.Now())
+}
+
+func (s *Store) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+	_, err := s.db.Exec("DELETE FROM rawg_cache WHERE cache_key IN ("+placeholders+")", args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete cached responses: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) Clear() error {
+	if _, err := s.db.Exec("DELETE FROM rawg_cache"); err != nil {
+		return fmt.Errorf("failed to clear cached responses: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) sweep(now time.Time) error {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	if _, err := s.db.Exec("DELETE FROM rawg_cache WHERE expires_at <= ?", now); err != nil {
+		return fmt.Errorf("failed to delete expired responses: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package screenshot
+
+import (
+	"database/sql"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetScreenshotsByGame(gameID uint32) ([]*models.Screenshot, error) {
+	rows, err := s.db.Query("SELECT id, game_id, rawg_id, imgurl, width, height FROM screenshots WHERE game_id = ? ORDER BY id", gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var screenshots []*models.Screenshot
+	for rows.Next() {
+		var screenshot models.Screenshot
+		if err := scanScreenshot(rows, &screenshot); err != nil {
+			return nil, err
+		}
+		screenshots = append(screenshots, &screenshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return screenshots, nil
+}
+
+func scanScreenshot(scanner interface {
+	Scan(dest ...interface{}) error
+}, screenshot *models.Screenshot) error {
+	var rawgID sql.NullInt64
+
+	err := scanner.Scan(&screenshot.ID, &screenshot.GameID, &rawgID, &screenshot.ImgURL, &screenshot.Width, &screenshot.Height)
+	if err != nil {
+		return err
+	}
+
+	screenshot.RAWGID = uint32(rawgID.Int64)
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/config"
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	store     models.SessionStore
+	userStore models.UserStore
+}
+
+func NewHandler(store models.SessionStore, userStore models.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/token/refresh", utils.MakeHandler(h.handleRefresh)).Methods("POST")
+	router.HandleFunc("/logout", utils.MakeHandler(h.handleLogout)).Methods("POST")
+	router.HandleFunc("/logout-all", utils.MakeHandler(h.handleLogoutAll)).Methods("POST")
+	router.HandleFunc("/users/{id:[0-9]+}/sessions", utils.MakeHandler(h.handleGetSessions)).Methods("GET")
+}
+
+// StartSession opens a new session for a user who just proved their identity
+// and returns the access and refresh tokens for it
+func StartSession(store models.SessionStore, userID uint32, r *http.Request) (*models.TokenResponse, error) {
+	refreshToken, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	userAgent := r.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	sessionID, err := store.CreateSession(models.Session{
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        utils.ClientIP(r),
+		ExpiresAt: refreshExpiry(),
+	}, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenResponse{Token: token, RefreshToken: refreshToken}, nil
+}
+
+func (h *Handler) handleRefresh(w http.ResponseWriter, r *http.Request) error {
+	var payload models.RefreshTokenPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
+	}
+
+	oldHash := auth.HashToken(payload.RefreshToken)
+	s, err := h.store.GetSessionByTokenHash(oldHash)
+	if err != nil && !errors.Is(err, utils.ErrNotFound) {
+		return err
+	}
+	if err != nil || !s.IsActive(time.Now()) {
+		return utils.Unauthorized("invalid or expired refresh token")
+	}
+
+	refreshToken, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	err = h.store.RotateSession(s.ID, oldHash, hash, refreshExpiry())
+	if err != nil {
+		return err
+	}
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), s.UserID, s.ID)
+	if err != nil {
+		return err
+	}
+
+	err = h.userStore.UpdateLastLogin(s.UserID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, models.TokenResponse{Token: token, RefreshToken: refreshToken})
+}
+
+func (h *Handler) handleLogout(w http.ResponseWriter, r *http.Request) error {
+	if _, err := auth.ActingUserID(r, 0); err != nil {
+		return err
+	}
+
+	err := h.store.RevokeSession(auth.GetSessionIDFromContext(r.Context()))
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+func (h *Handler) handleLogoutAll(w http.ResponseWriter, r *http.Request) error {
+	userID, err := auth.ActingUserID(r, 0)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.RevokeAllSessions(userID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+func (h *Handler) handleGetSessions(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return utils.Invalid("invalid user id: %v", err)
+	}
+
+	// Sessions are only visible to their owner
+	userID, err := auth.ActingUserID(r, uint32(id))
+	if err != nil {
+		return err
+	}
+
+	sessions, err := h.store.GetActiveSessionsByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	current := auth.GetSessionIDFromContext(r.Context())
+	for _, s := range sessions {
+		s.Current = s.ID == current
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, sessions)
+}
+
+func refreshExpiry() time.Time {
+	return time.Now().Add(time.Second * time.Duration(config.Envs.RefreshExpirationInSeconds))
+}
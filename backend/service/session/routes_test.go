@@ -0,0 +1,207 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestSessions(t *testing.T) {
+	sessionStore := newMockSessionStore()
+	handler := NewHandler(sessionStore, &mockUserStore{})
+
+	refreshToken, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionStore.sessions[1] = &models.Session{ID: 1, UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+	sessionStore.hashes[hash] = 1
+	sessionStore.current[1] = hash
+
+	t.Run("should rotate the refresh token", func(t *testing.T) {
+		marshal, _ := json.Marshal(models.RefreshTokenPayload{RefreshToken: refreshToken})
+
+		req, err := http.NewRequest(http.MethodPost, "/token/refresh", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/token/refresh", utils.MakeHandler(handler.handleRefresh))
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var tokens models.TokenResponse
+		if err := json.NewDecoder(rr.Body).Decode(&tokens); err != nil {
+			t.Fatal(err)
+		}
+		if tokens.Token == "" || tokens.RefreshToken == "" || tokens.RefreshToken == refreshToken {
+			t.Errorf("expected a new token pair, got %+v", tokens)
+		}
+	})
+
+	t.Run("should not accept a refresh token twice", func(t *testing.T) {
+		marshal, _ := json.Marshal(models.RefreshTokenPayload{RefreshToken: refreshToken})
+
+		req, err := http.NewRequest(http.MethodPost, "/token/refresh", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/token/refresh", utils.MakeHandler(handler.handleRefresh))
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d. Response body: %s", http.StatusUnauthorized, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should only let one of two concurrent refreshes with the same token through", func(t *testing.T) {
+		raceToken, raceHash, err := auth.NewRefreshToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessionStore.sessions[2] = &models.Session{ID: 2, UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+		sessionStore.hashes[raceHash] = 2
+		sessionStore.current[2] = raceHash
+		sessionStore.stale = true
+		defer func() { sessionStore.stale = false }()
+
+		router := mux.NewRouter()
+		router.HandleFunc("/token/refresh", utils.MakeHandler(handler.handleRefresh))
+
+		refresh := func() int {
+			marshal, _ := json.Marshal(models.RefreshTokenPayload{RefreshToken: raceToken})
+			req, err := http.NewRequest(http.MethodPost, "/token/refresh", bytes.NewBuffer(marshal))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr.Code
+		}
+
+		if code := refresh(); code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, code)
+		}
+		if code := refresh(); code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, code)
+		}
+	})
+
+	t.Run("should not list another user's sessions", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/users/2/sessions", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, uint32(1)))
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/users/{id:[0-9]+}/sessions", utils.MakeHandler(handler.handleGetSessions))
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d. Response body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should revoke the current session on logout", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/logout", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.WithValue(req.Context(), auth.UserKey, uint32(1))
+		ctx = context.WithValue(ctx, auth.SessionKey, uint32(1))
+		req = req.WithContext(ctx)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/logout", utils.MakeHandler(handler.handleLogout))
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if sessionStore.sessions[1].IsActive(time.Now()) {
+			t.Error("expected session to be revoked")
+		}
+	})
+}
+
+type mockSessionStore struct {
+	models.SessionStore
+	sessions map[uint32]*models.Session
+	hashes   map[string]uint32
+	current  map[uint32]string // Each session's refresh token hash
+	stale    bool
+}
+
+func newMockSessionStore() *mockSessionStore {
+	return &mockSessionStore{
+		sessions: make(map[uint32]*models.Session),
+		hashes:   make(map[string]uint32),
+		current:  make(map[uint32]string),
+	}
+}
+
+func (s *mockSessionStore) GetSessionByTokenHash(tokenHash string) (*models.Session, error) {
+	id, ok := s.hashes[tokenHash]
+	if !ok {
+		return nil, utils.NotFound("session not found")
+	}
+	return s.sessions[id], nil
+}
+
+// RotateSession keeps old hashes findable when stale, as they are to a
+// concurrent refresh that looked the session up before this rotation
+func (s *mockSessionStore) RotateSession(id uint32, oldTokenHash, tokenHash string, expiresAt time.Time) error {
+	if s.current[id] != oldTokenHash {
+		return utils.Unauthorized("invalid or expired refresh token")
+	}
+	if !s.stale {
+		delete(s.hashes, oldTokenHash)
+	}
+	s.hashes[tokenHash] = id
+	s.current[id] = tokenHash
+	s.sessions[id].ExpiresAt = expiresAt
+	return nil
+}
+
+func (s *mockSessionStore) RevokeSession(id uint32) error {
+	now := time.Now()
+	s.sessions[id].RevokedAt = &now
+	return nil
+}
+
+type mockUserStore struct {
+	models.UserStore
+}
+
+func (s *mockUserStore) UpdateLastLogin(id uint32) error {
+	return nil
+}
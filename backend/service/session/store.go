@@ -0,0 +1,148 @@
+package session
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateSession(session models.Session, tokenHash string) (uint32, error) {
+	result, err := s.db.Exec("INSERT INTO sessions (user_id, token_hash, user_agent, ip, expires_at) VALUES (?, ?, ?, ?, ?)",
+		session.UserID, tokenHash, session.UserAgent, session.IP, session.ExpiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(lastID), nil
+}
+
+func (s *Store) GetSessionByID(id uint32) (*models.Session, error) {
+	row := s.db.QueryRow("SELECT id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at FROM sessions WHERE id = ?", id)
+
+	var session models.Session
+	err := scanSession(row, &session)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("session not found with id '%d'", id)
+		}
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (s *Store) GetSessionByTokenHash(tokenHash string) (*models.Session, error) {
+	row := s.db.QueryRow("SELECT id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at FROM sessions WHERE token_hash = ?", tokenHash)
+
+	var session models.Session
+	err := scanSession(row, &session)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("session not found")
+		}
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (s *Store) GetActiveSessionsByUserID(userID uint32) ([]*models.Session, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at
+		FROM sessions
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY last_used_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		var session models.Session
+		err := scanSession(rows, &session)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RotateSession replaces the refresh token of a session, so a refresh token can only be used once.
+// Matching the old hash makes concurrent refreshes with the same token race for a single rotation.
+func (s *Store) RotateSession(id uint32, oldTokenHash, tokenHash string, expiresAt time.Time) error {
+	result, err := s.db.Exec("UPDATE sessions SET token_hash = ?, expires_at = ?, last_used_at = CURRENT_TIMESTAMP WHERE id = ? AND token_hash = ? AND revoked_at IS NULL",
+		tokenHash, expiresAt, id, oldTokenHash)
+	if err != nil {
+		return fmt.Errorf("failed to rotate session: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return utils.Unauthorized("invalid or expired refresh token")
+	}
+
+	return nil
+}
+
+func (s *Store) RevokeSession(id uint32) error {
+	_, err := s.db.Exec("UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL", id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) RevokeAllSessions(userID uint32) error {
+	_, err := s.db.Exec("UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL", userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return nil
+}
+
+func scanSession(scanner interface {
+	Scan(dest ...interface{}) error
+}, session *models.Session) error {
+	var userAgent, ip sql.NullString
+	var revokedAt sql.NullTime
+
+	err := scanner.Scan(&session.ID, &session.UserID, &userAgent, &ip, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &revokedAt)
+	if err != nil {
+		return err
+	}
+
+	session.UserAgent = userAgent.String
+	session.IP = ip.String
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+
+	return nil
+}
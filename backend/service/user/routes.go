@@ -1,127 +1,209 @@
 package user
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ajtroup1/platinum-trophy-tracker/models"
 	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/ratelimit"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/session"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/verification"
 	"github.com/ajtroup1/platinum-trophy-tracker/utils"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
 type Handler struct {
-	store models.UserStore
+	store        models.UserStore
+	sessionStore models.SessionStore
+	tokenStore   models.UserTokenStore
+	twoFactor    models.TwoFactorStore
+	mailer       models.Mailer
+	lockout      *ratelimit.Lockout
 }
 
-func NewHandler(store models.UserStore) *Handler {
-	return &Handler{store: store}
+func NewHandler(store models.UserStore, sessionStore models.SessionStore, tokenStore models.UserTokenStore, twoFactor models.TwoFactorStore, mailer models.Mailer, lockout *ratelimit.Lockout) *Handler {
+	return &Handler{store: store, sessionStore: sessionStore, tokenStore: tokenStore, twoFactor: twoFactor, mailer: mailer, lockout: lockout}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/users", h.handleGetUsers).Methods("GET")
-	router.HandleFunc("/users/{id:[0-9]+}", h.handleGetUserByID).Methods("GET")
-	router.HandleFunc("/login", h.handleLogin).Methods("POST")
-	router.HandleFunc("/register", h.handleRegister).Methods("POST")
-	router.HandleFunc("/edit-user", h.handleEdit).Methods("PUT")
-	router.HandleFunc("/change-password", h.handleChangePassword).Methods("PUT")
+	router.HandleFunc("/users", utils.MakeHandler(auth.RequireRole(auth.RoleAdmin, h.handleGetUsers))).Methods("GET")
+	router.HandleFunc("/users/{id:[0-9]+}", utils.MakeHandler(h.handleGetUserByID)).Methods("GET")
+	router.HandleFunc("/login", utils.MakeHandler(h.handleLogin)).Methods("POST")
+	router.HandleFunc("/login/2fa", utils.MakeHandler(h.handleTwoFactorLogin)).Methods("POST")
+	router.HandleFunc("/register", utils.MakeHandler(h.handleRegister)).Methods("POST")
+	router.HandleFunc("/edit-user", utils.MakeHandler(h.handleEdit)).Methods("PUT")
+	router.HandleFunc("/change-password", utils.MakeHandler(h.handleChangePassword)).Methods("PUT")
+	router.HandleFunc("/users/{id:[0-9]+}/deactivate", utils.MakeHandler(auth.RequireRole(auth.RoleAdmin, h.handleDeactivateUser))).Methods("POST")
+	router.HandleFunc("/users/{id:[0-9]+}/reactivate", utils.MakeHandler(auth.RequireRole(auth.RoleAdmin, h.handleReactivateUser))).Methods("POST")
+	router.HandleFunc("/users/{id:[0-9]+}/export", utils.MakeHandler(h.handleExportUser)).Methods("GET")
+	router.HandleFunc("/deactivate-account", utils.MakeHandler(h.handleDeactivateAccount)).Methods("DELETE")
+	router.HandleFunc("/reactivate-account", utils.MakeHandler(h.handleReactivateAccount)).Methods("POST")
+	router.HandleFunc("/delete-account", utils.MakeHandler(h.handleDeleteAccount)).Methods("DELETE")
 }
 
-func (h *Handler) handleGetUsers(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleGetUsers(w http.ResponseWriter, r *http.Request) error {
 	us, err := h.store.GetAllUsers()
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		return err
 	}
 
-	utils.WriteJSON(w, http.StatusOK, us)
-}
+	views := make([]models.AdminUser, 0, len(us))
+	for _, u := range us {
+		views = append(views, toAdminUser(u))
+	}
 
-func (h *Handler) handleGetUserByID(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
+	return utils.WriteJSON(w, http.StatusOK, views)
+}
 
-	// Convert the ID from string to integer
-	id, err := strconv.Atoi(idStr)
+func (h *Handler) handleGetUserByID(w http.ResponseWriter, r *http.Request) error {
+	id, err := userIDFromPath(r)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid user id: %v", err))
-		return
+		return err
 	}
+
 	u, err := h.store.GetUserByID(id)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
+		return err
+	}
+
+	// Deactivated profiles are hidden from everyone but admins
+	if u.Deactivated && !auth.GetRoleFromContext(r.Context()).Includes(auth.RoleAdmin) {
+		return utils.NotFound("user not found with id '%d'", id)
+	}
+
+	u.Trophies, err = h.store.GetTrophyScore(u.ID)
+	if err != nil {
+		return fmt.Errorf("error computing trophy score: %w", err)
 	}
 
-	utils.WriteJSON(w, http.StatusOK, u)
+	return utils.WriteJSON(w, http.StatusOK, viewFor(r, u))
 }
 
-func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) error {
 	var user models.LoginUserPayload
 	if err := utils.ParseJSON(r, &user); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
-		return
+		return err
 	}
 
 	if err := utils.Validate.Struct(user); err != nil {
-		errors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
-		return
+		return utils.ValidationError(err)
+	}
+
+	u, err := h.checkCredentials(w, user)
+	if err != nil {
+		return err
+	}
+
+	if u.Deactivated {
+		return utils.Forbidden("account is deactivated, reactivate it to log in")
+	}
+
+	return h.completeLogin(w, r, u, false)
+}
+
+// handleTwoFactorLogin is the second step of logging in for users with
+// two-factor authentication. It trades the challenge from /login and a TOTP
+// or recovery code for a session.
+func (h *Handler) handleTwoFactorLogin(w http.ResponseWriter, r *http.Request) error {
+	var payload models.TwoFactorLoginPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
+	}
+
+	errInvalidChallenge := utils.Unauthorized("invalid or expired challenge")
+
+	// Challenges from /reactivate-account reactivate the account once the code is accepted
+	purpose := auth.PurposeTwoFactor
+	hash, err := auth.VerifySignedToken(purpose, payload.Challenge)
+	if err != nil {
+		purpose = auth.PurposeReactivate
+		hash, err = auth.VerifySignedToken(purpose, payload.Challenge)
+		if err != nil {
+			return errInvalidChallenge
+		}
+	}
+
+	// The challenge stays usable until a code is accepted so a typo doesn't mean logging in again
+	userID, err := h.tokenStore.FindToken(purpose, hash)
+	if err != nil {
+		return errInvalidChallenge
+	}
+
+	key := fmt.Sprintf("2fa|%d", userID)
+	if ok, retryAfter := h.lockout.Check(key); !ok {
+		return ratelimit.TooManyRequests(w, retryAfter)
+	}
+
+	tf, err := h.twoFactor.GetTwoFactor(userID)
+	if err != nil {
+		return err
 	}
 
-	u, err := h.store.GetUserByUsernameOrEmail(user.Username)
+	ok, err := auth.VerifySecondFactor(h.twoFactor, tf, payload.Code, time.Now())
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
-		return
+		return err
+	}
+	if !ok {
+		h.lockout.Fail(key)
+		return utils.Unauthorized("invalid code")
 	}
+	h.lockout.Reset(key)
 
-	if !auth.ComparePasswords(u.Password, []byte(user.Password)) {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid password"))
-		return
+	if _, err := h.tokenStore.ConsumeToken(purpose, hash); err != nil {
+		return errInvalidChallenge
 	}
 
-	// Uncomment and use the appropriate JWT secret from your config
-	// secret := []byte(config.Envs.JWTSecret)
-	// secret := []byte("your_jwt_secret")
-	// token, err := auth.CreateJWT(secret, u.ID)
+	u, err := h.store.GetUserByID(int(userID))
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
-		return
+		return err
+	}
+
+	if purpose == auth.PurposeReactivate {
+		// An admin may have deactivated the account since the challenge was issued
+		if err := h.reactivate(u); err != nil {
+			return err
+		}
+	} else if u.Deactivated {
+		return utils.Forbidden("account is deactivated, reactivate it to log in")
 	}
 
-	utils.WriteJSON(w, http.StatusOK, map[string]string{"token": ""})
+	return h.startSession(w, r, u)
 }
 
-func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) error {
 	var payload models.RegisterUserPayload
 	if err := utils.ParseJSON(r, &payload); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
-		return
+		return err
 	}
 
-	// fmt.Printf("Received payload: %+v\n", payload)
-
 	if err := utils.Validate.Struct(payload); err != nil {
-		errors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
-		return
+		return utils.ValidationError(err)
 	}
 
 	// Check if user exists
 	_, err := h.store.GetUserByUsername(payload.Username)
 	if err == nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("user with username %s already exists", payload.Username))
-		return
+		return utils.Conflict("user with username %s already exists", payload.Username)
+	}
+	if !errors.Is(err, utils.ErrNotFound) {
+		return err
 	}
 
 	// Hash password
 	hashedPassword, err := auth.HashPassword(payload.Password)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
-		return
+		return err
 	}
 
 	u := models.User{
@@ -137,31 +219,42 @@ func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
 	// Create user in the database
 	err = h.store.CreateUser(u)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
-		return
+		return err
+	}
+
+	// Read the user back for the generated ID and defaults
+	created, err := h.store.GetUserByUsername(u.Username)
+	if err != nil {
+		return err
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, u)
+	// The user can ask for another verification mail if this one doesn't arrive
+	if err := verification.SendEmailVerification(h.tokenStore, h.mailer, created); err != nil {
+		log.Printf("failed to send verification mail to user %d: %v", created.ID, err)
+	}
+
+	return utils.WriteJSON(w, http.StatusCreated, toSelfUser(created))
 }
 
-func (h *Handler) handleEdit(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleEdit(w http.ResponseWriter, r *http.Request) error {
 	var payload models.EditUserPayload
 	if err := utils.ParseJSON(r, &payload); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
-		return
+		return err
 	}
 
 	if err := utils.Validate.Struct(payload); err != nil {
-		errors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
-		return
+		return utils.ValidationError(err)
+	}
+
+	userID, err := auth.ActingUserID(r, payload.ID)
+	if err != nil {
+		return err
 	}
 
 	// Check if user exists
-	existingUser, err := h.store.GetUserByID(int(payload.ID))
+	existingUser, err := h.store.GetUserByID(int(userID))
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("user with id %d doesn't exist", payload.ID))
-		return
+		return err
 	}
 
 	// Check if any data has changed
@@ -170,8 +263,13 @@ func (h *Handler) handleEdit(w http.ResponseWriter, r *http.Request) {
 		existingUser.Lastname == payload.Lastname &&
 		existingUser.Email == payload.Email &&
 		existingUser.ImgURL == payload.ImgURL {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("received information is identical to information in database"))
-		return
+		return utils.Invalid("received information is identical to information in database")
+	}
+
+	// A new email address has to be verified again
+	emailChanged := existingUser.Email != payload.Email
+	if emailChanged {
+		existingUser.EmailVerified = false
 	}
 
 	// Update user details
@@ -183,41 +281,320 @@ func (h *Handler) handleEdit(w http.ResponseWriter, r *http.Request) {
 
 	err = h.store.EditUser(*existingUser)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
-		return
+		return err
+	}
+
+	if emailChanged {
+		if err := verification.SendEmailVerification(h.tokenStore, h.mailer, existingUser); err != nil {
+			log.Printf("failed to send verification mail to user %d: %v", existingUser.ID, err)
+		}
 	}
 
-	utils.WriteJSON(w, http.StatusOK, existingUser)
+	return utils.WriteJSON(w, http.StatusOK, toSelfUser(existingUser))
 }
 
-func (h *Handler) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleChangePassword(w http.ResponseWriter, r *http.Request) error {
 	var payload models.ChangePasswordPayload
 	if err := utils.ParseJSON(r, &payload); err != nil {
-		log.Printf("Failed to parse JSON: %v", err)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("failed to parse JSON: %w", err))
-		return
+		return err
 	}
 
 	if err := utils.Validate.Struct(payload); err != nil {
-		errors := err.(validator.ValidationErrors)
-		log.Printf("Invalid payload: %v", errors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
-		return
+		return utils.ValidationError(err)
+	}
+
+	userID, err := auth.ActingUserID(r, uint32(payload.UserID))
+	if err != nil {
+		return err
+	}
+
+	err = h.store.ChangePassword(uint(userID), payload.CurrentPassword, payload.NewPassword, payload.ConfirmNewPassword)
+	if err != nil {
+		return err
+	}
+
+	// A changed password signs the user out everywhere
+	err = h.sessionStore.RevokeAllSessions(userID)
+	if err != nil {
+		return err
 	}
 
-	_, err := h.store.GetUserByID(int(payload.UserID))
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+func (h *Handler) handleDeactivateUser(w http.ResponseWriter, r *http.Request) error {
+	id, err := userIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	u, err := h.store.GetUserByID(id)
 	if err != nil {
-		log.Printf("User with id %d doesn't exist: %v", payload.UserID, err)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("user with id %d doesn't exist", payload.UserID))
-		return
+		return err
 	}
 
-	err = h.store.ChangePassword(uint(payload.UserID), payload.CurrentPassword, payload.NewPassword, payload.ConfirmNewPassword)
+	err = h.store.DeactivateUser(u.ID, models.DeactivatedByAdmin)
 	if err != nil {
-		log.Printf("User with id %d doesn't exist: %v", payload.UserID, err)
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("error with change password function"))
-		return
+		return err
 	}
 
-	utils.WriteJSON(w, http.StatusOK, nil)
+	// Deactivated users can't stay signed in
+	err = h.sessionStore.RevokeAllSessions(u.ID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+func (h *Handler) handleReactivateUser(w http.ResponseWriter, r *http.Request) error {
+	id, err := userIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	u, err := h.store.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.ReactivateUser(u.ID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+func (h *Handler) handleExportUser(w http.ResponseWriter, r *http.Request) error {
+	id, err := userIDFromPath(r)
+	if err != nil {
+		return err
+	}
+
+	// Admins may export anyone, users only themselves
+	if !auth.GetRoleFromContext(r.Context()).Includes(auth.RoleAdmin) {
+		if _, err := auth.ActingUserID(r, uint32(id)); err != nil {
+			return err
+		}
+	}
+
+	export, err := h.store.ExportUser(uint32(id))
+	if err != nil {
+		return err
+	}
+
+	return writeExport(w, http.StatusOK, export)
+}
+
+func (h *Handler) handleDeactivateAccount(w http.ResponseWriter, r *http.Request) error {
+	u, err := h.confirmPassword(r)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.DeactivateUser(u.ID, models.DeactivatedBySelf)
+	if err != nil {
+		return err
+	}
+
+	err = h.sessionStore.RevokeAllSessions(u.ID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+// handleReactivateAccount brings back a user who deactivated their own
+// account. It takes the same payload as /login, and the account is only
+// reactivated once the user is fully logged in, after /login/2fa for users
+// with two-factor authentication. Accounts deactivated by an admin stay
+// deactivated until an admin reactivates them.
+func (h *Handler) handleReactivateAccount(w http.ResponseWriter, r *http.Request) error {
+	var payload models.LoginUserPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
+	}
+
+	u, err := h.checkCredentials(w, payload)
+	if err != nil {
+		return err
+	}
+
+	if !u.Deactivated {
+		return h.completeLogin(w, r, u, false)
+	}
+
+	if err := checkReactivate(u); err != nil {
+		return err
+	}
+
+	return h.completeLogin(w, r, u, true)
+}
+
+// handleDeleteAccount permanently deletes the user and responds with the
+// export of everything that was deleted
+func (h *Handler) handleDeleteAccount(w http.ResponseWriter, r *http.Request) error {
+	u, err := h.confirmPassword(r)
+	if err != nil {
+		return err
+	}
+
+	export, err := h.store.ExportUser(u.ID)
+	if err != nil {
+		return fmt.Errorf("failed to export user data: %w", err)
+	}
+
+	err = h.store.DeleteUser(u.ID)
+	if err != nil {
+		return err
+	}
+
+	return writeExport(w, http.StatusOK, export)
+}
+
+// checkCredentials looks up the user logging in and checks their password.
+// Repeated failures lock the username out with an increasing delay.
+func (h *Handler) checkCredentials(w http.ResponseWriter, payload models.LoginUserPayload) (*models.User, error) {
+	key := "login|" + strings.ToLower(payload.Username)
+	if ok, retryAfter := h.lockout.Check(key); !ok {
+		return nil, ratelimit.TooManyRequests(w, retryAfter)
+	}
+
+	// Unknown users and wrong passwords look the same so usernames can't be probed
+	errInvalidCredentials := utils.Unauthorized("invalid username or password")
+
+	u, err := h.store.GetUserByUsernameOrEmail(payload.Username)
+	if errors.Is(err, utils.ErrNotFound) {
+		h.lockout.Fail(key)
+		return nil, errInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !auth.ComparePasswords(u.Password, []byte(payload.Password)) {
+		h.lockout.Fail(key)
+		return nil, errInvalidCredentials
+	}
+
+	h.lockout.Reset(key)
+	return u, nil
+}
+
+// completeLogin finishes logging in a user whose password has been checked.
+// Users with two-factor authentication get a challenge for /login/2fa
+// instead of a session. With reactivate, the user's account is reactivated
+// once they're logged in, by /login/2fa when it issues a challenge.
+func (h *Handler) completeLogin(w http.ResponseWriter, r *http.Request, u *models.User, reactivate bool) error {
+	tf, err := h.twoFactor.GetTwoFactor(u.ID)
+	if err != nil {
+		return err
+	}
+
+	if tf.Enabled() {
+		purpose := auth.PurposeTwoFactor
+		if reactivate {
+			purpose = auth.PurposeReactivate
+		}
+
+		challenge, err := auth.NewTwoFactorChallenge(h.tokenStore, u.ID, purpose, time.Now())
+		if err != nil {
+			return err
+		}
+
+		return utils.WriteJSON(w, http.StatusOK, models.TwoFactorChallenge{TwoFactorRequired: true, Challenge: challenge})
+	}
+
+	if reactivate {
+		if err := h.reactivate(u); err != nil {
+			return err
+		}
+	}
+
+	return h.startSession(w, r, u)
+}
+
+// reactivate reactivates a deactivated user who is logging in, unless an
+// admin deactivated them
+func (h *Handler) reactivate(u *models.User) error {
+	if !u.Deactivated {
+		return nil
+	}
+
+	if err := checkReactivate(u); err != nil {
+		return err
+	}
+
+	return h.store.ReactivateUser(u.ID)
+}
+
+// checkReactivate refuses to let users reactivate accounts they didn't
+// deactivate themselves
+func checkReactivate(u *models.User) error {
+	if u.DeactivatedBy != models.DeactivatedBySelf {
+		return utils.Forbidden("account was deactivated by an admin, contact an admin to reactivate it")
+	}
+	return nil
+}
+
+func (h *Handler) startSession(w http.ResponseWriter, r *http.Request, u *models.User) error {
+	tokens, err := session.StartSession(h.sessionStore, u.ID, r)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.UpdateLastLogin(u.ID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, tokens)
+}
+
+// confirmPassword reads a models.ConfirmPasswordPayload and checks it against
+// the acting user's password
+func (h *Handler) confirmPassword(r *http.Request) (*models.User, error) {
+	var payload models.ConfirmPasswordPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return nil, err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return nil, utils.ValidationError(err)
+	}
+
+	userID, err := auth.ActingUserID(r, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := h.store.GetUserByID(int(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	if !auth.ComparePasswords(u.Password, []byte(payload.ConfirmPassword)) {
+		return nil, utils.Forbidden("invalid password")
+	}
+
+	return u, nil
+}
+
+func userIDFromPath(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, utils.Invalid("invalid user id: %v", err)
+	}
+	return id, nil
+}
+
+func writeExport(w http.ResponseWriter, status int, export *models.UserExport) error {
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-export.json"`, export.User.ID))
+	return utils.WriteJSON(w, status, export)
 }
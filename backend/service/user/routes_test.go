@@ -2,20 +2,25 @@ package user
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/ratelimit"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
 	"github.com/gorilla/mux"
 )
 
 func TestUser(t *testing.T) {
 	userStore := &mockUserStore{}
-	handler := NewHandler(userStore)
+	handler := NewHandler(userStore, &mockSessionStore{}, &mockTokenStore{}, &mockTwoFactorStore{}, &mockMailer{}, newLockout())
 
 	t.Run("should fail if payload is invalid", func(t *testing.T) {
 		payload := models.RegisterUserPayload{
@@ -37,7 +42,7 @@ func TestUser(t *testing.T) {
 		rr := httptest.NewRecorder()
 		router := mux.NewRouter()
 
-		router.HandleFunc("/register", handler.handleRegister)
+		router.HandleFunc("/register", utils.MakeHandler(handler.handleRegister))
 
 		router.ServeHTTP(rr, req)
 
@@ -66,7 +71,7 @@ func TestUser(t *testing.T) {
 		rr := httptest.NewRecorder()
 		router := mux.NewRouter()
 
-		router.HandleFunc("/register", handler.handleRegister)
+		router.HandleFunc("/register", utils.MakeHandler(handler.handleRegister))
 
 		router.ServeHTTP(rr, req)
 
@@ -90,11 +95,12 @@ func TestUser(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req = withUser(req, 1)
 
 		rr := httptest.NewRecorder()
 		router := mux.NewRouter()
 
-		router.HandleFunc("/edit-user", handler.handleEdit)
+		router.HandleFunc("/edit-user", utils.MakeHandler(handler.handleEdit))
 
 		router.ServeHTTP(rr, req)
 
@@ -116,11 +122,12 @@ func TestUser(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req = withUser(req, 1)
 
 		rr := httptest.NewRecorder()
 		router := mux.NewRouter()
 
-		router.HandleFunc("/change-password", handler.handleChangePassword)
+		router.HandleFunc("/change-password", utils.MakeHandler(handler.handleChangePassword))
 
 		router.ServeHTTP(rr, req)
 
@@ -128,23 +135,653 @@ func TestUser(t *testing.T) {
 			t.Errorf("failed with status code %d, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
 		}
 	})
+
+	t.Run("should not change another user's password", func(t *testing.T) {
+		payload := models.ChangePasswordPayload{
+			UserID:             2,
+			CurrentPassword:    "Sample123!",
+			NewPassword:        "Sample1234!",
+			ConfirmNewPassword: "Sample1234!",
+		}
+		marshal, _ := json.Marshal(payload)
+
+		req, err := http.NewRequest(http.MethodPut, "/change-password", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = withUser(req, 1)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/change-password", utils.MakeHandler(handler.handleChangePassword))
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+		}
+	})
+}
+
+func TestUserResponses(t *testing.T) {
+	userStore := &mockUserStore{}
+	handler := NewHandler(userStore, &mockSessionStore{}, &mockTokenStore{}, &mockTwoFactorStore{}, &mockMailer{}, newLockout())
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users", utils.MakeHandler(handler.handleGetUsers))
+	router.HandleFunc("/users/{id:[0-9]+}", utils.MakeHandler(handler.handleGetUserByID))
+	router.HandleFunc("/register", utils.MakeHandler(handler.handleRegister))
+	router.HandleFunc("/edit-user", utils.MakeHandler(handler.handleEdit))
+
+	t.Run("should not show a hash or email on a public profile", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		assertNoPasswordHash(t, rr.Body.String())
+		if strings.Contains(rr.Body.String(), "adamjtroup@gmail.com") {
+			t.Errorf("expected public profile to hide the email. Response body: %s", rr.Body.String())
+		}
+
+		var profile models.PublicUser
+		if err := json.Unmarshal(rr.Body.Bytes(), &profile); err != nil {
+			t.Fatal(err)
+		}
+		if profile.Trophies == nil || profile.Trophies.Points != 330 || profile.Trophies.Level != 6 {
+			t.Errorf("expected the profile to include the trophy score, got %+v", profile.Trophies)
+		}
+	})
+
+	t.Run("should show the email but no hash on the user's own profile", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = withUser(req, 1)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assertNoPasswordHash(t, rr.Body.String())
+		if !strings.Contains(rr.Body.String(), "adamjtroup@gmail.com") {
+			t.Errorf("expected own profile to include the email. Response body: %s", rr.Body.String())
+		}
+	})
+
+	t.Run("should show another user's profile publicly", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/users/2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = withUser(req, 1)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assertNoPasswordHash(t, rr.Body.String())
+		if strings.Contains(rr.Body.String(), "sample@mail.com") {
+			t.Errorf("expected another user's profile to hide the email. Response body: %s", rr.Body.String())
+		}
+	})
+
+	t.Run("should not show hashes to admins", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/users", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = withRole(withUser(req, 1), auth.RoleAdmin)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		assertNoPasswordHash(t, rr.Body.String())
+		if !strings.Contains(rr.Body.String(), `"deactivated"`) {
+			t.Errorf("expected the admin view. Response body: %s", rr.Body.String())
+		}
+	})
+
+	t.Run("should mail a verification link after registering", func(t *testing.T) {
+		mailer := &mockMailer{}
+		handler := NewHandler(userStore, &mockSessionStore{}, &mockTokenStore{}, &mockTwoFactorStore{}, mailer, newLockout())
+
+		payload := models.RegisterUserPayload{
+			Username:  "verifyme",
+			Password:  "Sample123!",
+			Firstname: "Verify",
+			Lastname:  "Me",
+			Email:     "verifyme@mail.com",
+		}
+		marshal, _ := json.Marshal(payload)
+
+		req, err := http.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.handleRegister(rr, req)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, received %d. Response body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+		if len(mailer.sent) != 1 || mailer.sent[0].To != "verifyme@mail.com" || !strings.Contains(mailer.sent[0].Body, "verify-email?token=") {
+			t.Errorf("expected a verification mail, got %+v", mailer.sent)
+		}
+	})
+
+	t.Run("should not show the hash after registering", func(t *testing.T) {
+		payload := models.RegisterUserPayload{
+			Username:  "newhunter",
+			Password:  "Sample123!",
+			Firstname: "New",
+			Lastname:  "Hunter",
+			Email:     "newhunter@mail.com",
+		}
+		marshal, _ := json.Marshal(payload)
+
+		req, err := http.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, received %d. Response body: %s", http.StatusCreated, rr.Code, rr.Body.String())
+		}
+		assertNoPasswordHash(t, rr.Body.String())
+	})
+
+	t.Run("should not show the hash after editing", func(t *testing.T) {
+		payload := models.EditUserPayload{
+			Username:  "adamjtroup3",
+			Firstname: "Adam",
+			Lastname:  "Troup",
+			Email:     "adamjtroup@gmail.com",
+		}
+		marshal, _ := json.Marshal(payload)
+
+		req, err := http.NewRequest(http.MethodPut, "/edit-user", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = withUser(req, 1)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		assertNoPasswordHash(t, rr.Body.String())
+	})
+}
+
+func TestAccountLifecycle(t *testing.T) {
+	userStore := &mockUserStore{}
+	handler := NewHandler(userStore, &mockSessionStore{}, &mockTokenStore{}, &mockTwoFactorStore{}, &mockMailer{}, newLockout())
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{id:[0-9]+}", utils.MakeHandler(handler.handleGetUserByID))
+	router.HandleFunc("/login", utils.MakeHandler(handler.handleLogin))
+	router.HandleFunc("/deactivate-account", utils.MakeHandler(handler.handleDeactivateAccount))
+	router.HandleFunc("/reactivate-account", utils.MakeHandler(handler.handleReactivateAccount))
+	router.HandleFunc("/delete-account", utils.MakeHandler(handler.handleDeleteAccount))
+
+	t.Run("should log in an active user", func(t *testing.T) {
+		marshal, _ := json.Marshal(models.LoginUserPayload{Username: "adamjtroup", Password: "Sample123!"})
+
+		req, err := http.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should not log in a deactivated user", func(t *testing.T) {
+		marshal, _ := json.Marshal(models.LoginUserPayload{Username: "leaver", Password: "Sample123!"})
+
+		req, err := http.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should reactivate and log in a deactivated user", func(t *testing.T) {
+		marshal, _ := json.Marshal(models.LoginUserPayload{Username: "leaver", Password: "Sample123!"})
+
+		req, err := http.NewRequest(http.MethodPost, "/reactivate-account", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if len(userStore.reactivated) != 1 || userStore.reactivated[0] != 4 {
+			t.Errorf("expected user 4 to be reactivated, got %v", userStore.reactivated)
+		}
+	})
+
+	t.Run("should not reactivate a user an admin deactivated", func(t *testing.T) {
+		userStore.reactivated = nil
+		marshal, _ := json.Marshal(models.LoginUserPayload{Username: "banned", Password: "Sample123!"})
+
+		req, err := http.NewRequest(http.MethodPost, "/reactivate-account", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+		}
+		if len(userStore.reactivated) != 0 {
+			t.Errorf("expected nobody to be reactivated, got %v", userStore.reactivated)
+		}
+	})
+
+	t.Run("should lock out a username after repeated failures", func(t *testing.T) {
+		for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+			marshal, _ := json.Marshal(models.LoginUserPayload{Username: "friend", Password: "Wrong123!"})
+
+			req, err := http.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(marshal))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != want {
+				t.Fatalf("attempt %d: expected status code %d, received %d. Response body: %s", i+1, want, rr.Code, rr.Body.String())
+			}
+			if want == http.StatusTooManyRequests && rr.Header().Get("Retry-After") == "" {
+				t.Error("expected a Retry-After header")
+			}
+		}
+
+		// The right password doesn't get through while locked either
+		marshal, _ := json.Marshal(models.LoginUserPayload{Username: "friend", Password: "Sample123!"})
+
+		req, err := http.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusTooManyRequests {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusTooManyRequests, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should hide a deactivated profile", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/users/4", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusNotFound, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should not deactivate with the wrong password", func(t *testing.T) {
+		marshal, _ := json.Marshal(models.ConfirmPasswordPayload{ConfirmPassword: "wrong"})
+
+		req, err := http.NewRequest(http.MethodDelete, "/deactivate-account", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = withUser(req, 1)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should respond with the export when deleting", func(t *testing.T) {
+		marshal, _ := json.Marshal(models.ConfirmPasswordPayload{ConfirmPassword: "Sample123!"})
+
+		req, err := http.NewRequest(http.MethodDelete, "/delete-account", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = withUser(req, 1)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		assertNoPasswordHash(t, rr.Body.String())
+
+		var export models.UserExport
+		if err := json.NewDecoder(rr.Body).Decode(&export); err != nil {
+			t.Fatal(err)
+		}
+		if export.User.ID != 1 || len(export.Games) != 1 {
+			t.Errorf("expected the export of user 1, got %+v", export)
+		}
+	})
+}
+
+func assertNoPasswordHash(t *testing.T, body string) {
+	t.Helper()
+
+	if strings.Contains(body, "$2a$") || strings.Contains(strings.ToLower(body), `"password"`) {
+		t.Errorf("expected no password hash in response body: %s", body)
+	}
+}
+
+func newLockout() *ratelimit.Lockout {
+	return ratelimit.NewLockout(ratelimit.NewMemoryStore(), 3, time.Minute, time.Hour)
+}
+
+func TestErrorResponses(t *testing.T) {
+	userStore := &mockUserStore{}
+	handler := NewHandler(userStore, &mockSessionStore{}, &mockTokenStore{}, &mockTwoFactorStore{}, &mockMailer{}, newLockout())
+
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	do := func(method, path string, payload any) (int, utils.ErrorResponse) {
+		marshal, _ := json.Marshal(payload)
+
+		req, err := http.NewRequest(method, path, bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		var res utils.ErrorResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatalf("expected a JSON body: %v", err)
+		}
+		return rr.Code, res
+	}
+
+	register := models.RegisterUserPayload{
+		Username:  "newbie",
+		Password:  "Sample123!",
+		Firstname: "New",
+		Lastname:  "User",
+		Email:     "newbie@mail.com",
+	}
+
+	t.Run("should list invalid fields by their JSON names", func(t *testing.T) {
+		invalid := register
+		invalid.Username = ""
+		invalid.Email = "not-an-email"
+
+		status, res := do(http.MethodPost, "/register", invalid)
+		if status != http.StatusBadRequest || res.Code != "validation_failed" {
+			t.Fatalf("expected a 400 validation_failed, got %d %+v", status, res)
+		}
+
+		rules := make(map[string]string)
+		for _, d := range res.Details {
+			rules[d.Field] = d.Rule
+		}
+		if rules["username"] != "required" || rules["email"] != "email" || len(rules) != 2 {
+			t.Errorf("expected username and email details, got %+v", res.Details)
+		}
+	})
+
+	t.Run("should reject malformed JSON", func(t *testing.T) {
+		status, res := do(http.MethodPost, "/login", "not an object")
+		if status != http.StatusBadRequest || res.Code != "validation_failed" {
+			t.Errorf("expected a 400 validation_failed, got %d %+v", status, res)
+		}
+	})
+
+	t.Run("should return a 409 for a taken username", func(t *testing.T) {
+		if status, _ := do(http.MethodPost, "/register", register); status != http.StatusCreated {
+			t.Fatalf("expected the first registration to succeed, got %d", status)
+		}
+
+		status, res := do(http.MethodPost, "/register", register)
+		if status != http.StatusConflict || res.Code != "conflict" {
+			t.Errorf("expected a 409 conflict, got %d %+v", status, res)
+		}
+	})
+
+	t.Run("should return a 404 for an unknown user", func(t *testing.T) {
+		status, res := do(http.MethodGet, "/users/999", nil)
+		if status != http.StatusNotFound || res.Code != "not_found" {
+			t.Errorf("expected a 404 not_found, got %d %+v", status, res)
+		}
+	})
+
+	t.Run("should hide the cause of a server error", func(t *testing.T) {
+		status, res := do(http.MethodGet, "/users/500", nil)
+		if status != http.StatusInternalServerError || res.Code != "internal_error" {
+			t.Fatalf("expected a 500 internal_error, got %d %+v", status, res)
+		}
+		if strings.Contains(res.Error, "3306") {
+			t.Errorf("expected the database error to stay out of the response, got %q", res.Error)
+		}
+	})
+
+	t.Run("should return a 401 for anonymous mutations", func(t *testing.T) {
+		status, res := do(http.MethodPut, "/edit-user", models.EditUserPayload{Username: "adamjtroup", Firstname: "Adam", Lastname: "Troup", Email: "adamjtroup@gmail.com"})
+		if status != http.StatusUnauthorized || res.Code != "unauthorized" {
+			t.Errorf("expected a 401 unauthorized, got %d %+v", status, res)
+		}
+	})
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	userStore := &mockUserStore{}
+	handler := NewHandler(userStore, &mockSessionStore{}, &mockTokenStore{}, &mockTwoFactorStore{}, &mockMailer{}, newLockout())
+
+	router := mux.NewRouter()
+	router.HandleFunc("/login", utils.MakeHandler(handler.handleLogin))
+	router.HandleFunc("/login/2fa", utils.MakeHandler(handler.handleTwoFactorLogin))
+	router.HandleFunc("/reactivate-account", utils.MakeHandler(handler.handleReactivateAccount))
+
+	post := func(path string, payload any) *httptest.ResponseRecorder {
+		marshal, _ := json.Marshal(payload)
+
+		req, err := http.NewRequest(http.MethodPost, path, bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	challengeFrom := func(t *testing.T, path, username string) string {
+		t.Helper()
+
+		rr := post(path, models.LoginUserPayload{Username: username, Password: "Sample123!"})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var res models.TwoFactorChallenge
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if !res.TwoFactorRequired || res.Challenge == "" {
+			t.Fatalf("expected a two-factor challenge, got %+v", res)
+		}
+		return res.Challenge
+	}
+	challenge := func(t *testing.T) string {
+		t.Helper()
+		return challengeFrom(t, "/login", "enemy")
+	}
+
+	t.Run("should not start a session before the second factor", func(t *testing.T) {
+		rr := post("/login", models.LoginUserPayload{Username: "enemy", Password: "Sample123!"})
+
+		if strings.Contains(rr.Body.String(), "refreshToken") {
+			t.Errorf("expected no tokens before the second factor: %s", rr.Body.String())
+		}
+	})
+
+	t.Run("should reject a wrong code", func(t *testing.T) {
+		rr := post("/login/2fa", models.TwoFactorLoginPayload{Challenge: challenge(t), Code: "000000"})
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusUnauthorized, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should log in with a TOTP code once", func(t *testing.T) {
+		code, err := auth.TOTPCode(twoFactorSecret, auth.TOTPStep(time.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := challenge(t)
+		rr := post("/login/2fa", models.TwoFactorLoginPayload{Challenge: c, Code: code})
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "refreshToken") {
+			t.Fatalf("expected status code %d with tokens, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		// Neither the challenge nor the code can be replayed
+		rr = post("/login/2fa", models.TwoFactorLoginPayload{Challenge: c, Code: code})
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusUnauthorized, rr.Code, rr.Body.String())
+		}
+
+		rr = post("/login/2fa", models.TwoFactorLoginPayload{Challenge: challenge(t), Code: code})
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusUnauthorized, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should log in with a recovery code once", func(t *testing.T) {
+		rr := post("/login/2fa", models.TwoFactorLoginPayload{Challenge: challenge(t), Code: "ABCD EFGH"})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		rr = post("/login/2fa", models.TwoFactorLoginPayload{Challenge: challenge(t), Code: twoFactorRecoveryCode})
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, received %d. Response body: %s", http.StatusUnauthorized, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should only reactivate after the second factor", func(t *testing.T) {
+		c := challengeFrom(t, "/reactivate-account", "hermit")
+		if len(userStore.reactivated) != 0 {
+			t.Fatalf("expected nobody to be reactivated before the second factor, got %v", userStore.reactivated)
+		}
+
+		rr := post("/login/2fa", models.TwoFactorLoginPayload{Challenge: c, Code: "000000"})
+		if rr.Code != http.StatusUnauthorized || len(userStore.reactivated) != 0 {
+			t.Fatalf("expected a wrong code to leave the account deactivated, received %d and %v", rr.Code, userStore.reactivated)
+		}
+
+		// The step after the one used above, still within the allowed skew
+		code, err := auth.TOTPCode(twoFactorSecret, auth.TOTPStep(time.Now())+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr = post("/login/2fa", models.TwoFactorLoginPayload{Challenge: c, Code: code})
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "refreshToken") {
+			t.Fatalf("expected status code %d with tokens, received %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if len(userStore.reactivated) != 1 || userStore.reactivated[0] != 6 {
+			t.Errorf("expected user 6 to be reactivated, got %v", userStore.reactivated)
+		}
+	})
 }
 
-type mockUserStore struct{}
+// withRole sets the authenticated user's role, the way the auth middleware would
+func withRole(req *http.Request, role auth.Role) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), auth.RoleKey, role))
+}
+
+// withUser authenticates the request as the given user, the way the auth middleware would
+func withUser(req *http.Request, userID uint32) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), auth.UserKey, userID))
+}
+
+type mockUserStore struct {
+	created     []models.User
+	reactivated []uint32
+}
 
 func (s *mockUserStore) GetAllUsers() ([]*models.User, error) {
-	return nil, fmt.Errorf("users not found")
+	var users []*models.User
+	for id := 1; id <= 3; id++ {
+		u, _ := s.GetUserByID(id)
+		users = append(users, u)
+	}
+	return users, nil
 }
 
 func (s *mockUserStore) GetUserByUsername(username string) (*models.User, error) {
-	return nil, fmt.Errorf("user not found")
+	for i := range s.created {
+		if s.created[i].Username == username {
+			return &s.created[i], nil
+		}
+	}
+	return nil, utils.NotFound("user not found")
 }
 
 func (s *mockUserStore) GetUserByUsernameOrEmail(username string) (*models.User, error) {
-	return nil, fmt.Errorf("user not found")
+	for id := 1; id <= 6; id++ {
+		u, _ := s.GetUserByID(id)
+		if u.Username == username || u.Email == username {
+			return u, nil
+		}
+	}
+	return nil, utils.NotFound("user not found")
 }
 
 func (s *mockUserStore) GetUserByID(id int) (*models.User, error) {
+	if id == 500 {
+		return nil, fmt.Errorf("dial tcp 127.0.0.1:3306: connection refused")
+	}
 	if id == 1 {
 		return &models.User{
 			ID:        1,
@@ -205,11 +842,46 @@ func (s *mockUserStore) GetUserByID(id int) (*models.User, error) {
 				time.UTC,  // location
 			),
 		}, nil
+	} else if id == 4 {
+		return &models.User{
+			ID:            4,
+			Username:      "leaver",
+			Password:      "$2a$10$luQ7PyQR0KQeliaN15Y55uMFFPzdwDW8VhjEPvIWfJUizTN4IGps2",
+			Firstname:     "Sample",
+			Lastname:      "Name",
+			Email:         "leaver@mail.com",
+			Deactivated:   true,
+			DeactivatedBy: models.DeactivatedBySelf,
+		}, nil
+	} else if id == 5 {
+		return &models.User{
+			ID:            5,
+			Username:      "banned",
+			Password:      "$2a$10$luQ7PyQR0KQeliaN15Y55uMFFPzdwDW8VhjEPvIWfJUizTN4IGps2",
+			Firstname:     "Sample",
+			Lastname:      "Name",
+			Email:         "banned@mail.com",
+			Deactivated:   true,
+			DeactivatedBy: models.DeactivatedByAdmin,
+		}, nil
+	} else if id == 6 {
+		return &models.User{
+			ID:            6,
+			Username:      "hermit",
+			Password:      "$2a$10$luQ7PyQR0KQeliaN15Y55uMFFPzdwDW8VhjEPvIWfJUizTN4IGps2",
+			Firstname:     "Sample",
+			Lastname:      "Name",
+			Email:         "hermit@mail.com",
+			Deactivated:   true,
+			DeactivatedBy: models.DeactivatedBySelf,
+		}, nil
 	}
-	return nil, fmt.Errorf("user not found")
+	return nil, utils.NotFound("user not found")
 }
 
 func (s *mockUserStore) CreateUser(user models.User) error {
+	user.ID = uint32(100 + len(s.created))
+	s.created = append(s.created, user)
 	return nil
 }
 
@@ -221,6 +893,140 @@ func (s *mockUserStore) ChangePassword(id uint, currentPassword, newPassword, co
 	return nil
 }
 
+func (s *mockUserStore) UpdateLastLogin(id uint32) error {
+	return nil
+}
+
+func (s *mockUserStore) SetUserRole(id uint32, role string) error {
+	return nil
+}
+
+func (s *mockUserStore) DeactivateUser(id uint32, by string) error {
+	return nil
+}
+
+func (s *mockUserStore) ReactivateUser(id uint32) error {
+	s.reactivated = append(s.reactivated, id)
+	return nil
+}
+
+func (s *mockUserStore) ExportUser(id uint32) (*models.UserExport, error) {
+	u, err := s.GetUserByID(int(id))
+	if err != nil {
+		return nil, err
+	}
+	return &models.UserExport{
+		User: toSelfUser(u),
+		Games: []*models.UserGame{
+			{ID: 1, UserID: u.ID, GameID: 1},
+		},
+	}, nil
+}
+
+func (s *mockUserStore) DeleteUser(id uint32) error {
+	return nil
+}
+
+func (s *mockUserStore) SetPassword(id uint32, hashedPassword string) error {
+	return nil
+}
+
+func (s *mockUserStore) SetEmailVerified(id uint32) error {
+	return nil
+}
+
+func (s *mockUserStore) GetTrophyScore(id uint32) (*models.TrophyScore, error) {
+	return &models.TrophyScore{Points: 330, Level: 6, Progress: 50, Bronze: 2, Platinum: 1}, nil
+}
+
+type mockTokenStore struct {
+	tokens map[string]uint32
+}
+
+func (s *mockTokenStore) CreateToken(userID uint32, purpose, tokenHash string, expiresAt time.Time) error {
+	if s.tokens == nil {
+		s.tokens = make(map[string]uint32)
+	}
+	s.tokens[purpose+tokenHash] = userID
+	return nil
+}
+
+func (s *mockTokenStore) ConsumeToken(purpose, tokenHash string) (uint32, error) {
+	userID, err := s.FindToken(purpose, tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	delete(s.tokens, purpose+tokenHash)
+	return userID, nil
+}
+
+func (s *mockTokenStore) FindToken(purpose, tokenHash string) (uint32, error) {
+	userID, ok := s.tokens[purpose+tokenHash]
+	if !ok {
+		return 0, utils.Invalid("invalid or expired token")
+	}
+	return userID, nil
+}
+
+// mockTwoFactorStore has two-factor authentication enabled for "enemy" (ID 3)
+// and "hermit" (ID 6)
+type mockTwoFactorStore struct {
+	models.TwoFactorStore
+	lastUsedStep int64
+	usedCodes    map[string]bool
+}
+
+const twoFactorSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+const twoFactorRecoveryCode = "abcd-efgh"
+
+func (s *mockTwoFactorStore) GetTwoFactor(userID uint32) (*models.TwoFactor, error) {
+	if userID != 3 && userID != 6 {
+		return nil, nil
+	}
+	confirmedAt := time.Date(2024, time.August, 1, 0, 0, 0, 0, time.UTC)
+	return &models.TwoFactor{UserID: userID, Secret: twoFactorSecret, ConfirmedAt: &confirmedAt, LastUsedStep: s.lastUsedStep}, nil
+}
+
+func (s *mockTwoFactorStore) SetLastUsedStep(userID uint32, step int64) (bool, error) {
+	if step <= s.lastUsedStep {
+		return false, nil
+	}
+	s.lastUsedStep = step
+	return true, nil
+}
+
+func (s *mockTwoFactorStore) UseRecoveryCode(userID uint32, codeHash string) (bool, error) {
+	if s.usedCodes == nil {
+		s.usedCodes = make(map[string]bool)
+	}
+	if codeHash != auth.HashRecoveryCode(twoFactorRecoveryCode) || s.usedCodes[codeHash] {
+		return false, nil
+	}
+	s.usedCodes[codeHash] = true
+	return true, nil
+}
+
+type mockMailer struct {
+	sent []models.Mail
+}
+
+func (m *mockMailer) Send(mail models.Mail) error {
+	m.sent = append(m.sent, mail)
+	return nil
+}
+
+type mockSessionStore struct {
+	models.SessionStore
+}
+
+func (s *mockSessionStore) CreateSession(session models.Session, tokenHash string) (uint32, error) {
+	return 1, nil
+}
+
+func (s *mockSessionStore) RevokeAllSessions(userID uint32) error {
+	return nil
+}
+
 // Correct usage of time.Date
 func ParseTime(timestamp string) (time.Time, error) {
 	layout := "2006-01-02 15:04:05"
@@ -4,9 +4,12 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/achievement"
 	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
 )
 
 type Store struct {
@@ -20,23 +23,22 @@ func NewStore(db *sql.DB) *Store {
 }
 
 func (s *Store) GetAllUsers() ([]*models.User, error) {
-	rows, err := s.db.Query("SELECT id, username, password, firstname, lastname, email, imgurl, created_at FROM users")
+	rows, err := s.db.Query("SELECT * FROM users")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var users []*models.User
+	users := []*models.User{}
 	for rows.Next() {
-		var u models.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.Password, &u.Firstname, &u.Lastname, &u.Email, &u.ImgURL, &u.CreatedAt); err != nil {
+		u, err := scanRowsIntoUser(rows)
+		if err != nil {
 			return nil, err
 		}
-		users = append(users, &u)
+		users = append(users, u)
 	}
-
-	if len(users) == 0 {
-		return nil, fmt.Errorf("users not found")
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
 	return users, nil
@@ -47,6 +49,7 @@ func (s *Store) GetUserByUsername(username string) (*models.User, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	u := new(models.User)
 	for rows.Next() {
@@ -57,7 +60,7 @@ func (s *Store) GetUserByUsername(username string) (*models.User, error) {
 	}
 
 	if u.ID == 0 {
-		return nil, fmt.Errorf("user not found")
+		return nil, utils.NotFound("user not found")
 	}
 
 	return u, nil
@@ -93,7 +96,7 @@ func (s *Store) GetUserByUsernameOrEmail(val string) (*models.User, error) {
 	}
 
 	if !found {
-		return nil, fmt.Errorf("user not found")
+		return nil, utils.NotFound("user not found")
 	}
 
 	return user, nil
@@ -104,6 +107,7 @@ func (s *Store) GetUserByID(id int) (*models.User, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	u := new(models.User)
 	for rows.Next() {
@@ -114,7 +118,7 @@ func (s *Store) GetUserByID(id int) (*models.User, error) {
 	}
 
 	if u.ID == 0 {
-		return nil, fmt.Errorf("user not found with id '%d'", id)
+		return nil, utils.NotFound("user not found with id '%d'", id)
 	}
 
 	return u, nil
@@ -127,6 +131,9 @@ func (s *Store) CreateUser(user models.User) error {
 	_, err := s.db.Exec("INSERT INTO users (username, password, firstname, lastname, email, imgurl, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
 		user.Username, user.Password, firstname, lastname, user.Email, user.ImgURL, user.CreatedAt)
 	if err != nil {
+		if utils.IsDuplicateEntry(err) {
+			return utils.Conflict("username or email is already taken")
+		}
 		return err
 	}
 
@@ -137,9 +144,12 @@ func (s *Store) EditUser(user models.User) error {
 	firstname := capitalizeFirstLetter(user.Firstname)
 	lastname := capitalizeFirstLetter(user.Lastname)
 
-	_, err := s.db.Exec("UPDATE users SET username = ?, firstname = ?, lastname = ?, email = ?, imgLink = ? WHERE id = ?",
-		user.Username, firstname, lastname, user.Email, user.ImgURL, user.ID)
+	_, err := s.db.Exec("UPDATE users SET username = ?, firstname = ?, lastname = ?, email = ?, email_verified = ?, imgurl = ? WHERE id = ?",
+		user.Username, firstname, lastname, user.Email, user.EmailVerified, user.ImgURL, user.ID)
 	if err != nil {
+		if utils.IsDuplicateEntry(err) {
+			return utils.Conflict("username or email is already taken")
+		}
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
@@ -147,39 +157,42 @@ func (s *Store) EditUser(user models.User) error {
 }
 
 func (s *Store) ChangePassword(id uint, currentPassword, newPassword, confirmNewPassword string) error {
-	if newPassword != confirmNewPassword {
-		return fmt.Errorf("new password did not match confirm new password")
-	}
-
 	// Retrieve the user
 	row := s.db.QueryRow("SELECT password FROM users WHERE id = ?", id)
 	var storedPassword string
 	err := row.Scan(&storedPassword)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("user not found with id '%d'", id)
+			return utils.NotFound("user not found with id '%d'", id)
 		}
 		return err
 	}
 
 	// Check the current password
 	if !auth.ComparePasswords(storedPassword, []byte(currentPassword)) {
-		return fmt.Errorf("current password did not match")
+		return utils.Forbidden("current password did not match")
 	}
 
-	// Check if new password is the same as the current one
-	if auth.ComparePasswords(storedPassword, []byte(newPassword)) {
-		return fmt.Errorf("new password cannot be the same as current password")
+	hashedPassword, err := auth.HashNewPassword(newPassword, confirmNewPassword, storedPassword)
+	if err != nil {
+		return err
 	}
 
-	// Hash the new password
-	hashedPassword, err := auth.HashPassword(newPassword)
+	return s.SetPassword(uint32(id), hashedPassword)
+}
+
+func (s *Store) SetEmailVerified(id uint32) error {
+	_, err := s.db.Exec("UPDATE users SET email_verified = TRUE WHERE id = ?", id)
 	if err != nil {
-		return fmt.Errorf("error hashing new password: %v", err)
+		return fmt.Errorf("failed to verify email: %w", err)
 	}
 
-	// Update the password in the database
-	_, err = s.db.Exec("UPDATE users SET password = ? WHERE id = ?", hashedPassword, id)
+	return nil
+}
+
+// SetPassword stores an already hashed password
+func (s *Store) SetPassword(id uint32, hashedPassword string) error {
+	_, err := s.db.Exec("UPDATE users SET password = ? WHERE id = ?", hashedPassword, id)
 	if err != nil {
 		return err
 	}
@@ -187,6 +200,134 @@ func (s *Store) ChangePassword(id uint, currentPassword, newPassword, confirmNew
 	return nil
 }
 
+func (s *Store) UpdateLastLogin(id uint32) error {
+	_, err := s.db.Exec("UPDATE users SET last_login = CURRENT_TIMESTAMP WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to update last login: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) SetUserRole(id uint32, role string) error {
+	_, err := s.db.Exec("UPDATE users SET role = ? WHERE id = ?", role, id)
+	if err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) DeactivateUser(id uint32, by string) error {
+	_, err := s.db.Exec("UPDATE users SET deactivated = TRUE, deactivated_by = ? WHERE id = ?", by, id)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate user: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) ReactivateUser(id uint32) error {
+	_, err := s.db.Exec("UPDATE users SET deactivated = FALSE, deactivated_by = NULL WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to reactivate user: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) ExportUser(id uint32) (*models.UserExport, error) {
+	u, err := s.GetUserByID(int(id))
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.UserExport{
+		ExportedAt: time.Now(),
+		User:       toSelfUser(u),
+	}
+
+	accountRows, err := s.db.Query("SELECT id, user_id, username, platform_id FROM accounts WHERE user_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer accountRows.Close()
+
+	for accountRows.Next() {
+		var a models.UserPlatformAccount
+		if err := accountRows.Scan(&a.ID, &a.UserID, &a.Username, &a.PlatformID); err != nil {
+			return nil, err
+		}
+		export.Accounts = append(export.Accounts, &a)
+	}
+	if err := accountRows.Err(); err != nil {
+		return nil, err
+	}
+
+	gameRows, err := s.db.Query("SELECT id, user_id, game_id, tracked_at, completed_at, updated_at FROM user_games WHERE user_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer gameRows.Close()
+
+	for gameRows.Next() {
+		var g models.UserGame
+		var completedAt sql.NullTime
+		if err := gameRows.Scan(&g.ID, &g.UserID, &g.GameID, &g.TrackedAt, &completedAt, &g.UpdatedAt); err != nil {
+			return nil, err
+		}
+		g.CompletedAt = completedAt.Time
+		export.Games = append(export.Games, &g)
+	}
+	if err := gameRows.Err(); err != nil {
+		return nil, err
+	}
+
+	achRows, err := s.db.Query("SELECT id, completed, user_id, game_id, achievement_id, completed_at, created_at FROM user_achievements WHERE user_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer achRows.Close()
+
+	for achRows.Next() {
+		var a models.UserAchievement
+		var completedAt sql.NullTime
+		if err := achRows.Scan(&a.ID, &a.Completed, &a.UserID, &a.GameID, &a.AchievementID, &completedAt, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.CompletedAt = completedAt.Time
+		export.Achievements = append(export.Achievements, &a)
+	}
+	if err := achRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// DeleteUser permanently removes a user and every row that belongs to them
+func (s *Store) DeleteUser(id uint32) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"user_achievements", "user_games", "accounts", "sessions", "user_tokens", "recovery_codes", "two_factor", "jobs"} {
+		_, err = tx.Exec("DELETE FROM "+table+" WHERE user_id = ?", id)
+		if err != nil {
+			return fmt.Errorf("failed to delete from %s: %v", table, err)
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %v", err)
+	}
+
+	return tx.Commit()
+}
+
 func capitalizeFirstLetter(s string) string {
 	if len(s) == 0 {
 		return s
@@ -194,13 +335,44 @@ func capitalizeFirstLetter(s string) string {
 	return strings.ToUpper(s[:1]) + s[1:]
 }
 
+func (s *Store) GetTrophyScore(id uint32) (*models.TrophyScore, error) {
+	rows, err := s.db.Query(`
+		SELECT a.grade, COUNT(*)
+		FROM user_achievements ua
+		JOIN achievements a ON a.id = ua.achievement_id
+		WHERE ua.user_id = ? AND ua.completed = TRUE
+		GROUP BY a.grade`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	earned := make(map[string]int)
+	for rows.Next() {
+		var grade string
+		var n int
+		if err := rows.Scan(&grade, &n); err != nil {
+			return nil, err
+		}
+		earned[grade] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	score := achievement.Score(earned)
+	return &score, nil
+}
+
 func scanRowsIntoUser(rows *sql.Rows) (*models.User, error) {
 	user := new(models.User)
 
-	err := rows.Scan(&user.ID, &user.Username, &user.Password, &user.Firstname, &user.Lastname, &user.Email, &user.ImgURL, &user.CreatedAt, &user.UpdatedAt, &user.TrackedGames, &user.CompletedGames, &user.LastLogin, &user.Deactivated)
+	var deactivatedBy sql.NullString
+	err := rows.Scan(&user.ID, &user.Username, &user.Password, &user.Firstname, &user.Lastname, &user.Email, &user.ImgURL, &user.CreatedAt, &user.UpdatedAt, &user.TrackedGames, &user.CompletedGames, &user.LastLogin, &user.Deactivated, &user.Role, &user.EmailVerified, &deactivatedBy)
 	if err != nil {
 		return nil, err
 	}
+	user.DeactivatedBy = deactivatedBy.String
 
 	return user, nil
 }
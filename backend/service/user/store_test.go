@@ -0,0 +1,97 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+)
+
+func TestStore(t *testing.T) {
+	created := time.Date(2024, 8, 3, 16, 38, 0, 0, time.UTC)
+	lastLogin := time.Date(2024, 8, 29, 18, 30, 0, 0, time.UTC)
+
+	db := sql.OpenDB(&fakeConnector{rows: [][]driver.Value{
+		{int64(1), "adamjtroup", "hash", "Adam", "Troup", "adam@example.com", "", created, created, int64(12), int64(7), lastLogin, false, "admin", true, nil},
+		{int64(2), "gooseberries", "hash", "Goose", "Berry", "goose@example.com", "", created, lastLogin, int64(3), int64(1), lastLogin, true, "user", true, "admin"},
+	}})
+	defer db.Close()
+
+	store := NewStore(db)
+
+	t.Run("should list every user in full", func(t *testing.T) {
+		users, err := store.GetAllUsers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 2 {
+			t.Fatalf("expected 2 users, got %d", len(users))
+		}
+
+		u := users[1]
+		if !u.Deactivated || u.DeactivatedBy != models.DeactivatedByAdmin || !u.EmailVerified {
+			t.Errorf("expected a verified user deactivated by an admin, got %+v", u)
+		}
+		if u.TrackedGames != 3 || u.CompletedGames != 1 || !u.UpdatedAt.Equal(lastLogin) || !u.LastLogin.Equal(lastLogin) {
+			t.Errorf("expected the user's counts and timestamps, got %+v", u)
+		}
+		if users[0].Deactivated || users[0].DeactivatedBy != "" || users[0].Role != "admin" {
+			t.Errorf("expected an active admin, got %+v", users[0])
+		}
+	})
+}
+
+// usersColumns are the columns of the users table, in order
+var usersColumns = []string{"id", "username", "password", "firstname", "lastname", "email", "imgurl", "created_at", "updated_at", "tracked_games", "completed_games", "last_login", "deactivated", "role", "email_verified", "deactivated_by"}
+
+// fakeConnector is a database whose every query returns the same rows of the
+// users table
+type fakeConnector struct {
+	rows [][]driver.Value
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{rows: c.rows}, nil
+}
+func (c *fakeConnector) Driver() driver.Driver { return nil }
+
+type fakeConn struct {
+	rows [][]driver.Value
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{rows: c.rows}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+type fakeStmt struct {
+	rows [][]driver.Value
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{rows: s.rows}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+	next int
+}
+
+func (r *fakeRows) Columns() []string { return usersColumns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next == len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,55 @@
+package user
+
+import (
+	"net/http"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+)
+
+// Handlers never write models.User directly, every response goes through one
+// of these views so password hashes and emails only reach who may see them.
+
+func toPublicUser(u *models.User) models.PublicUser {
+	return models.PublicUser{
+		ID:             u.ID,
+		Username:       u.Username,
+		Firstname:      u.Firstname,
+		Lastname:       u.Lastname,
+		ImgURL:         u.ImgURL,
+		CreatedAt:      u.CreatedAt,
+		TrackedGames:   u.TrackedGames,
+		CompletedGames: u.CompletedGames,
+		Trophies:       u.Trophies,
+	}
+}
+
+func toSelfUser(u *models.User) models.SelfUser {
+	return models.SelfUser{
+		PublicUser:    toPublicUser(u),
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		UpdatedAt:     u.UpdatedAt,
+		LastLogin:     u.LastLogin,
+		Role:          u.Role,
+	}
+}
+
+func toAdminUser(u *models.User) models.AdminUser {
+	return models.AdminUser{
+		SelfUser:      toSelfUser(u),
+		Deactivated:   u.Deactivated,
+		DeactivatedBy: u.DeactivatedBy,
+	}
+}
+
+// viewFor picks the most detailed view of u the requester is allowed to see
+func viewFor(r *http.Request, u *models.User) any {
+	if auth.GetRoleFromContext(r.Context()).Includes(auth.RoleAdmin) {
+		return toAdminUser(u)
+	}
+	if auth.GetUserIDFromContext(r.Context()) == u.ID {
+		return toSelfUser(u)
+	}
+	return toPublicUser(u)
+}
@@ -1,12 +1,16 @@
 package usergame
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/achievement"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
 	"github.com/ajtroup1/platinum-trophy-tracker/utils"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
@@ -14,106 +18,298 @@ type Handler struct {
 	store models.UserGameStore
 	achStore models.AchievementStore
 	gameStore models.GameStore
+	userStore models.UserStore
 }
 
-func NewHandler(store models.UserGameStore, achStore models.AchievementStore, gameStore models.GameStore) *Handler {
-	return &Handler{store: store, achStore: achStore, gameStore: gameStore}
+func NewHandler(store models.UserGameStore, achStore models.AchievementStore, gameStore models.GameStore, userStore models.UserStore) *Handler {
+	return &Handler{store: store, achStore: achStore, gameStore: gameStore, userStore: userStore}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/track-game", h.handleTrackGame).Methods("POST")
-	router.HandleFunc("/untrack-game", h.handleUntrackGame).Methods("POST")
-	router.HandleFunc("/complete-achievement", h.handleCompleteAchievement).Methods("POST")
+	router.HandleFunc("/track-game", utils.MakeHandler(h.handleTrackGame)).Methods("POST")
+	router.HandleFunc("/untrack-game", utils.MakeHandler(h.handleUntrackGame)).Methods("POST")
+	router.HandleFunc("/complete-achievement", utils.MakeHandler(h.handleCompleteAchievement)).Methods("POST")
+	router.HandleFunc("/complete-achievements", utils.MakeHandler(h.handleCompleteAchievements)).Methods("POST")
+	router.HandleFunc("/uncomplete-achievement", utils.MakeHandler(h.handleUncompleteAchievement)).Methods("POST")
+	router.HandleFunc("/users/{id:[0-9]+}/games", utils.MakeHandler(h.handleGetUserGames)).Methods("GET")
+	router.HandleFunc("/users/{id:[0-9]+}/games/{gameID:[0-9]+}", utils.MakeHandler(h.handleGetUserGame)).Methods("GET")
+	router.HandleFunc("/users/{id:[0-9]+}/rarest-unlocks", utils.MakeHandler(h.handleGetRarestUnlocks)).Methods("GET")
 	
 }
 
-func (h *Handler) handleTrackGame(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleTrackGame(w http.ResponseWriter, r *http.Request) error {
 	var payload models.TrackGamePayload
 	if err := utils.ParseJSON(r, &payload); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
-		return
+		return err
 	}
 
 	if err := utils.Validate.Struct(payload); err != nil {
-		errors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
-		return
+		return utils.ValidationError(err)
+	}
+
+	userID, err := auth.ActingUserID(r, payload.UserID)
+	if err != nil {
+		return err
 	}
 
 	// Check if user is already tracking game
-	_, err := h.store.GetUserGameByID(payload.UserID, payload.GameID)
+	_, err = h.store.GetUserGameByID(userID, payload.GameID)
 	if err == nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("user is already tracking game"))
-		return
+		return utils.Conflict("user is already tracking game")
+	}
+	if !errors.Is(err, utils.ErrNotFound) {
+		return err
 	}
 
 	// Track the game
-	err = h.store.TrackGame(payload.UserID, payload.GameID)
+	err = h.store.TrackGame(userID, payload.GameID)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("error executing game track: %v", err))
-		return
+		return fmt.Errorf("error executing game track: %w", err)
 	}
 
 	// Retrieve the achievements for the game
 	achievements, err := h.achStore.GetAllAchievementsByGame(payload.GameID)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("error retrieving game achievements: %v", err))
-		return
+		return fmt.Errorf("error retrieving game achievements: %w", err)
 	}
 
 	// Add user_achievement records for each achievement
 	for _, achievement := range achievements {
-		err = h.gameStore.AddUserAchievement(payload.UserID, uint32(achievement.GameID), achievement.ID)
+		err = h.gameStore.AddUserAchievement(userID, uint32(achievement.GameID), achievement.ID)
 		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("error adding achievement: %v", err))
-			return
+			return fmt.Errorf("error adding achievement: %w", err)
 		}
 	}
 
-	utils.WriteJSON(w, http.StatusOK, nil)
+	return utils.WriteJSON(w, http.StatusOK, nil)
 }
 
 
-func (h *Handler) handleUntrackGame(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleUntrackGame(w http.ResponseWriter, r *http.Request) error {
 	var payload models.TrackGamePayload
 	if err := utils.ParseJSON(r, &payload); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
-		return
+		return err
 	}
 
 	if err := utils.Validate.Struct(payload); err != nil {
-		errors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
-		return
+		return utils.ValidationError(err)
+	}
+
+	userID, err := auth.ActingUserID(r, payload.UserID)
+	if err != nil {
+		return err
 	}
 
-	err := h.store.UntrackGame(payload.UserID, payload.GameID)
+	err = h.store.UntrackGame(userID, payload.GameID)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("error executing game track: %v", err))
-		return
+		return fmt.Errorf("error executing game untrack: %w", err)
 	}
 
-	utils.WriteJSON(w, http.StatusOK, nil)
+	return utils.WriteJSON(w, http.StatusOK, nil)
 }
 
-func (h *Handler) handleCompleteAchievement(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) handleCompleteAchievement(w http.ResponseWriter, r *http.Request) error {
 	var payload models.CompletedUserAchievementPayload
 	if err := utils.ParseJSON(r, &payload); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, err)
-		return
+		return err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
+	}
+
+	userID, err := auth.ActingUserID(r, payload.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := validateCompletedAt(payload.CompletedAt); err != nil {
+		return err
+	}
+
+	err = h.achStore.CompleteAchievements(userID, []uint32{payload.AchievementID}, payload.CompletedAt)
+	if err != nil {
+		return fmt.Errorf("error completing achievement: %w", err)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+// handleCompleteAchievements earns many achievements at once, all or none of them
+func (h *Handler) handleCompleteAchievements(w http.ResponseWriter, r *http.Request) error {
+	var payload models.CompleteAchievementsPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
 	}
 
 	if err := utils.Validate.Struct(payload); err != nil {
-		errors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
-		return
+		return utils.ValidationError(err)
 	}
 
-	err := h.achStore.CompleteAchievement(payload.UserID, payload.AchievementID)
+	userID, err := auth.ActingUserID(r, payload.UserID)
 	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("error completing achievement: %v", err))
-		return
+		return err
 	}
 
-	utils.WriteJSON(w, http.StatusOK, nil)
-}
\ No newline at end of file
+	if err := validateCompletedAt(payload.CompletedAt); err != nil {
+		return err
+	}
+
+	err = h.achStore.CompleteAchievements(userID, payload.AchievementIDs, payload.CompletedAt)
+	if err != nil {
+		return fmt.Errorf("error completing achievements: %w", err)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+func (h *Handler) handleUncompleteAchievement(w http.ResponseWriter, r *http.Request) error {
+	var payload models.UncompleteAchievementPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
+	}
+
+	userID, err := auth.ActingUserID(r, payload.UserID)
+	if err != nil {
+		return err
+	}
+
+	err = h.achStore.UncompleteAchievement(userID, payload.AchievementID)
+	if err != nil {
+		return fmt.Errorf("error uncompleting achievement: %w", err)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+// validateCompletedAt rejects backdating into the future. Whether it is after
+// the game was tracked is up to the store.
+func validateCompletedAt(completedAt *time.Time) error {
+	if completedAt != nil && completedAt.After(time.Now()) {
+		return utils.Invalid("completedAt can't be in the future")
+	}
+	return nil
+}
+
+// handleGetUserGames lists the games a user tracks with how far they are through each
+func (h *Handler) handleGetUserGames(w http.ResponseWriter, r *http.Request) error {
+	userID, err := h.visibleUserID(r)
+	if err != nil {
+		return err
+	}
+
+	progress, err := h.store.GetAllGameProgress(userID)
+	if err != nil {
+		return fmt.Errorf("error receiving game progress: %w", err)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, progress)
+}
+
+// handleGetUserGame returns a user's progress through a game with every achievement
+func (h *Handler) handleGetUserGame(w http.ResponseWriter, r *http.Request) error {
+	userID, err := h.visibleUserID(r)
+	if err != nil {
+		return err
+	}
+
+	gameID, err := strconv.ParseUint(mux.Vars(r)["gameID"], 10, 32)
+	if err != nil {
+		return utils.Invalid("invalid game id: %v", err)
+	}
+
+	progress, err := h.store.GetGameProgress(userID, uint32(gameID))
+	if err != nil {
+		return err
+	}
+
+	achievements, err := h.store.GetAchievementProgress(userID, uint32(gameID))
+	if err != nil {
+		return fmt.Errorf("error receiving achievement progress: %w", err)
+	}
+
+	detail := models.GameProgressDetail{GameProgress: *progress, Grades: gradeProgress(achievements), Achievements: make([]models.AchievementProgress, 0, len(achievements))}
+	for _, a := range achievements {
+		detail.Achievements = append(detail.Achievements, *a)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, detail)
+}
+
+const (
+	defaultRarestUnlocks = 10
+	maxRarestUnlocks     = 50
+)
+
+// handleGetRarestUnlocks lists the rarest achievements a user has earned,
+// across every game. Achievements of unknown rarity aren't listed.
+func (h *Handler) handleGetRarestUnlocks(w http.ResponseWriter, r *http.Request) error {
+	userID, err := h.visibleUserID(r)
+	if err != nil {
+		return err
+	}
+
+	limit := defaultRarestUnlocks
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxRarestUnlocks {
+			return utils.Invalid("limit must be a number from 1 to %d", maxRarestUnlocks)
+		}
+		limit = n
+	}
+
+	unlocks, err := h.store.GetRarestUnlocks(userID, limit)
+	if err != nil {
+		return fmt.Errorf("error receiving rarest unlocks: %w", err)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, unlocks)
+}
+
+// gradeProgress breaks a user's progress through a game down by grade
+func gradeProgress(achievements []*models.AchievementProgress) []models.GradeProgress {
+	grades := make([]models.GradeProgress, len(models.Grades))
+	index := make(map[string]int, len(models.Grades))
+	for i, grade := range models.Grades {
+		grades[i].Grade = grade
+		index[grade] = i
+	}
+
+	for _, a := range achievements {
+		i, ok := index[a.Grade]
+		if !ok {
+			continue
+		}
+		grades[i].Total++
+		if a.Completed {
+			grades[i].Earned++
+			grades[i].Points += achievement.Points(a.Grade)
+		}
+	}
+
+	return grades
+}
+
+// visibleUserID returns the user in the path, as long as the requester may
+// see them. Like profiles, deactivated users' progress is only for admins.
+func (h *Handler) visibleUserID(r *http.Request) (uint32, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, utils.Invalid("invalid user id: %v", err)
+	}
+
+	u, err := h.userStore.GetUserByID(id)
+	if err != nil {
+		return 0, err
+	}
+
+	if u.Deactivated && !auth.GetRoleFromContext(r.Context()).Includes(auth.RoleAdmin) {
+		return 0, utils.NotFound("user not found with id '%d'", id)
+	}
+
+	return u.ID, nil
+}
@@ -0,0 +1,317 @@
+package usergame
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestGetUserGames(t *testing.T) {
+	unlocked := time.Date(2024, 8, 20, 21, 14, 0, 0, time.UTC)
+	common, uncommon, rare := 84.12, 39.77, 1.9
+	store := &mockUserGameStore{
+		progress: map[uint32][]*models.GameProgress{
+			1: {
+				{GameID: 7, Name: "The Witcher 3: Wild Hunt", Earned: 1, Total: 2, Percent: 50, LastUnlockedAt: &unlocked},
+				{GameID: 8, Name: "Hollow Knight", Earned: 0, Total: 0},
+			},
+		},
+		achievements: map[[2]uint32][]*models.AchievementProgress{
+			{1, 7}: {
+				{Achievement: models.Achievement{ID: 1, Name: "Lilac and Gooseberries", Percent: &common, Grade: models.GradeBronze}, Completed: true, CompletedAt: &unlocked},
+				{Achievement: models.Achievement{ID: 2, Name: "Kaer Morhen Forever", Percent: &uncommon, Grade: models.GradeGold}},
+			},
+		},
+		unlocks: map[uint32][]*models.RareUnlock{
+			1: {
+				{Achievement: models.Achievement{ID: 9, Name: "Steel Soul", Percent: &rare, Rarity: models.RarityUltraRare, GameID: 8}, GameName: "Hollow Knight", CompletedAt: unlocked},
+				{Achievement: models.Achievement{ID: 1, Name: "Lilac and Gooseberries", Percent: &common, Rarity: models.RarityCommon, GameID: 7}, GameName: "The Witcher 3: Wild Hunt", CompletedAt: unlocked},
+			},
+		},
+	}
+	users := &mockUserStore{users: map[int]*models.User{
+		1: {ID: 1, Username: "adamjtroup"},
+		2: {ID: 2, Username: "gone", Deactivated: true},
+	}}
+
+	handler := NewHandler(store, nil, nil, users)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	get := func(path string, role auth.Role) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if role != "" {
+			ctx := context.WithValue(req.Context(), auth.UserKey, uint32(3))
+			req = req.WithContext(context.WithValue(ctx, auth.RoleKey, role))
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should list the user's games with their progress", func(t *testing.T) {
+		rr := get("/users/1/games", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var progress []models.GameProgress
+		if err := json.NewDecoder(rr.Body).Decode(&progress); err != nil {
+			t.Fatal(err)
+		}
+		if len(progress) != 2 || progress[0].Percent != 50 || progress[0].LastUnlockedAt == nil || progress[1].LastUnlockedAt != nil {
+			t.Errorf("unexpected progress %+v", progress)
+		}
+	})
+
+	t.Run("should return every achievement of a game with whether it was earned", func(t *testing.T) {
+		rr := get("/users/1/games/7", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var detail models.GameProgressDetail
+		if err := json.NewDecoder(rr.Body).Decode(&detail); err != nil {
+			t.Fatal(err)
+		}
+		if detail.GameID != 7 || detail.Earned != 1 || len(detail.Achievements) != 2 {
+			t.Fatalf("unexpected progress %+v", detail)
+		}
+		first, second := detail.Achievements[0], detail.Achievements[1]
+		if !first.Completed || first.CompletedAt == nil || !first.CompletedAt.Equal(unlocked) || first.Name != "Lilac and Gooseberries" {
+			t.Errorf("expected the first achievement to be earned, got %+v", first)
+		}
+		if second.Completed || second.CompletedAt != nil {
+			t.Errorf("expected the second achievement not to be earned, got %+v", second)
+		}
+
+		expected := []models.GradeProgress{
+			{Grade: models.GradeBronze, Earned: 1, Total: 1, Points: 15},
+			{Grade: models.GradeSilver},
+			{Grade: models.GradeGold, Total: 1},
+			{Grade: models.GradePlatinum},
+		}
+		if len(detail.Grades) != len(expected) {
+			t.Fatalf("expected %d grades, got %+v", len(expected), detail.Grades)
+		}
+		for i, grade := range expected {
+			if detail.Grades[i] != grade {
+				t.Errorf("expected %+v, got %+v", grade, detail.Grades[i])
+			}
+		}
+	})
+
+	t.Run("should list the user's rarest unlocks, rarest first", func(t *testing.T) {
+		rr := get("/users/1/rarest-unlocks?limit=1", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var unlocks []models.RareUnlock
+		if err := json.NewDecoder(rr.Body).Decode(&unlocks); err != nil {
+			t.Fatal(err)
+		}
+		if len(unlocks) != 1 || unlocks[0].Name != "Steel Soul" || unlocks[0].GameName != "Hollow Knight" || unlocks[0].Rarity != models.RarityUltraRare {
+			t.Errorf("unexpected unlocks %+v", unlocks)
+		}
+
+		if rr := get("/users/1/rarest-unlocks", ""); rr.Code != http.StatusOK || store.limit != defaultRarestUnlocks {
+			t.Errorf("expected the default limit of %d, got %d", defaultRarestUnlocks, store.limit)
+		}
+	})
+
+	t.Run("should fail for a limit out of range", func(t *testing.T) {
+		for _, limit := range []string{"0", "51", "ten"} {
+			if rr := get("/users/1/rarest-unlocks?limit="+limit, ""); rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for limit %s, got %d", http.StatusBadRequest, limit, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should fail for a game the user doesn't track", func(t *testing.T) {
+		if rr := get("/users/1/games/9", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should hide deactivated users from all but admins", func(t *testing.T) {
+		if rr := get("/users/2/games", auth.RoleUser); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+		if rr := get("/users/2/games", auth.RoleAdmin); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should fail for an unknown user", func(t *testing.T) {
+		if rr := get("/users/4/games", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+func TestCompleteAchievements(t *testing.T) {
+	achievements := &mockAchievementStore{tracked: map[uint32]bool{1: true, 2: true}}
+	handler := NewHandler(nil, achievements, nil, nil)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router)
+
+	post := func(path string, payload interface{}) *httptest.ResponseRecorder {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), auth.UserKey, uint32(1)))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should complete every achievement at the given time", func(t *testing.T) {
+		earned := time.Date(2024, 8, 20, 21, 14, 0, 0, time.UTC)
+		rr := post("/complete-achievements", models.CompleteAchievementsPayload{AchievementIDs: []uint32{1, 2}, CompletedAt: &earned})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		if len(achievements.completed) != 2 || !achievements.completed[2].Equal(earned) {
+			t.Errorf("expected both achievements earned at %s, got %+v", earned, achievements.completed)
+		}
+	})
+
+	t.Run("should fail for completion times in the future", func(t *testing.T) {
+		future := time.Now().Add(time.Hour)
+		rr := post("/complete-achievement", models.CompletedUserAchievementPayload{AchievementID: 1, CompletedAt: &future})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should fail without any achievements", func(t *testing.T) {
+		rr := post("/complete-achievements", models.CompleteAchievementsPayload{AchievementIDs: []uint32{}})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), "must be at least 1 item") {
+			t.Errorf("expected the minimum counted in items, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("should fail for achievements the user doesn't track", func(t *testing.T) {
+		rr := post("/complete-achievements", models.CompleteAchievementsPayload{AchievementIDs: []uint32{1, 3}})
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should uncomplete an achievement", func(t *testing.T) {
+		rr := post("/uncomplete-achievement", models.UncompleteAchievementPayload{AchievementID: 2})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		if _, ok := achievements.completed[2]; ok {
+			t.Error("expected the achievement to no longer be earned")
+		}
+	})
+}
+
+type mockAchievementStore struct {
+	models.AchievementStore
+	tracked   map[uint32]bool
+	completed map[uint32]time.Time
+}
+
+func (s *mockAchievementStore) CompleteAchievements(userID uint32, achievementIDs []uint32, completedAt *time.Time) error {
+	for _, id := range achievementIDs {
+		if !s.tracked[id] {
+			return utils.NotFound("user '%d' doesn't track achievement '%d'", userID, id)
+		}
+	}
+
+	if s.completed == nil {
+		s.completed = make(map[uint32]time.Time)
+	}
+	for _, id := range achievementIDs {
+		if completedAt != nil {
+			s.completed[id] = *completedAt
+		} else {
+			s.completed[id] = time.Now()
+		}
+	}
+	return nil
+}
+
+func (s *mockAchievementStore) UncompleteAchievement(userID, achievementID uint32) error {
+	if !s.tracked[achievementID] {
+		return utils.NotFound("user '%d' doesn't track achievement '%d'", userID, achievementID)
+	}
+	delete(s.completed, achievementID)
+	return nil
+}
+
+type mockUserGameStore struct {
+	models.UserGameStore
+	progress     map[uint32][]*models.GameProgress
+	achievements map[[2]uint32][]*models.AchievementProgress
+	unlocks      map[uint32][]*models.RareUnlock
+	limit        int
+}
+
+func (s *mockUserGameStore) GetAllGameProgress(userID uint32) ([]*models.GameProgress, error) {
+	return s.progress[userID], nil
+}
+
+func (s *mockUserGameStore) GetGameProgress(userID, gameID uint32) (*models.GameProgress, error) {
+	for _, p := range s.progress[userID] {
+		if p.GameID == gameID {
+			return p, nil
+		}
+	}
+	return nil, utils.NotFound("user '%d' doesn't track game '%d'", userID, gameID)
+}
+
+func (s *mockUserGameStore) GetAchievementProgress(userID, gameID uint32) ([]*models.AchievementProgress, error) {
+	return s.achievements[[2]uint32{userID, gameID}], nil
+}
+
+func (s *mockUserGameStore) GetRarestUnlocks(userID uint32, limit int) ([]*models.RareUnlock, error) {
+	s.limit = limit
+	unlocks := s.unlocks[userID]
+	if len(unlocks) > limit {
+		unlocks = unlocks[:limit]
+	}
+	return unlocks, nil
+}
+
+type mockUserStore struct {
+	models.UserStore
+	users map[int]*models.User
+}
+
+func (s *mockUserStore) GetUserByID(id int) (*models.User, error) {
+	u, ok := s.users[id]
+	if !ok {
+		return nil, utils.NotFound("user not found with id '%d'", id)
+	}
+	return u, nil
+}
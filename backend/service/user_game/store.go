@@ -3,8 +3,12 @@ package usergame
 import (
 	"database/sql"
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/achievement"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
 )
 
 type Store struct {
@@ -15,8 +19,6 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
-
-
 func (s *Store) GetUserGameByID(userID, gameID uint32) (*models.UserGame, error) {
 	row := s.db.QueryRow("SELECT * FROM user_games WHERE user_id = ? AND game_id = ?", userID, gameID)
 
@@ -24,7 +26,7 @@ func (s *Store) GetUserGameByID(userID, gameID uint32) (*models.UserGame, error)
 	err := scanUserGame(row, &u)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user game not found with user_id '%d' and game_id '%d'", userID, gameID)
+			return nil, utils.NotFound("user game not found with user_id '%d' and game_id '%d'", userID, gameID)
 		}
 		return nil, err
 	}
@@ -55,10 +57,135 @@ func (s *Store) GetAllUserGames(userID uint32) ([]*models.UserGame, error) {
 	return us, nil
 }
 
+const gameProgressQuery = `
+	SELECT g.id, g.name, g.slug, g.background_img, COUNT(ua.id), COALESCE(SUM(ua.completed), 0), MAX(ua.completed_at), ug.completed_at, ug.tracked_at
+	FROM user_games ug
+	JOIN games g ON g.id = ug.game_id
+	LEFT JOIN user_achievements ua ON ua.user_id = ug.user_id AND ua.game_id = ug.game_id
+	WHERE ug.user_id = ?`
+
+const gameProgressGroupBy = " GROUP BY g.id, g.name, g.slug, g.background_img, ug.completed_at, ug.tracked_at"
+
+func (s *Store) GetAllGameProgress(userID uint32) ([]*models.GameProgress, error) {
+	rows, err := s.db.Query(gameProgressQuery+gameProgressGroupBy+" ORDER BY COALESCE(MAX(ua.completed_at), ug.tracked_at) DESC, g.id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := []*models.GameProgress{}
+	for rows.Next() {
+		var p models.GameProgress
+		if err := scanGameProgress(rows, &p); err != nil {
+			return nil, err
+		}
+		progress = append(progress, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return progress, nil
+}
+
+func (s *Store) GetGameProgress(userID, gameID uint32) (*models.GameProgress, error) {
+	row := s.db.QueryRow(gameProgressQuery+" AND ug.game_id = ?"+gameProgressGroupBy, userID, gameID)
+
+	var p models.GameProgress
+	if err := scanGameProgress(row, &p); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("user '%d' doesn't track game '%d'", userID, gameID)
+		}
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (s *Store) GetAchievementProgress(userID, gameID uint32) ([]*models.AchievementProgress, error) {
+	rows, err := s.db.Query(`
+		SELECT a.id, a.rawg_id, a.name, a.description, a.imgurl, a.percent, a.grade, a.game_id, ua.completed, ua.completed_at
+		FROM user_achievements ua
+		JOIN achievements a ON a.id = ua.achievement_id
+		WHERE ua.user_id = ? AND ua.game_id = ?
+		ORDER BY a.id`, userID, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := []*models.AchievementProgress{}
+	for rows.Next() {
+		var p models.AchievementProgress
+		var rawgID sql.NullInt64
+		var description, imgURL sql.NullString
+		var completedAt sql.NullTime
+
+		err := rows.Scan(&p.ID, &rawgID, &p.Name, &description, &imgURL, &p.Percent, &p.Grade, &p.GameID, &p.Completed, &completedAt)
+		if err != nil {
+			return nil, err
+		}
+		p.RAWGID = uint32(rawgID.Int64)
+		p.Description = description.String
+		p.ImgURL = imgURL.String
+		p.Rarity = achievement.Rarity(p.Percent)
+		p.CompletedAt = timeOrNil(completedAt)
+
+		progress = append(progress, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return progress, nil
+}
+
+func (s *Store) GetRarestUnlocks(userID uint32, limit int) ([]*models.RareUnlock, error) {
+	rows, err := s.db.Query(`
+		SELECT a.id, a.rawg_id, a.name, a.description, a.imgurl, a.percent, a.grade, a.game_id, g.name, ua.completed_at
+		FROM user_achievements ua
+		JOIN achievements a ON a.id = ua.achievement_id
+		JOIN games g ON g.id = ua.game_id
+		WHERE ua.user_id = ? AND ua.completed = TRUE AND ua.completed_at IS NOT NULL AND a.percent IS NOT NULL
+		ORDER BY a.percent, ua.completed_at, a.id
+		LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	unlocks := []*models.RareUnlock{}
+	for rows.Next() {
+		var u models.RareUnlock
+		var rawgID sql.NullInt64
+		var description, imgURL sql.NullString
+
+		err := rows.Scan(&u.ID, &rawgID, &u.Name, &description, &imgURL, &u.Percent, &u.Grade, &u.GameID, &u.GameName, &u.CompletedAt)
+		if err != nil {
+			return nil, err
+		}
+		u.RAWGID = uint32(rawgID.Int64)
+		u.Description = description.String
+		u.ImgURL = imgURL.String
+		u.Rarity = achievement.Rarity(u.Percent)
+
+		unlocks = append(unlocks, &u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return unlocks, nil
+}
+
 func (s *Store) TrackGame(userID, gameID uint32) error {
 	_, err := s.db.Exec("INSERT INTO user_games (user_id, game_id) VALUES (?, ?)",
 		userID, gameID)
 	if err != nil {
+		// The handler checks first, but a concurrent request can still get there first
+		if utils.IsDuplicateEntry(err) {
+			return utils.Conflict("user is already tracking game")
+		}
 		return err
 	}
 
@@ -82,9 +209,41 @@ func (s *Store) UntrackGame(userID, gameID uint32) error {
 func scanUserGame(scanner interface {
 	Scan(dest ...interface{}) error
 }, game *models.UserGame) error {
-	err := scanner.Scan(&game.ID, &game.UserID, &game.GameID, &game.TrackedAt, &game.CompletedAt, &game.UpdatedAt)
+	// Only completed games have a completed_at
+	var completedAt sql.NullTime
+	err := scanner.Scan(&game.ID, &game.UserID, &game.GameID, &game.TrackedAt, &completedAt, &game.UpdatedAt)
 	if err != nil {
 		return err
 	}
+	game.CompletedAt = completedAt.Time
 	return nil
 }
+
+func scanGameProgress(scanner interface {
+	Scan(dest ...interface{}) error
+}, p *models.GameProgress) error {
+	var backgroundIMG sql.NullString
+	var lastUnlockedAt, completedAt sql.NullTime
+
+	err := scanner.Scan(&p.GameID, &p.Name, &p.Slug, &backgroundIMG, &p.Total, &p.Earned, &lastUnlockedAt, &completedAt, &p.TrackedAt)
+	if err != nil {
+		return err
+	}
+
+	p.BackgroundIMG = backgroundIMG.String
+	p.LastUnlockedAt = timeOrNil(lastUnlockedAt)
+	p.CompletedAt = timeOrNil(completedAt)
+	p.Platinum = completedAt.Valid
+	if p.Total > 0 {
+		p.Percent = math.Round(float64(p.Earned)/float64(p.Total)*10000) / 100
+	}
+
+	return nil
+}
+
+func timeOrNil(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
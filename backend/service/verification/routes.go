@@ -0,0 +1,211 @@
+package verification
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/config"
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+
+	verifyEmailExpiration   = 48 * time.Hour
+	resetPasswordExpiration = time.Hour
+)
+
+type Handler struct {
+	store        models.UserTokenStore
+	userStore    models.UserStore
+	sessionStore models.SessionStore
+	mailer       models.Mailer
+}
+
+func NewHandler(store models.UserTokenStore, userStore models.UserStore, sessionStore models.SessionStore, mailer models.Mailer) *Handler {
+	return &Handler{store: store, userStore: userStore, sessionStore: sessionStore, mailer: mailer}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/verify-email", utils.MakeHandler(h.handleVerifyEmail)).Methods("POST")
+	router.HandleFunc("/resend-verification", utils.MakeHandler(h.handleResendVerification)).Methods("POST")
+	router.HandleFunc("/forgot-password", utils.MakeHandler(h.handleForgotPassword)).Methods("POST")
+	router.HandleFunc("/reset-password", utils.MakeHandler(h.handleResetPassword)).Methods("POST")
+}
+
+// SendEmailVerification mails u a link to confirm they own their email address
+func SendEmailVerification(store models.UserTokenStore, mailer models.Mailer, u *models.User) error {
+	token, hash, err := auth.NewSignedToken(PurposeVerifyEmail)
+	if err != nil {
+		return err
+	}
+
+	err = store.CreateToken(u.ID, PurposeVerifyEmail, hash, time.Now().Add(verifyEmailExpiration))
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(models.Mail{
+		To:      u.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm this is your email address by opening the link below. It expires in %d hours.\n\n%s/verify-email?token=%s\n",
+			u.Firstname, int(verifyEmailExpiration.Hours()), config.Envs.AppURL, token),
+	})
+}
+
+func (h *Handler) handleVerifyEmail(w http.ResponseWriter, r *http.Request) error {
+	var payload models.VerifyEmailPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
+	}
+
+	userID, err := h.consume(PurposeVerifyEmail, payload.Token)
+	if err != nil {
+		return err
+	}
+
+	err = h.userStore.SetEmailVerified(userID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+func (h *Handler) handleResendVerification(w http.ResponseWriter, r *http.Request) error {
+	userID, err := auth.ActingUserID(r, 0)
+	if err != nil {
+		return err
+	}
+
+	u, err := h.userStore.GetUserByID(int(userID))
+	if err != nil {
+		return err
+	}
+
+	if u.EmailVerified {
+		return utils.Conflict("email is already verified")
+	}
+
+	err = SendEmailVerification(h.store, h.mailer, u)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+// handleForgotPassword always responds with a 200 so it can't be used to find out which emails are registered
+func (h *Handler) handleForgotPassword(w http.ResponseWriter, r *http.Request) error {
+	var payload models.ForgotPasswordPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
+	}
+
+	u, err := h.userStore.GetUserByUsernameOrEmail(payload.Email)
+	if err != nil || u.Email != payload.Email {
+		return utils.WriteJSON(w, http.StatusOK, nil)
+	}
+
+	// Failing only for registered emails would give them away too
+	if err := h.sendPasswordReset(u); err != nil {
+		log.Printf("failed to send password reset mail to user %d: %v", u.ID, err)
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+func (h *Handler) sendPasswordReset(u *models.User) error {
+	token, hash, err := auth.NewSignedToken(PurposeResetPassword)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.CreateToken(u.ID, PurposeResetPassword, hash, time.Now().Add(resetPasswordExpiration))
+	if err != nil {
+		return err
+	}
+
+	return h.mailer.Send(models.Mail{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. If that was you, open the link below within the hour. Otherwise you can ignore this email.\n\n%s/reset-password?token=%s\n",
+			u.Firstname, config.Envs.AppURL, token),
+	})
+}
+
+func (h *Handler) handleResetPassword(w http.ResponseWriter, r *http.Request) error {
+	var payload models.ResetPasswordPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		return err
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		return utils.ValidationError(err)
+	}
+
+	// Check the new password before using up the token, so a rejected password can be retried
+	if payload.NewPassword != payload.ConfirmNewPassword {
+		return utils.Invalid("new password did not match confirm new password")
+	}
+	if err := utils.CheckPasswordPolicy(payload.NewPassword); err != nil {
+		return err
+	}
+
+	userID, err := h.consume(PurposeResetPassword, payload.Token)
+	if err != nil {
+		return err
+	}
+
+	u, err := h.userStore.GetUserByID(int(userID))
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := auth.HashNewPassword(payload.NewPassword, payload.ConfirmNewPassword, u.Password)
+	if err != nil {
+		return err
+	}
+
+	err = h.userStore.SetPassword(u.ID, hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	// Following the emailed link proves the user owns the address
+	err = h.userStore.SetEmailVerified(u.ID)
+	if err != nil {
+		return err
+	}
+
+	err = h.sessionStore.RevokeAllSessions(u.ID)
+	if err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, http.StatusOK, nil)
+}
+
+// consume checks the token's signature and uses it up
+func (h *Handler) consume(purpose, token string) (uint32, error) {
+	hash, err := auth.VerifySignedToken(purpose, token)
+	if err != nil {
+		return 0, utils.Invalid("invalid token")
+	}
+
+	return h.store.ConsumeToken(purpose, hash)
+}
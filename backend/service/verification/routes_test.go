@@ -0,0 +1,252 @@
+package verification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/models"
+	"github.com/ajtroup1/platinum-trophy-tracker/service/auth"
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+	"github.com/gorilla/mux"
+)
+
+func TestVerification(t *testing.T) {
+	tokenStore := newMockTokenStore()
+	userStore := &mockUserStore{}
+	mailer := &mockMailer{}
+	handler := NewHandler(tokenStore, userStore, &mockSessionStore{}, mailer)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/verify-email", utils.MakeHandler(handler.handleVerifyEmail))
+	router.HandleFunc("/forgot-password", utils.MakeHandler(handler.handleForgotPassword))
+	router.HandleFunc("/reset-password", utils.MakeHandler(handler.handleResetPassword))
+
+	t.Run("should verify an email once", func(t *testing.T) {
+		err := SendEmailVerification(tokenStore, mailer, &models.User{ID: 1, Email: "adamjtroup@gmail.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := tokenFromMail(t, mailer.sent[len(mailer.sent)-1])
+
+		for i, want := range []int{http.StatusOK, http.StatusBadRequest} {
+			marshal, _ := json.Marshal(models.VerifyEmailPayload{Token: token})
+
+			req, err := http.NewRequest(http.MethodPost, "/verify-email", bytes.NewBuffer(marshal))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != want {
+				t.Errorf("attempt %d: expected status code %d, got %d. Response body: %s", i+1, want, rr.Code, rr.Body.String())
+			}
+		}
+		if !userStore.verified {
+			t.Error("expected email to be verified")
+		}
+	})
+
+	t.Run("should not reveal whether an email is registered", func(t *testing.T) {
+		sent := len(mailer.sent)
+		marshal, _ := json.Marshal(models.ForgotPasswordPayload{Email: "nobody@mail.com"})
+
+		req, err := http.NewRequest(http.MethodPost, "/forgot-password", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if len(mailer.sent) != sent {
+			t.Error("expected no mail to be sent")
+		}
+	})
+
+	t.Run("should not reveal a registered email when the mail fails", func(t *testing.T) {
+		mailer.err = errors.New("smtp server unavailable")
+		defer func() { mailer.err = nil }()
+
+		marshal, _ := json.Marshal(models.ForgotPasswordPayload{Email: "adamjtroup@gmail.com"})
+
+		req, err := http.NewRequest(http.MethodPost, "/forgot-password", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("should reset a password with a mailed token", func(t *testing.T) {
+		marshal, _ := json.Marshal(models.ForgotPasswordPayload{Email: "adamjtroup@gmail.com"})
+
+		req, err := http.NewRequest(http.MethodPost, "/forgot-password", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d. Response body: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		token := tokenFromMail(t, mailer.sent[len(mailer.sent)-1])
+
+		// A weak password is rejected without using up the token
+		for _, c := range []struct {
+			password string
+			want     int
+		}{
+			{"weak", http.StatusBadRequest},
+			{"Sample1234!", http.StatusOK},
+		} {
+			marshal, _ := json.Marshal(models.ResetPasswordPayload{Token: token, NewPassword: c.password, ConfirmNewPassword: c.password})
+
+			req, err := http.NewRequest(http.MethodPost, "/reset-password", bytes.NewBuffer(marshal))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != c.want {
+				t.Errorf("password %q: expected status code %d, got %d. Response body: %s", c.password, c.want, rr.Code, rr.Body.String())
+			}
+		}
+		if !auth.ComparePasswords(userStore.password, []byte("Sample1234!")) {
+			t.Error("expected the password to be reset")
+		}
+	})
+
+	t.Run("should not accept a verification token as a reset token", func(t *testing.T) {
+		err := SendEmailVerification(tokenStore, mailer, &models.User{ID: 1, Email: "adamjtroup@gmail.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := tokenFromMail(t, mailer.sent[len(mailer.sent)-1])
+
+		marshal, _ := json.Marshal(models.ResetPasswordPayload{Token: token, NewPassword: "Sample12345!", ConfirmNewPassword: "Sample12345!"})
+
+		req, err := http.NewRequest(http.MethodPost, "/reset-password", bytes.NewBuffer(marshal))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d. Response body: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+		}
+	})
+}
+
+func tokenFromMail(t *testing.T, mail models.Mail) string {
+	t.Helper()
+
+	_, token, ok := strings.Cut(mail.Body, "token=")
+	if !ok {
+		t.Fatalf("expected a token in the mail: %s", mail.Body)
+	}
+	return strings.TrimSpace(token)
+}
+
+type mockTokenStore struct {
+	tokens map[string]uint32
+	used   map[string]bool
+}
+
+func newMockTokenStore() *mockTokenStore {
+	return &mockTokenStore{tokens: make(map[string]uint32), used: make(map[string]bool)}
+}
+
+func (s *mockTokenStore) CreateToken(userID uint32, purpose, tokenHash string, expiresAt time.Time) error {
+	s.tokens[purpose+tokenHash] = userID
+	return nil
+}
+
+func (s *mockTokenStore) ConsumeToken(purpose, tokenHash string) (uint32, error) {
+	userID, ok := s.tokens[purpose+tokenHash]
+	if !ok || s.used[purpose+tokenHash] {
+		return 0, utils.Invalid("invalid or expired token")
+	}
+	s.used[purpose+tokenHash] = true
+	return userID, nil
+}
+
+func (s *mockTokenStore) FindToken(purpose, tokenHash string) (uint32, error) {
+	userID, ok := s.tokens[purpose+tokenHash]
+	if !ok || s.used[purpose+tokenHash] {
+		return 0, utils.Invalid("invalid or expired token")
+	}
+	return userID, nil
+}
+
+type mockUserStore struct {
+	models.UserStore
+	verified bool
+	password string
+}
+
+func (s *mockUserStore) GetUserByID(id int) (*models.User, error) {
+	if id == 1 {
+		return &models.User{ID: 1, Username: "adamjtroup", Email: "adamjtroup@gmail.com", Password: s.password}, nil
+	}
+	return nil, utils.NotFound("user not found")
+}
+
+func (s *mockUserStore) GetUserByUsernameOrEmail(val string) (*models.User, error) {
+	if val == "adamjtroup" || val == "adamjtroup@gmail.com" {
+		return s.GetUserByID(1)
+	}
+	return nil, utils.NotFound("user not found")
+}
+
+func (s *mockUserStore) SetEmailVerified(id uint32) error {
+	s.verified = true
+	return nil
+}
+
+func (s *mockUserStore) SetPassword(id uint32, hashedPassword string) error {
+	s.password = hashedPassword
+	return nil
+}
+
+type mockSessionStore struct {
+	models.SessionStore
+}
+
+func (s *mockSessionStore) RevokeAllSessions(userID uint32) error {
+	return nil
+}
+
+type mockMailer struct {
+	sent []models.Mail
+	err  error
+}
+
+func (m *mockMailer) Send(mail models.Mail) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, mail)
+	return nil
+}
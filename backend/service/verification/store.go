@@ -0,0 +1,92 @@
+package verification
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ajtroup1/platinum-trophy-tracker/utils"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateToken stores a new token and invalidates any unused token the user
+// still has for the same purpose
+func (s *Store) CreateToken(userID uint32, purpose, tokenHash string, expiresAt time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE user_tokens SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND purpose = ? AND used_at IS NULL",
+		userID, purpose)
+	if err != nil {
+		return fmt.Errorf("failed to invalidate previous tokens: %v", err)
+	}
+
+	_, err = tx.Exec("INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES (?, ?, ?, ?)",
+		userID, purpose, tokenHash, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create token: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// ConsumeToken marks a token as used and returns the user it was issued to.
+// Unknown, expired and already used tokens are rejected.
+func (s *Store) ConsumeToken(purpose, tokenHash string) (uint32, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var id, userID uint32
+	err = tx.QueryRow(`
+		SELECT id, user_id
+		FROM user_tokens
+		WHERE purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		FOR UPDATE`, purpose, tokenHash).Scan(&id, &userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, utils.Invalid("invalid or expired token")
+		}
+		return 0, err
+	}
+
+	_, err = tx.Exec("UPDATE user_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = ?", id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to use token: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return userID, nil
+}
+
+func (s *Store) FindToken(purpose, tokenHash string) (uint32, error) {
+	var userID uint32
+	err := s.db.QueryRow(`
+		SELECT user_id
+		FROM user_tokens
+		WHERE purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP`,
+		purpose, tokenHash).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, utils.Invalid("invalid or expired token")
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,253 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+)
+
+// The kinds of error stores and handlers return. Wrap them with the
+// constructors below so MakeHandler can pick the status code.
+var (
+	ErrValidation      = errors.New("validation failed")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrUpstream        = errors.New("upstream error")
+)
+
+// Every error response has this shape. Code is stable for clients to switch
+// on, Error is a human readable message.
+type ErrorResponse struct {
+	Error   string       `json:"error"`
+	Code    string       `json:"code"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is an error of one of the kinds above with a message that is safe to
+// show to clients. The cause, if any, is only logged.
+type Error struct {
+	Kind    error
+	Message string
+	Details []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+func newError(kind error, format string, args []any) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+func Invalid(format string, args ...any) error {
+	return newError(ErrValidation, format, args)
+}
+
+func Unauthorized(format string, args ...any) error {
+	return newError(ErrUnauthorized, format, args)
+}
+
+func Forbidden(format string, args ...any) error {
+	return newError(ErrForbidden, format, args)
+}
+
+func NotFound(format string, args ...any) error {
+	return newError(ErrNotFound, format, args)
+}
+
+func Conflict(format string, args ...any) error {
+	return newError(ErrConflict, format, args)
+}
+
+func TooManyRequests(format string, args ...any) error {
+	return newError(ErrTooManyRequests, format, args)
+}
+
+// Upstream reports a failure of a service we depend on, such as RAWG
+func Upstream(err error, format string, args ...any) error {
+	return &Error{Kind: ErrUpstream, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// ValidationError turns the error from Validate.Struct into field-level details
+func ValidationError(err error) error {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return Invalid("invalid payload: %v", err)
+	}
+
+	details := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		details = append(details, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: fieldMessage(fe),
+		})
+	}
+
+	return &Error{Kind: ErrValidation, Message: "invalid payload", Details: details}
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fe.Field() + " is required"
+	case "email":
+		return fe.Field() + " must be a valid email address"
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", fe.Field(), bound(fe))
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", fe.Field(), bound(fe))
+	case "password":
+		if err := CheckPasswordPolicy(fe.Value().(string)); err != nil {
+			return err.Error()
+		}
+	}
+	return fmt.Sprintf("%s failed the '%s' rule", fe.Field(), fe.Tag())
+}
+
+// bound is the param of a min or max rule in what it counts for the field's kind
+func bound(fe validator.FieldError) string {
+	var unit string
+	switch fe.Kind() {
+	case reflect.String:
+		unit = " character"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " item"
+	default:
+		return fe.Param()
+	}
+
+	if fe.Param() != "1" {
+		unit += "s"
+	}
+	return fe.Param() + unit
+}
+
+// IsDuplicateEntry reports whether err is MySQL rejecting a duplicate unique key
+func IsDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+var errorStatuses = []struct {
+	kind   error
+	status int
+}{
+	{ErrValidation, http.StatusBadRequest},
+	{ErrUnauthorized, http.StatusUnauthorized},
+	{ErrForbidden, http.StatusForbidden},
+	{ErrNotFound, http.StatusNotFound},
+	{ErrConflict, http.StatusConflict},
+	{ErrTooManyRequests, http.StatusTooManyRequests},
+	{ErrUpstream, http.StatusBadGateway},
+}
+
+// StatusCode returns the HTTP status for err, 500 for errors of no known kind
+func StatusCode(err error) int {
+	for _, s := range errorStatuses {
+		if errors.Is(err, s.kind) {
+			return s.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+var errorCodes = map[int]string{
+	http.StatusBadRequest:          "validation_failed",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not_found",
+	http.StatusConflict:            "conflict",
+	http.StatusTooManyRequests:     "rate_limited",
+	http.StatusInternalServerError: "internal_error",
+	http.StatusBadGateway:          "upstream_error",
+}
+
+func errorCode(status int) string {
+	if code, ok := errorCodes[status]; ok {
+		return code
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// HandlerFunc is a handler that returns its error for MakeHandler to write
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// MakeHandler adapts a HandlerFunc to the router, writing any error it
+// returns with the status code for its kind. Errors returned after the
+// response has started, such as from WriteJSON, can only be logged.
+func MakeHandler(f HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+
+		err := f(rw, r)
+		if err == nil {
+			return
+		}
+
+		status := StatusCode(err)
+		if status >= http.StatusInternalServerError || rw.wroteHeader {
+			log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		}
+		if !rw.wroteHeader {
+			WriteError(w, status, err)
+		}
+	}
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// WriteError writes err in the ErrorResponse envelope. The messages of
+// server errors that aren't an *Error are hidden from the client.
+func WriteError(w http.ResponseWriter, status int, err error) {
+	res := ErrorResponse{Error: err.Error(), Code: errorCode(status)}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		res.Error = apiErr.Message
+		res.Details = apiErr.Details
+	} else if status >= http.StatusInternalServerError {
+		res.Error = http.StatusText(status)
+	}
+
+	WriteJSON(w, status, res)
+}
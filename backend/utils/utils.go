@@ -2,9 +2,11 @@ package utils
 
 import (
 	"encoding/json"
-	"fmt"
+	"net"
 	"net/http"
+	"reflect"
 	"regexp"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -14,27 +16,57 @@ var Validate *validator.Validate
 func init() {
 	Validate = validator.New()
 	Validate.RegisterValidation("password", validatePassword)
+
+	// Report fields by the names clients send them as
+	Validate.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
 }
 
 // validatePassword checks password complexity requirements
 func validatePassword(fl validator.FieldLevel) bool {
-	password := fl.Field().String()
-	hasMinLen := len(password) >= 3
-	hasMaxLen := len(password) <= 130
-	hasUpper := regexp.MustCompile(`[A-Z]`).MatchString(password)
-	hasLower := regexp.MustCompile(`[a-z]`).MatchString(password)
-	hasNumber := regexp.MustCompile(`[0-9]`).MatchString(password)
-	hasSpecial := regexp.MustCompile(`[!@#~$%^&*(),.?":{}|<>]`).MatchString(password)
-
-	return hasMinLen && hasMaxLen && hasUpper && hasLower && hasNumber && hasSpecial
+	return CheckPasswordPolicy(fl.Field().String()) == nil
+}
+
+var (
+	upperRegex   = regexp.MustCompile(`[A-Z]`)
+	lowerRegex   = regexp.MustCompile(`[a-z]`)
+	numberRegex  = regexp.MustCompile(`[0-9]`)
+	specialRegex = regexp.MustCompile(`[!@#~$%^&*(),.?":{}|<>]`)
+)
+
+// CheckPasswordPolicy is the single password policy for registering, changing and resetting passwords
+func CheckPasswordPolicy(password string) error {
+	switch {
+	case len(password) < 3 || len(password) > 130:
+		return Invalid("password must be between 3 and 130 characters")
+	case !upperRegex.MatchString(password):
+		return Invalid("password must contain an uppercase letter")
+	case !lowerRegex.MatchString(password):
+		return Invalid("password must contain a lowercase letter")
+	case !numberRegex.MatchString(password):
+		return Invalid("password must contain a number")
+	case !specialRegex.MatchString(password):
+		return Invalid("password must contain a special character")
+	}
+
+	return nil
 }
 
 func ParseJSON(r *http.Request, payload any) error {
 	if r.Body == nil {
-		return fmt.Errorf("missing request body")
+		return Invalid("missing request body")
 	}
 
-	return json.NewDecoder(r.Body).Decode(payload)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return Invalid("invalid JSON: %v", err)
+	}
+
+	return nil
 }
 
 func WriteJSON(w http.ResponseWriter, status int, v any) error {
@@ -43,6 +75,11 @@ func WriteJSON(w http.ResponseWriter, status int, v any) error {
 	return json.NewEncoder(w).Encode(v)
 }
 
-func WriteError(w http.ResponseWriter, status int, err error) {
-	WriteJSON(w, status, map[string]string{"error": err.Error()})
+// ClientIP returns the address the request came from, without the port
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }